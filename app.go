@@ -8,9 +8,19 @@ import (
 	goruntime "runtime"
 
 	"factureapp/backend/client"
+	"factureapp/backend/currency"
 	"factureapp/backend/database"
 	"factureapp/backend/inventory"
 	"factureapp/backend/invoice"
+	"factureapp/backend/layout"
+	"factureapp/backend/mailer"
+	"factureapp/backend/money"
+	"factureapp/backend/pos"
+	"factureapp/backend/pricing"
+	"factureapp/backend/recurring"
+	"factureapp/backend/settings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 const AppVersion = "1.1.0"
@@ -21,18 +31,39 @@ type App struct {
 	invoiceService   *invoice.Service
 	inventoryService *inventory.Service
 	clientService    *client.Service
+	posService       *pos.Service
+	pricingService   *pricing.Service
+	currencyService  *currency.Service
+	layoutService    *layout.Service
+	settingsService  *settings.Service
+	mailerService    *mailer.Service
+	recurringService *recurring.Service
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	inventoryService := inventory.NewService()
-	invoiceService := invoice.NewService(inventoryService)
+	pricingService := pricing.NewService()
+	currencyService := currency.NewService()
+	layoutService := layout.NewService()
+	settingsService := settings.NewService()
+	invoiceService := invoice.NewService(inventoryService, pricingService, currencyService, layoutService, settingsService)
 	clientService := client.NewService()
+	posService := pos.NewService(inventoryService)
+	mailerService := mailer.NewService(invoiceService)
+	recurringService := recurring.NewService(invoiceService)
 
 	return &App{
 		invoiceService:   invoiceService,
 		inventoryService: inventoryService,
 		clientService:    clientService,
+		posService:       posService,
+		pricingService:   pricingService,
+		currencyService:  currencyService,
+		layoutService:    layoutService,
+		settingsService:  settingsService,
+		mailerService:    mailerService,
+		recurringService: recurringService,
 	}
 }
 
@@ -47,6 +78,9 @@ func (a *App) startup(ctx context.Context) {
 	}
 
 	// Run migrations
+	if err := a.settingsService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run settings migrations: %v", err))
+	}
 	if err := a.inventoryService.Migrate(); err != nil {
 		panic(fmt.Sprintf("Failed to run inventory migrations: %v", err))
 	}
@@ -56,6 +90,31 @@ func (a *App) startup(ctx context.Context) {
 	if err := a.clientService.Migrate(); err != nil {
 		panic(fmt.Sprintf("Failed to run client migrations: %v", err))
 	}
+	if err := a.posService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run POS migrations: %v", err))
+	}
+	if err := a.pricingService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run pricing migrations: %v", err))
+	}
+	if err := a.currencyService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run currency migrations: %v", err))
+	}
+	if err := a.layoutService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run template migrations: %v", err))
+	}
+	if err := a.mailerService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run mailer migrations: %v", err))
+	}
+	if err := a.recurringService.Migrate(); err != nil {
+		panic(fmt.Sprintf("Failed to run recurring invoice migrations: %v", err))
+	}
+
+	// Bill the recurring invoices that fell due while the application was closed
+	if report, err := a.recurringService.GenerateDueInvoices(); err != nil {
+		fmt.Printf("Recurring invoice generation failed: %v\n", err)
+	} else if len(report.Generated) > 0 || len(report.Failed) > 0 {
+		fmt.Printf("Recurring invoices: %d generated, %d failed\n", len(report.Generated), len(report.Failed))
+	}
 
 	fmt.Println("FactureApp started successfully")
 }
@@ -65,14 +124,69 @@ func (a *App) CreateInvoice(req invoice.InvoiceCreateRequest) (*invoice.InvoiceR
 	return a.invoiceService.CreateInvoice(req)
 }
 
-// UpdateInvoice updates an existing invoice
+// CreateDraft saves an invoice as a draft, without number or stock reservation
+func (a *App) CreateDraft(req invoice.InvoiceCreateRequest) (*invoice.InvoiceResponse, error) {
+	return a.invoiceService.CreateDraft(req)
+}
+
+// IssueInvoice issues a draft: numbers it, decrements the stock and seals it
+func (a *App) IssueInvoice(id uint) (*invoice.InvoiceResponse, error) {
+	return a.invoiceService.IssueInvoice(id)
+}
+
+// UpdateInvoice updates a draft invoice
 func (a *App) UpdateInvoice(id uint, req invoice.InvoiceCreateRequest) (*invoice.InvoiceResponse, error) {
 	return a.invoiceService.UpdateInvoice(id, req)
 }
 
-// GetAllInvoices returns all invoices for a specific year
-func (a *App) GetAllInvoices(year int) ([]invoice.InvoiceResponse, error) {
-	return a.invoiceService.GetAllInvoices(year)
+// DeleteDraft deletes a draft invoice
+func (a *App) DeleteDraft(id uint) error {
+	return a.invoiceService.DeleteDraft(id)
+}
+
+// DuplicateInvoice copies an invoice into a new invoice request for editing, optionally with current prices
+func (a *App) DuplicateInvoice(id uint, newDate string, refreshPrices bool) (*invoice.InvoiceDuplicate, error) {
+	return a.invoiceService.DuplicateInvoice(id, newDate, refreshPrices)
+}
+
+// DuplicateAsDraft copies an invoice and saves the copy as a draft
+func (a *App) DuplicateAsDraft(id uint, newDate string, refreshPrices bool) (*invoice.InvoiceResponse, error) {
+	return a.invoiceService.DuplicateAsDraft(id, newDate, refreshPrices)
+}
+
+// CreateCreditNote credits all or part of an issued invoice
+func (a *App) CreateCreditNote(invoiceID uint, req invoice.CreditNoteRequest) (*invoice.CreditNote, error) {
+	return a.invoiceService.CreateCreditNote(invoiceID, req)
+}
+
+// CancelInvoice cancels an issued invoice with a full credit note and restocks its lines
+func (a *App) CancelInvoice(id uint, reason string) (*invoice.CreditNote, error) {
+	return a.invoiceService.CancelInvoice(id, reason)
+}
+
+// GetCreditNotes returns the credit notes of an invoice
+func (a *App) GetCreditNotes(invoiceID uint) ([]invoice.CreditNote, error) {
+	return a.invoiceService.GetCreditNotes(invoiceID)
+}
+
+// CreateReturn records goods returned against an invoice, with its credit note and optional refund
+func (a *App) CreateReturn(invoiceID uint, req invoice.ReturnRequest) (*invoice.Return, error) {
+	return a.invoiceService.CreateReturn(invoiceID, req)
+}
+
+// GetReturns returns the customer returns of an invoice
+func (a *App) GetReturns(invoiceID uint) ([]invoice.Return, error) {
+	return a.invoiceService.GetReturns(invoiceID)
+}
+
+// GetAllInvoices returns all invoices for a specific year, optionally with a given status (empty: all)
+func (a *App) GetAllInvoices(year int, status string) ([]invoice.InvoiceResponse, error) {
+	return a.invoiceService.GetAllInvoices(year, status)
+}
+
+// QueryInvoices returns a page of the invoices matching the filters, with the total count
+func (a *App) QueryInvoices(q invoice.InvoiceQuery) (*invoice.InvoicePage, error) {
+	return a.invoiceService.QueryInvoices(q)
 }
 
 // GetInvoiceByID returns a single invoice by ID
@@ -94,6 +208,201 @@ func (a *App) GeneratePDF(invoiceID uint) (string, error) {
 	return pdfPath, nil
 }
 
+// GeneratePDFWithTemplate generates a PDF for the invoice with a chosen template (0: default)
+func (a *App) GeneratePDFWithTemplate(invoiceID uint, templateID uint) (string, error) {
+	return a.invoiceService.GeneratePDFWithTemplate(invoiceID, templateID)
+}
+
+// GetInvoiceTemplates returns all invoice templates, the default first
+func (a *App) GetInvoiceTemplates() ([]layout.Template, error) {
+	return a.layoutService.GetAllTemplates()
+}
+
+// CreateInvoiceTemplate creates a new invoice template
+func (a *App) CreateInvoiceTemplate(tpl layout.Template) (*layout.Template, error) {
+	return a.layoutService.CreateTemplate(tpl)
+}
+
+// UpdateInvoiceTemplate updates an invoice template
+func (a *App) UpdateInvoiceTemplate(tpl layout.Template) error {
+	return a.layoutService.UpdateTemplate(tpl)
+}
+
+// DeleteInvoiceTemplate deletes an invoice template other than the default one
+func (a *App) DeleteInvoiceTemplate(id uint) error {
+	return a.layoutService.DeleteTemplate(id)
+}
+
+// SetDefaultInvoiceTemplate makes a template the default one
+func (a *App) SetDefaultInvoiceTemplate(id uint) error {
+	return a.layoutService.SetDefaultTemplate(id)
+}
+
+// SelectTemplateLogo lets the user pick a PNG or JPG logo for a template
+func (a *App) SelectTemplateLogo(templateID uint) (string, error) {
+	sourcePath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Choisir un logo",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Images (*.png, *.jpg)", Pattern: "*.png;*.jpg;*.jpeg"},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("impossible d'ouvrir le sélecteur de fichier: %w", err)
+	}
+	if sourcePath == "" {
+		// Dialog cancelled
+		return "", nil
+	}
+	return a.layoutService.SetTemplateLogo(templateID, sourcePath)
+}
+
+// RemoveTemplateLogo removes the logo of a template
+func (a *App) RemoveTemplateLogo(templateID uint) error {
+	_, err := a.layoutService.SetTemplateLogo(templateID, "")
+	return err
+}
+
+// PreviewInvoiceTemplate renders an invoice (0: sample invoice) with an unsaved template and returns the PDF path
+func (a *App) PreviewInvoiceTemplate(invoiceID uint, tpl layout.Template) (string, error) {
+	return a.invoiceService.PreviewTemplate(invoiceID, tpl)
+}
+
+// ExportInvoices exports invoices as a ZIP of PDFs or a merged PDF, emitting "invoice:export-progress"
+// events with the number of invoices done, and returns the exported file path
+func (a *App) ExportInvoices(req invoice.BatchExportRequest) (string, error) {
+	return a.invoiceService.ExportBatch(req, func(progress invoice.BatchProgress) {
+		runtime.EventsEmit(a.ctx, "invoice:export-progress", progress)
+	})
+}
+
+// GetArchiveRoot returns the folder where invoice PDFs are archived
+func (a *App) GetArchiveRoot() string {
+	return a.invoiceService.ArchiveRoot()
+}
+
+// SelectArchiveRoot lets the user pick the folder where invoice PDFs are archived
+func (a *App) SelectArchiveRoot() (string, error) {
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Choisir le dossier d'archives des factures",
+		DefaultDirectory: a.invoiceService.ArchiveRoot(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("impossible d'ouvrir le sélecteur de dossier: %w", err)
+	}
+	if dir == "" {
+		// Dialog cancelled
+		return "", nil
+	}
+	if err := a.invoiceService.SetArchiveRoot(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// GetArchivedPDFs lists archived invoice PDFs for a year and month (0: all)
+func (a *App) GetArchivedPDFs(year int, month int) ([]invoice.ArchivedPDF, error) {
+	return a.invoiceService.GetArchivedPDFs(year, month)
+}
+
+// OpenArchivedPDF opens the archived original PDF of an invoice after checking its hash
+func (a *App) OpenArchivedPDF(invoiceID uint) error {
+	pdfPath, err := a.invoiceService.ArchivedPDFPath(invoiceID)
+	if err != nil {
+		return err
+	}
+	return a.OpenPDF(pdfPath)
+}
+
+// GetCompanyInfo returns the seller's identity printed on invoices
+func (a *App) GetCompanyInfo() settings.Company {
+	return a.settingsService.GetCompany(invoice.CompanyICE)
+}
+
+// SaveCompanyInfo saves the seller's identity
+func (a *App) SaveCompanyInfo(company settings.Company) error {
+	return a.settingsService.SaveCompany(company)
+}
+
+// ExportInvoiceUBL writes the invoice as UBL 2.1 XML next to its PDF and returns the file path
+func (a *App) ExportInvoiceUBL(invoiceID uint) (string, error) {
+	return a.invoiceService.ExportUBL(invoiceID)
+}
+
+// ExportInvoicePDFWithUBL generates the invoice PDF with its UBL XML embedded and returns the file path
+func (a *App) ExportInvoicePDFWithUBL(invoiceID uint) (string, error) {
+	return a.invoiceService.ExportPDFWithUBL(invoiceID)
+}
+
+// VerifyInvoiceQR checks a scanned invoice QR code against the invoices in the database
+func (a *App) VerifyInvoiceQR(payload string) (*invoice.QRVerification, error) {
+	return a.invoiceService.VerifyQRPayload(payload)
+}
+
+// VerifyInvoiceChain checks the hash chain of a year's invoices and reports the first broken link
+func (a *App) VerifyInvoiceChain(year int) (*invoice.ChainVerification, error) {
+	return a.invoiceService.VerifyInvoiceChain(year)
+}
+
+// MarkInvoicePaid records the payment date (DD-MM-YYYY, empty: today) of an invoice
+func (a *App) MarkInvoicePaid(id uint, paidOn string) error {
+	return a.invoiceService.MarkInvoicePaid(id, paidOn)
+}
+
+// MarkInvoiceUnpaid cancels the recorded payment of an invoice
+func (a *App) MarkInvoiceUnpaid(id uint) error {
+	return a.invoiceService.MarkInvoiceUnpaid(id)
+}
+
+// GetOverdueInvoices lists unpaid invoices past their due date, sorted by AMOUNT or AGE
+func (a *App) GetOverdueInvoices(sortBy string) ([]invoice.OverdueInvoice, error) {
+	return a.invoiceService.GetOverdueInvoices(sortBy)
+}
+
+// GetInvoiceReminders returns the reminder history of an invoice
+func (a *App) GetInvoiceReminders(invoiceID uint) ([]invoice.Reminder, error) {
+	return a.invoiceService.GetReminders(invoiceID)
+}
+
+// GenerateReminderPDF writes a reminder letter (level 0: next level) and records it in the history
+func (a *App) GenerateReminderPDF(invoiceID uint, level int) (*invoice.Reminder, error) {
+	return a.invoiceService.GenerateReminder(invoiceID, level)
+}
+
+// SendReminderByEmail emails a reminder letter (level 0: next level); an empty recipient uses the client's email
+func (a *App) SendReminderByEmail(invoiceID uint, level int, to string) (*invoice.Reminder, error) {
+	return a.mailerService.SendReminderByEmail(invoiceID, level, to)
+}
+
+// GetSMTPConfig returns the SMTP configuration, without the password
+func (a *App) GetSMTPConfig() (*mailer.SMTPConfig, error) {
+	return a.mailerService.GetConfig()
+}
+
+// SaveSMTPConfig saves the SMTP configuration; an empty password keeps the stored one
+func (a *App) SaveSMTPConfig(req mailer.SMTPConfigRequest) error {
+	return a.mailerService.SaveConfig(req)
+}
+
+// TestSMTPConfig sends a test message with the saved SMTP configuration
+func (a *App) TestSMTPConfig(to string) error {
+	return a.mailerService.SendTestEmail(to)
+}
+
+// GetInvoiceEmailDraft pre-fills the recipient, subject and message for sending an invoice
+func (a *App) GetInvoiceEmailDraft(invoiceID uint) (*mailer.EmailDraft, error) {
+	return a.mailerService.PrepareInvoiceEmail(invoiceID)
+}
+
+// SendInvoiceByEmail sends the invoice PDF by email and records the attempt in the sent log
+func (a *App) SendInvoiceByEmail(invoiceID uint, to string, subject string, body string) (*mailer.EmailLog, error) {
+	return a.mailerService.SendInvoiceByEmail(invoiceID, to, subject, body)
+}
+
+// GetInvoiceEmailLogs returns the emails sent for an invoice
+func (a *App) GetInvoiceEmailLogs(invoiceID uint) ([]mailer.EmailLog, error) {
+	return a.mailerService.GetEmailLogs(invoiceID)
+}
+
 // GetVersion returns the application version
 func (a *App) GetVersion() string {
 	return AppVersion
@@ -157,14 +466,14 @@ func (a *App) PrintPDF(pdfPath string) error {
 	return nil
 }
 
-// CalculateTotals calculates totals from TTC for live preview
-func (a *App) CalculateTotals(totalTTC float64) map[string]interface{} {
-	return a.invoiceService.CalculateTotals(totalTTC)
+// CalculateTotals calculates totals from a TTC or HT amount for live preview
+func (a *App) CalculateTotals(amount money.Amount, basis string, currencyCode string, tvaExempt bool, language string) map[string]interface{} {
+	return a.invoiceService.CalculateTotals(amount, basis, currencyCode, tvaExempt, language)
 }
 
-// GetTotalInWords converts amount to French words
-func (a *App) GetTotalInWords(amount float64) string {
-	return a.invoiceService.ConvertToWords(amount)
+// GetTotalInWords converts amount to words in the given currency and language (fr, en or ar)
+func (a *App) GetTotalInWords(amount money.Amount, currencyCode string, language string) string {
+	return a.invoiceService.ConvertToWords(amount, currencyCode, language)
 }
 
 // CreateProduct creates a new product
@@ -172,12 +481,17 @@ func (a *App) CreateProduct(product inventory.Product) (*inventory.Product, erro
 	return a.inventoryService.CreateProduct(product)
 }
 
-// GetAllProducts returns all products
-func (a *App) GetAllProducts() ([]inventory.Product, error) {
-	return a.inventoryService.GetAllProducts()
+// GetAllProducts returns the products matching the filter (an empty filter returns every product)
+func (a *App) GetAllProducts(filter inventory.ProductFilter) ([]inventory.Product, error) {
+	return a.inventoryService.GetAllProducts(filter)
+}
+
+// CreateProductVariant creates a size/colour variant of a product
+func (a *App) CreateProductVariant(parentID uint, variant inventory.Product) (*inventory.Product, error) {
+	return a.inventoryService.CreateVariant(parentID, variant)
 }
 
-// UpdateProduct updates an existing product
+// UpdateProduct updates an existing product; its stock is corrected through a stock-take
 func (a *App) UpdateProduct(product inventory.Product) error {
 	return a.inventoryService.UpdateProduct(product)
 }
@@ -186,6 +500,204 @@ func (a *App) DeleteProduct(id uint) error {
 	return a.inventoryService.DeleteProduct(id)
 }
 
+// FindProductByBarcode returns the product matching a scanned barcode or reference
+func (a *App) FindProductByBarcode(code string) (*inventory.Product, error) {
+	return a.inventoryService.GetProductByCode(code)
+}
+
+// AddProductBarcode attaches a barcode to a product
+func (a *App) AddProductBarcode(productID uint, code string) (*inventory.Barcode, error) {
+	return a.inventoryService.AddBarcode(productID, code)
+}
+
+// DeleteProductBarcode removes a barcode from a product
+func (a *App) DeleteProductBarcode(id uint) error {
+	return a.inventoryService.DeleteBarcode(id)
+}
+
+// GenerateProductBarcode assigns an internal EAN-13 to a product
+func (a *App) GenerateProductBarcode(productID uint) (*inventory.Barcode, error) {
+	return a.inventoryService.GenerateBarcode(productID)
+}
+
+// GenerateMissingBarcodes assigns an internal EAN-13 to every product without a barcode
+func (a *App) GenerateMissingBarcodes() (int, error) {
+	return a.inventoryService.GenerateMissingBarcodes()
+}
+
+// SelectProductImage lets the user pick an image file and attaches it to the product
+func (a *App) SelectProductImage(productID uint) (string, error) {
+	sourcePath, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Choisir une image",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Images (*.png, *.jpg, *.webp)", Pattern: "*.png;*.jpg;*.jpeg;*.webp"},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("impossible d'ouvrir le sélecteur de fichier: %w", err)
+	}
+	if sourcePath == "" {
+		// Dialog cancelled
+		return "", nil
+	}
+	return a.inventoryService.SetProductImage(productID, sourcePath)
+}
+
+// RemoveProductImage detaches the image of a product
+func (a *App) RemoveProductImage(productID uint) error {
+	return a.inventoryService.RemoveProductImage(productID)
+}
+
+// GetProductImage returns the product image as a data URI
+func (a *App) GetProductImage(productID uint) (string, error) {
+	return a.inventoryService.GetProductImageData(productID)
+}
+
+// GetCategoryTree returns the product category hierarchy
+func (a *App) GetCategoryTree() ([]inventory.CategoryNode, error) {
+	return a.inventoryService.GetCategoryTree()
+}
+
+// CreateCategory creates a product category (parentID nil for a root category)
+func (a *App) CreateCategory(name string, parentID *uint) (*inventory.Category, error) {
+	return a.inventoryService.CreateCategory(name, parentID)
+}
+
+// UpdateCategory renames or moves a product category
+func (a *App) UpdateCategory(category inventory.Category) error {
+	return a.inventoryService.UpdateCategory(category)
+}
+
+// DeleteCategory deletes an empty product category
+func (a *App) DeleteCategory(id uint) error {
+	return a.inventoryService.DeleteCategory(id)
+}
+
+// GenerateShelfLabels generates a PDF sheet of shelf labels and returns the file path
+func (a *App) GenerateShelfLabels(productIDs []uint) (string, error) {
+	return a.inventoryService.GenerateShelfLabels(productIDs)
+}
+
+// StartStockTake opens a physical count session at a location, freezing the stock of all products or of a category
+func (a *App) StartStockTake(name string, categoryID *uint, locationID uint) (*inventory.StockTake, error) {
+	return a.inventoryService.StartStockTake(name, categoryID, locationID)
+}
+
+// GetStockTakes returns the physical count sessions
+func (a *App) GetStockTakes() ([]inventory.StockTake, error) {
+	return a.inventoryService.GetStockTakes()
+}
+
+// GetStockTake returns a count session with its lines
+func (a *App) GetStockTake(id uint) (*inventory.StockTake, error) {
+	return a.inventoryService.GetStockTake(id)
+}
+
+// ScanStockCount adds a counted quantity to the product matching a scanned code
+func (a *App) ScanStockCount(id uint, code string, quantity int) (*inventory.StockTakeLine, error) {
+	return a.inventoryService.ScanCount(id, code, quantity)
+}
+
+// SetStockCounts records counted quantities in bulk
+func (a *App) SetStockCounts(id uint, counts []inventory.StockCount) error {
+	return a.inventoryService.SetCounts(id, counts)
+}
+
+// GetStockTakeReport returns the variances of a count session with their value
+func (a *App) GetStockTakeReport(id uint) (*inventory.StockTakeReport, error) {
+	return a.inventoryService.GetStockTakeReport(id)
+}
+
+// PostStockTake adjusts the stock from a count session and closes it
+func (a *App) PostStockTake(id uint) (*inventory.StockTakeReport, error) {
+	return a.inventoryService.PostStockTake(id)
+}
+
+// CancelStockTake abandons a count session without touching the stock
+func (a *App) CancelStockTake(id uint) error {
+	return a.inventoryService.CancelStockTake(id)
+}
+
+// GetStockMovements returns the recorded stock movements of a product (0: all products)
+func (a *App) GetStockMovements(productID uint) ([]inventory.StockMovement, error) {
+	return a.inventoryService.GetStockMovements(productID)
+}
+
+// GenerateCountSheet generates the printable count sheet of a session and returns the file path
+func (a *App) GenerateCountSheet(id uint) (string, error) {
+	return a.inventoryService.GenerateCountSheet(id)
+}
+
+// GenerateVarianceReport generates the variance report PDF of a session and returns the file path
+func (a *App) GenerateVarianceReport(id uint) (string, error) {
+	return a.inventoryService.GenerateVarianceReport(id)
+}
+
+// GetLocations returns the stock locations
+func (a *App) GetLocations() ([]inventory.Location, error) {
+	return a.inventoryService.GetLocations()
+}
+
+// CreateLocation adds a stock location
+func (a *App) CreateLocation(name string) (*inventory.Location, error) {
+	return a.inventoryService.CreateLocation(name)
+}
+
+// RenameLocation changes the name of a stock location
+func (a *App) RenameLocation(id uint, name string) error {
+	return a.inventoryService.RenameLocation(id, name)
+}
+
+// SetDefaultLocation sets the location used by sales that do not choose one
+func (a *App) SetDefaultLocation(id uint) error {
+	return a.inventoryService.SetDefaultLocation(id)
+}
+
+// DeleteLocation removes an empty stock location
+func (a *App) DeleteLocation(id uint) error {
+	return a.inventoryService.DeleteLocation(id)
+}
+
+// GetProductLocations returns the stock of a product at each location
+func (a *App) GetProductLocations(productID uint) ([]inventory.ProductLocationStock, error) {
+	return a.inventoryService.GetProductLocations(productID)
+}
+
+// SetLocationMinStock sets the alert threshold of a product at a location
+func (a *App) SetLocationMinStock(productID, locationID uint, level int) error {
+	return a.inventoryService.SetLocationMinStock(productID, locationID, level)
+}
+
+// TransferStock moves units of a product between two locations
+func (a *App) TransferStock(productID, fromID, toID uint, quantity int, note string) (*inventory.StockTransfer, error) {
+	return a.inventoryService.TransferStock(productID, fromID, toID, quantity, note)
+}
+
+// GetStockTransfers returns the transfers of a product (0: all products)
+func (a *App) GetStockTransfers(productID uint) ([]inventory.StockTransfer, error) {
+	return a.inventoryService.GetStockTransfers(productID)
+}
+
+// GetLowStock returns the low-stock alerts, globally (0) or at a location
+func (a *App) GetLowStock(locationID uint) ([]inventory.LowStockAlert, error) {
+	return a.inventoryService.GetLowStock(locationID)
+}
+
+// ReceiveLot receives a quantity of a product into a lot at a location (0: the default one)
+func (a *App) ReceiveLot(productID, locationID uint, lotNumber, expiryDate string, quantity int) (*inventory.Lot, error) {
+	return a.inventoryService.ReceiveLot(productID, locationID, lotNumber, expiryDate, quantity)
+}
+
+// GetLots returns the lots of a product still in stock (0: every product)
+func (a *App) GetLots(productID uint) ([]inventory.Lot, error) {
+	return a.inventoryService.GetLots(productID)
+}
+
+// GetExpiringLots returns the lots expired or expiring within the given number of days
+func (a *App) GetExpiringLots(days int) ([]inventory.ExpiringLot, error) {
+	return a.inventoryService.GetExpiringLots(days)
+}
+
 type DashboardStats struct {
 	InvoiceStats   *invoice.InvoiceStats
 	InventoryStats *inventory.InventoryStats
@@ -208,6 +720,46 @@ func (a *App) GetDashboardStats(year int) (*DashboardStats, error) {
 	}, nil
 }
 
+// GetAllPriceLists returns every price list
+func (a *App) GetAllPriceLists() ([]pricing.PriceList, error) {
+	return a.pricingService.GetAllPriceLists()
+}
+
+// CreatePriceList creates a new price list
+func (a *App) CreatePriceList(list pricing.PriceList) (*pricing.PriceList, error) {
+	return a.pricingService.CreatePriceList(list)
+}
+
+// UpdatePriceList updates a price list
+func (a *App) UpdatePriceList(list pricing.PriceList) error {
+	return a.pricingService.UpdatePriceList(list)
+}
+
+// DeletePriceList deletes a price list not assigned to any client
+func (a *App) DeletePriceList(id uint) error {
+	return a.pricingService.DeletePriceList(id)
+}
+
+// GetPriceListEntries returns the prices of a list (productID 0 for all products)
+func (a *App) GetPriceListEntries(priceListID uint, productID uint) ([]pricing.PriceListEntry, error) {
+	return a.pricingService.GetPriceListEntries(priceListID, productID)
+}
+
+// SavePriceListEntry creates or updates a product price (with quantity break) in a list
+func (a *App) SavePriceListEntry(entry pricing.PriceListEntry) (*pricing.PriceListEntry, error) {
+	return a.pricingService.SavePriceListEntry(entry)
+}
+
+// DeletePriceListEntry removes a product price from a list
+func (a *App) DeletePriceListEntry(id uint) error {
+	return a.pricingService.DeletePriceListEntry(id)
+}
+
+// ResolvePrice returns the applicable unit price for a product, client and quantity
+func (a *App) ResolvePrice(productID uint, clientICE string, quantity float64) (*pricing.PriceQuote, error) {
+	return a.pricingService.ResolvePrice(productID, clientICE, quantity)
+}
+
 // CreateClient creates a new client
 func (a *App) CreateClient(c client.Client) error {
 	return a.clientService.CreateClient(c)
@@ -232,3 +784,139 @@ func (a *App) GetAllClients() ([]client.Client, error) {
 func (a *App) SearchClients(query string) ([]client.Client, error) {
 	return a.clientService.SearchClients(query)
 }
+
+// OpenCashSession opens the cash register with an opening float
+func (a *App) OpenCashSession(openingFloat money.Amount) (*pos.CashSession, error) {
+	return a.posService.OpenSession(openingFloat)
+}
+
+// GetCurrentCashSession returns the open cash session, if any
+func (a *App) GetCurrentCashSession() (*pos.CashSession, error) {
+	return a.posService.GetCurrentSession()
+}
+
+// ScanProduct resolves a scanned code into a POS cart line
+func (a *App) ScanProduct(code string) (*pos.CartLine, error) {
+	return a.posService.ScanProduct(code)
+}
+
+// Checkout records a counter sale and returns its ticket
+func (a *App) Checkout(req pos.CheckoutRequest) (*pos.Ticket, error) {
+	return a.posService.Checkout(req)
+}
+
+// CloseCashSession closes the cash register and returns the Z-report
+func (a *App) CloseCashSession(sessionID uint, closingCount money.Amount) (*pos.ZReport, error) {
+	return a.posService.CloseSession(sessionID, closingCount)
+}
+
+// GetZReport returns the Z-report of a session (X-report while still open)
+func (a *App) GetZReport(sessionID uint) (*pos.ZReport, error) {
+	return a.posService.GetZReport(sessionID)
+}
+
+// GenerateReceiptPDF generates a narrow PDF receipt (58 or 80 mm) and returns the file path
+func (a *App) GenerateReceiptPDF(ticketID uint, paperWidthMM int) (string, error) {
+	return a.posService.GenerateReceiptPDF(ticketID, paperWidthMM)
+}
+
+// PrintReceipt sends a ticket to the thermal printer as raw ESC/POS
+func (a *App) PrintReceipt(ticketID uint, paperWidthMM int) error {
+	if goruntime.GOOS == "windows" {
+		// Windows has no raw spooler command: print the narrow PDF instead
+		pdfPath, err := a.posService.GenerateReceiptPDF(ticketID, paperWidthMM)
+		if err != nil {
+			return err
+		}
+		return a.PrintPDF(pdfPath)
+	}
+
+	binPath, err := a.posService.WriteReceiptEscPos(ticketID, paperWidthMM)
+	if err != nil {
+		return err
+	}
+	return printRaw(binPath, "le ticket")
+}
+
+// PrintZReport sends the Z-report of a cash session (X-report while open) to the thermal printer as raw ESC/POS
+func (a *App) PrintZReport(sessionID uint, paperWidthMM int) error {
+	if goruntime.GOOS == "windows" {
+		// Windows has no raw spooler command: print the narrow PDF instead
+		pdfPath, err := a.posService.GenerateZReportPDF(sessionID, paperWidthMM)
+		if err != nil {
+			return err
+		}
+		return a.PrintPDF(pdfPath)
+	}
+
+	binPath, err := a.posService.WriteZReportEscPos(sessionID, paperWidthMM)
+	if err != nil {
+		return err
+	}
+	return printRaw(binPath, "le rapport de caisse")
+}
+
+// printRaw sends an ESC/POS file to the default printer.
+// lpr -o raw bypasses the CUPS filters so ESC/POS commands reach the printer untouched.
+func printRaw(binPath, document string) error {
+	cmd := exec.Command("lpr", "-o", "raw", binPath)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("impossible d'imprimer %s: vérifiez que l'imprimante thermique est configurée (CUPS)", document)
+	}
+	return nil
+}
+
+// GetCurrencies returns the supported invoice currencies
+func (a *App) GetCurrencies() []currency.Info {
+	return a.currencyService.GetCurrencies()
+}
+
+// SaveExchangeRate records the MAD value of a currency for a day (DD-MM-YYYY)
+func (a *App) SaveExchangeRate(code string, date string, rate float64, source string) (*currency.ExchangeRate, error) {
+	return a.currencyService.SaveRate(code, date, rate, source)
+}
+
+// GetExchangeRates returns the recorded rates of a currency
+func (a *App) GetExchangeRates(code string) ([]currency.ExchangeRate, error) {
+	return a.currencyService.GetRates(code)
+}
+
+// DeleteExchangeRate removes a recorded exchange rate
+func (a *App) DeleteExchangeRate(id uint) error {
+	return a.currencyService.DeleteRate(id)
+}
+
+// CreateRecurringSchedule creates a recurring invoice schedule for a client
+func (a *App) CreateRecurringSchedule(req recurring.ScheduleRequest) (*recurring.Schedule, error) {
+	return a.recurringService.CreateSchedule(req)
+}
+
+// UpdateRecurringSchedule replaces the settings and lines of a recurring invoice schedule
+func (a *App) UpdateRecurringSchedule(id uint, req recurring.ScheduleRequest) (*recurring.Schedule, error) {
+	return a.recurringService.UpdateSchedule(id, req)
+}
+
+// DeleteRecurringSchedule deletes a recurring invoice schedule, keeping its invoices
+func (a *App) DeleteRecurringSchedule(id uint) error {
+	return a.recurringService.DeleteSchedule(id)
+}
+
+// GetRecurringSchedules returns the recurring schedules of a client (0: all clients)
+func (a *App) GetRecurringSchedules(clientID uint) ([]recurring.Schedule, error) {
+	return a.recurringService.GetSchedules(clientID)
+}
+
+// GetBilledPeriods returns the periods already invoiced for a recurring schedule
+func (a *App) GetBilledPeriods(scheduleID uint) ([]recurring.BilledPeriod, error) {
+	return a.recurringService.GetBilledPeriods(scheduleID)
+}
+
+// ReleaseBilledPeriod frees a period left claimed without an invoice by an interrupted generation
+func (a *App) ReleaseBilledPeriod(scheduleID uint, period string) error {
+	return a.recurringService.ReleasePeriod(scheduleID, period)
+}
+
+// GenerateRecurringInvoices bills the recurring invoices due up to today
+func (a *App) GenerateRecurringInvoices() (*recurring.GenerationReport, error) {
+	return a.recurringService.GenerateDueInvoices()
+}
@@ -13,4 +13,12 @@ type Client struct {
 	Address string `json:"address"`
 	Phone   string `json:"phone"`
 	Email   string `json:"email"`
+
+	PriceListID  *uint  `json:"priceListId"`  // Tariff applied to this client (nil: default list)
+	PricingBasis string `json:"pricingBasis"` // Default invoice pricing basis: TTC or HT (empty: TTC)
+	Currency     string `json:"currency"`     // Default invoice currency (empty: MAD)
+	TVAExempt    bool   `json:"tvaExempt"`    // Export client invoiced without TVA
+	Language     string `json:"language"`     // Default invoice language: fr, en, ar or fr-ar (empty: fr)
+
+	PaymentTermsDays int `json:"paymentTermsDays"` // Days between the invoice date and its due date (0: 30 days)
 }
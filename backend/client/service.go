@@ -1,7 +1,9 @@
 package client
 
 import (
+	"factureapp/backend/currency"
 	"factureapp/backend/database"
+	"factureapp/backend/locale"
 	"fmt"
 	"strings"
 )
@@ -40,6 +42,9 @@ func (s *Service) CreateClient(client Client) error {
 	if len(client.City) == 0 {
 		return fmt.Errorf("la ville est obligatoire")
 	}
+	if err := normalizeInvoiceDefaults(&client); err != nil {
+		return err
+	}
 
 	db := database.GetDB()
 	if err := db.Create(&client).Error; err != nil {
@@ -65,6 +70,9 @@ func (s *Service) UpdateClient(client Client) error {
 	if len(client.City) == 0 {
 		return fmt.Errorf("la ville est obligatoire")
 	}
+	if err := normalizeInvoiceDefaults(&client); err != nil {
+		return err
+	}
 
 	db := database.GetDB()
 	if err := db.Save(&client).Error; err != nil {
@@ -113,3 +121,34 @@ func (s *Service) SearchClients(query string) ([]Client, error) {
 	}
 	return clients, nil
 }
+
+// normalizeInvoiceDefaults validates the client's default invoice pricing basis (TTC or HT), currency and language
+func normalizeInvoiceDefaults(client *Client) error {
+	client.PricingBasis = strings.ToUpper(strings.TrimSpace(client.PricingBasis))
+	switch client.PricingBasis {
+	case "", "TTC", "HT":
+	default:
+		return fmt.Errorf("base de prix inconnue: %s (TTC ou HT attendu)", client.PricingBasis)
+	}
+
+	if strings.TrimSpace(client.Currency) != "" {
+		info, err := currency.Lookup(client.Currency)
+		if err != nil {
+			return err
+		}
+		client.Currency = info.Code
+	}
+
+	if strings.TrimSpace(client.Language) != "" {
+		language, err := locale.Normalize(client.Language)
+		if err != nil {
+			return err
+		}
+		client.Language = language
+	}
+
+	if client.PaymentTermsDays < 0 || client.PaymentTermsDays > 365 {
+		return fmt.Errorf("le délai de paiement doit être compris entre 0 et 365 jours")
+	}
+	return nil
+}
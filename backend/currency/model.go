@@ -0,0 +1,31 @@
+package currency
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Supported invoice currencies (ISO 4217 codes)
+const (
+	MAD = "MAD" // Moroccan dirham, the accounting currency
+	EUR = "EUR"
+	USD = "USD"
+)
+
+// Info describes how a currency is printed and spelled out on invoices
+type Info struct {
+	Code    string `json:"code"`
+	Symbol  string `json:"symbol"`  // Printed after amounts, e.g. "DH"
+	Unit    string `json:"unit"`    // Plural name of the main unit, e.g. "dirhams"
+	Subunit string `json:"subunit"` // Plural name of the subunit, e.g. "centimes"
+}
+
+// ExchangeRate is the value of one unit of a foreign currency in MAD on a given day
+type ExchangeRate struct {
+	gorm.Model
+	Currency string    `gorm:"size:3;uniqueIndex:idx_rate_day" json:"currency"`
+	Date     time.Time `gorm:"uniqueIndex:idx_rate_day" json:"date"`
+	Rate     float64   `json:"rate"` // MAD for 1 unit of Currency
+	Source   string    `json:"source"`
+}
@@ -0,0 +1,134 @@
+package currency
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+)
+
+// currencies lists the supported invoice currencies
+var currencies = map[string]Info{
+	MAD: {Code: MAD, Symbol: "DH", Unit: "dirhams", Subunit: "centimes"},
+	EUR: {Code: EUR, Symbol: "EUR", Unit: "euros", Subunit: "centimes"},
+	USD: {Code: USD, Symbol: "USD", Unit: "dollars", Subunit: "cents"},
+}
+
+// Lookup returns the description of a currency; an empty code means MAD
+func Lookup(code string) (Info, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = MAD
+	}
+	info, ok := currencies[code]
+	if !ok {
+		return Info{}, fmt.Errorf("devise non prise en charge: %s (MAD, EUR ou USD attendu)", code)
+	}
+	return info, nil
+}
+
+// Symbol returns the printed symbol of a currency, falling back to the code itself
+func Symbol(code string) string {
+	info, err := Lookup(code)
+	if err != nil {
+		return code
+	}
+	return info.Symbol
+}
+
+// Service handles exchange rates
+type Service struct{}
+
+// NewService creates a new currency service
+func NewService() *Service {
+	return &Service{}
+}
+
+// Migrate runs database migrations for exchange rates
+func (s *Service) Migrate() error {
+	db := database.GetDB()
+	return db.AutoMigrate(&ExchangeRate{})
+}
+
+// GetCurrencies returns the supported currencies, MAD first
+func (s *Service) GetCurrencies() []Info {
+	return []Info{currencies[MAD], currencies[EUR], currencies[USD]}
+}
+
+// SaveRate records the MAD value of a foreign currency for a day (DD-MM-YYYY), replacing that day's rate
+func (s *Service) SaveRate(code string, date string, rate float64, source string) (*ExchangeRate, error) {
+	info, err := Lookup(code)
+	if err != nil {
+		return nil, err
+	}
+	if info.Code == MAD {
+		return nil, fmt.Errorf("le dirham est la devise de référence: aucun taux à saisir")
+	}
+	if rate <= 0 {
+		return nil, fmt.Errorf("le taux de change doit être supérieur à 0")
+	}
+	day, err := time.Parse("02-01-2006", date)
+	if err != nil {
+		return nil, fmt.Errorf("format de date invalide, JJ-MM-AAAA attendu: %w", err)
+	}
+
+	db := database.GetDB()
+
+	var existing ExchangeRate
+	db.Where("currency = ? AND date = ?", info.Code, day).Limit(1).Find(&existing)
+
+	existing.Currency = info.Code
+	existing.Date = day
+	existing.Rate = rate
+	existing.Source = strings.TrimSpace(source)
+	if err := db.Save(&existing).Error; err != nil {
+		return nil, fmt.Errorf("échec de l'enregistrement du taux de change: %w", err)
+	}
+	return &existing, nil
+}
+
+// GetRates returns the recorded rates of a currency, most recent first
+func (s *Service) GetRates(code string) ([]ExchangeRate, error) {
+	info, err := Lookup(code)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	var rates []ExchangeRate
+	if err := db.Where("currency = ?", info.Code).Order("date DESC").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// DeleteRate removes a recorded rate
+func (s *Service) DeleteRate(id uint) error {
+	db := database.GetDB()
+	if err := db.Delete(&ExchangeRate{}, id).Error; err != nil {
+		return fmt.Errorf("échec de la suppression du taux de change: %w", err)
+	}
+	return nil
+}
+
+// RateAt returns the MAD value of one unit of the currency on a date: the latest rate recorded on or before that day
+func (s *Service) RateAt(code string, date time.Time) (float64, error) {
+	info, err := Lookup(code)
+	if err != nil {
+		return 0, err
+	}
+	if info.Code == MAD {
+		return 1, nil
+	}
+
+	db := database.GetDB()
+	var rates []ExchangeRate
+	if err := db.Where("currency = ? AND date <= ?", info.Code, date).Order("date DESC").Limit(1).Find(&rates).Error; err != nil {
+		return 0, fmt.Errorf("échec de la lecture du taux de change: %w", err)
+	}
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("aucun taux de change %s/MAD enregistré au %s: saisissez le taux de la facture", info.Code, date.Format("02-01-2006"))
+	}
+	return rates[0].Rate, nil
+}
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -43,3 +45,43 @@ func InitDatabase() error {
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// SchemaMigration records a one-off data migration that has been applied
+type SchemaMigration struct {
+	Name      string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+// RunOnce runs a data migration inside a transaction, unless a migration with this name was already applied
+func RunOnce(name string, migrate func(tx *gorm.DB) error) error {
+	db := GetDB()
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(&SchemaMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := migrate(tx); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+		return tx.Create(&SchemaMigration{Name: name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// ConvertToCentimes rewrites REAL amount columns holding DH values as integer centimes
+func ConvertToCentimes(tx *gorm.DB, table string, columns ...string) error {
+	for _, column := range columns {
+		sql := fmt.Sprintf("UPDATE %s SET %s = CAST(ROUND(%s * 100) AS INTEGER) WHERE %s IS NOT NULL", table, column, column, column)
+		if err := tx.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InternalEANPrefix is the GS1 "in-store" prefix used for generated EAN-13 codes (20-29 are reserved for restricted circulation)
+const InternalEANPrefix = "20"
+
+// EAN13CheckDigit computes the check digit for the first 12 digits of an EAN-13
+func EAN13CheckDigit(digits string) (int, error) {
+	if len(digits) != 12 || !isDigits(digits) {
+		return 0, fmt.Errorf("un EAN-13 sans clé doit contenir exactement 12 chiffres")
+	}
+
+	sum := 0
+	for i, r := range digits {
+		d := int(r - '0')
+		// Weights alternate 1, 3 starting from the left
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10, nil
+}
+
+// NormalizeBarcode validates a code and returns it trimmed with its symbology.
+// 13-digit codes must carry a valid EAN-13 check digit; anything else printable is treated as Code128.
+func NormalizeBarcode(code string) (string, string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 0 {
+		return "", "", fmt.Errorf("le code-barres est vide")
+	}
+
+	if len(code) == 13 && isDigits(code) {
+		check, _ := EAN13CheckDigit(code[:12])
+		if int(code[12]-'0') != check {
+			return "", "", fmt.Errorf("code EAN-13 invalide '%s': clé de contrôle incorrecte (attendu %d)", code, check)
+		}
+		return code, SymbologyEAN13, nil
+	}
+
+	if len(code) > 48 {
+		return "", "", fmt.Errorf("code-barres trop long (%d caractères, maximum 48)", len(code))
+	}
+	for _, r := range code {
+		// Code128 only encodes printable ASCII
+		if r < 32 || r > 126 {
+			return "", "", fmt.Errorf("le code-barres '%s' contient des caractères non supportés", code)
+		}
+	}
+	return code, SymbologyCode128, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
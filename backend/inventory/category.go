@@ -0,0 +1,267 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// migrateLegacyCategories turns the former free-text categories into managed root categories
+func (s *Service) migrateLegacyCategories() error {
+	db := database.GetDB()
+
+	var names []string
+	if err := db.Model(&Product{}).
+		Where("category_id IS NULL AND category <> ''").
+		Distinct("category").
+		Pluck("category", &names).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range names {
+			trimmed := strings.TrimSpace(name)
+			if trimmed == "" {
+				continue
+			}
+
+			var category Category
+			if err := tx.Where("name = ? AND parent_id IS NULL", trimmed).FirstOrCreate(&category, Category{Name: trimmed}).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(&Product{}).
+				Where("category_id IS NULL AND category = ?", name).
+				Updates(map[string]interface{}{"category_id": category.ID, "category": category.Name}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateCategory creates a category, optionally under a parent
+func (s *Service) CreateCategory(name string, parentID *uint) (*Category, error) {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("le nom de la catégorie est obligatoire")
+	}
+
+	db := database.GetDB()
+
+	if parentID != nil {
+		var parent Category
+		if err := db.First(&parent, *parentID).Error; err != nil {
+			return nil, fmt.Errorf("catégorie parente introuvable: %w", err)
+		}
+	}
+
+	if err := s.checkSiblingName(db, name, parentID, 0); err != nil {
+		return nil, err
+	}
+
+	category := Category{Name: name, ParentID: parentID}
+	if err := db.Create(&category).Error; err != nil {
+		return nil, fmt.Errorf("échec de la création de la catégorie: %w", err)
+	}
+	return &category, nil
+}
+
+// UpdateCategory renames or moves a category
+func (s *Service) UpdateCategory(category Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if len(category.Name) == 0 {
+		return fmt.Errorf("le nom de la catégorie est obligatoire")
+	}
+
+	db := database.GetDB()
+
+	var existing Category
+	if err := db.First(&existing, category.ID).Error; err != nil {
+		return fmt.Errorf("catégorie introuvable: %w", err)
+	}
+
+	// A category cannot be moved under a missing category, itself or one of its descendants
+	if category.ParentID != nil {
+		var parent Category
+		if err := db.First(&parent, *category.ParentID).Error; err != nil {
+			return fmt.Errorf("catégorie parente introuvable: %w", err)
+		}
+		descendants, err := s.categoryWithDescendants(db, category.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range descendants {
+			if id == *category.ParentID {
+				return fmt.Errorf("une catégorie ne peut pas être déplacée sous elle-même ou sous une de ses sous-catégories")
+			}
+		}
+	}
+
+	if err := s.checkSiblingName(db, category.Name, category.ParentID, category.ID); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existing).Updates(map[string]interface{}{
+			"name":      category.Name,
+			"parent_id": category.ParentID,
+		}).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour de la catégorie: %w", err)
+		}
+
+		// Keep the denormalized name on products in sync
+		if err := tx.Model(&Product{}).Where("category_id = ?", category.ID).Update("category", category.Name).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour des produits de la catégorie: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteCategory soft-deletes an empty category
+func (s *Service) DeleteCategory(id uint) error {
+	db := database.GetDB()
+
+	var childCount int64
+	if err := db.Model(&Category{}).Where("parent_id = ?", id).Count(&childCount).Error; err != nil {
+		return fmt.Errorf("échec de la vérification d'utilisation: %w", err)
+	}
+	if childCount > 0 {
+		return fmt.Errorf("impossible de supprimer cette catégorie car elle contient %d sous-catégorie(s)", childCount)
+	}
+
+	var productCount int64
+	if err := db.Model(&Product{}).Where("category_id = ?", id).Count(&productCount).Error; err != nil {
+		return fmt.Errorf("échec de la vérification d'utilisation: %w", err)
+	}
+	if productCount > 0 {
+		return fmt.Errorf("impossible de supprimer cette catégorie car elle contient %d produit(s)", productCount)
+	}
+
+	if err := db.Delete(&Category{}, id).Error; err != nil {
+		return fmt.Errorf("échec de la suppression de la catégorie: %w", err)
+	}
+	return nil
+}
+
+// GetCategoryTree returns the category hierarchy with direct product counts
+func (s *Service) GetCategoryTree() ([]CategoryNode, error) {
+	db := database.GetDB()
+
+	var categories []Category
+	if err := db.Order("name ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		CategoryID uint
+		Total      int64
+	}
+	if err := db.Model(&Product{}).
+		Select("category_id, count(id) as total").
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countMap := make(map[uint]int64)
+	for _, c := range counts {
+		countMap[c.CategoryID] = c.Total
+	}
+
+	children := make(map[uint][]Category)
+	var roots []Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func(c Category) CategoryNode
+	build = func(c Category) CategoryNode {
+		node := CategoryNode{
+			ID:           c.ID,
+			Name:         c.Name,
+			ParentID:     c.ParentID,
+			ProductCount: countMap[c.ID],
+			Children:     []CategoryNode{},
+		}
+		for _, child := range children[c.ID] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+
+	tree := []CategoryNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+	return tree, nil
+}
+
+// categoryWithDescendants returns the ID of a category and of all its sub-categories
+func (s *Service) categoryWithDescendants(db *gorm.DB, id uint) ([]uint, error) {
+	ids := []uint{id}
+	frontier := []uint{id}
+	for len(frontier) > 0 {
+		var next []uint
+		if err := db.Model(&Category{}).Where("parent_id IN ?", frontier).Pluck("id", &next).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, next...)
+		frontier = next
+	}
+	return ids, nil
+}
+
+// checkSiblingName rejects two categories with the same name under the same parent
+func (s *Service) checkSiblingName(db *gorm.DB, name string, parentID *uint, excludeID uint) error {
+	query := db.Model(&Category{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, excludeID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("échec de la vérification de la catégorie: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("une catégorie '%s' existe déjà à cet emplacement", name)
+	}
+	return nil
+}
+
+// resolveCategory validates the product's category and fills in its display name.
+// A bare category name without ID is matched (or created) as a root category.
+func (s *Service) resolveCategory(db *gorm.DB, product *Product) error {
+	if product.CategoryID == nil || *product.CategoryID == 0 {
+		product.CategoryID = nil
+		name := strings.TrimSpace(product.Category)
+		if name == "" {
+			product.Category = ""
+			return nil
+		}
+
+		var category Category
+		if err := db.Where("LOWER(name) = LOWER(?) AND parent_id IS NULL", name).FirstOrCreate(&category, Category{Name: name}).Error; err != nil {
+			return fmt.Errorf("échec de la résolution de la catégorie: %w", err)
+		}
+		product.CategoryID = &category.ID
+		product.Category = category.Name
+		return nil
+	}
+
+	var category Category
+	if err := db.First(&category, *product.CategoryID).Error; err != nil {
+		return fmt.Errorf("catégorie introuvable: %w", err)
+	}
+	product.Category = category.Name
+	return nil
+}
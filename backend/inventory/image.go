@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// maxImageSize is the largest product image accepted (5 MB)
+const maxImageSize = 5 << 20
+
+// imageMimeTypes lists the accepted image extensions and their MIME types
+var imageMimeTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+}
+
+// SetProductImage copies an image file into the app data folder and attaches it to the product
+func (s *Service) SetProductImage(productID uint, sourcePath string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(sourcePath))
+	if _, ok := imageMimeTypes[ext]; !ok {
+		return "", fmt.Errorf("format d'image non supporté '%s' (PNG, JPG ou WEBP attendu)", ext)
+	}
+
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("l'image n'existe pas: %s", sourcePath)
+	}
+	if info.Size() > maxImageSize {
+		return "", fmt.Errorf("image trop volumineuse (%d Ko, maximum %d Ko)", info.Size()/1024, maxImageSize/1024)
+	}
+
+	db := database.GetDB()
+
+	var product Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return "", fmt.Errorf("produit introuvable: %w", err)
+	}
+
+	imageDir, err := productImageDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Each upload gets its own file, named after its content: variants keep pointing to the parent's
+	// previous picture until they are given another one
+	hash, err := fileHash(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("impossible de lire l'image: %w", err)
+	}
+	destPath := filepath.Join(imageDir, fmt.Sprintf("product_%d_%s%s", product.ID, hash, ext))
+	if err := copyFile(sourcePath, destPath); err != nil {
+		return "", fmt.Errorf("impossible de copier l'image (%s): vérifiez les permissions et l'espace disque disponible", destPath)
+	}
+
+	// Remove the previous image, unless a variant or its parent still uses it
+	if product.ImagePath != "" && product.ImagePath != destPath && strings.HasPrefix(product.ImagePath, imageDir) {
+		removeUnusedImage(db, product.ImagePath, product.ID)
+	}
+
+	if err := db.Model(&product).Update("image_path", destPath).Error; err != nil {
+		return "", fmt.Errorf("échec de la mise à jour du produit: %w", err)
+	}
+	return destPath, nil
+}
+
+// RemoveProductImage detaches and deletes the product's image
+func (s *Service) RemoveProductImage(productID uint) error {
+	db := database.GetDB()
+
+	var product Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return fmt.Errorf("produit introuvable: %w", err)
+	}
+	if product.ImagePath == "" {
+		return nil
+	}
+
+	removeUnusedImage(db, product.ImagePath, product.ID)
+
+	if err := db.Model(&product).Update("image_path", "").Error; err != nil {
+		return fmt.Errorf("échec de la mise à jour du produit: %w", err)
+	}
+	return nil
+}
+
+// removeUnusedImage deletes an image file a product no longer uses. Variants may share their parent's
+// image: the file is only deleted when no other product uses it.
+func removeUnusedImage(db *gorm.DB, path string, productID uint) {
+	var users int64
+	db.Model(&Product{}).Where("image_path = ? AND id <> ?", path, productID).Count(&users)
+	if users == 0 {
+		os.Remove(path)
+	}
+}
+
+// fileHash returns the first 16 hex digits of the SHA-256 of a file
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// GetProductImageData returns the product image as a data URI the webview can display
+func (s *Service) GetProductImageData(productID uint) (string, error) {
+	db := database.GetDB()
+
+	var product Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return "", fmt.Errorf("produit introuvable: %w", err)
+	}
+	if product.ImagePath == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(product.ImagePath)
+	if err != nil {
+		return "", fmt.Errorf("impossible de lire l'image du produit: %w", err)
+	}
+
+	mimeType := imageMimeTypes[strings.ToLower(filepath.Ext(product.ImagePath))]
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// productImageDir returns (and creates) the folder where product images are stored
+func productImageDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	imageDir := filepath.Join(configDir, "FactureApp", "images")
+	if err := os.MkdirAll(imageDir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier des images (%s): vérifiez les permissions ou l'espace disque", imageDir)
+	}
+	return imageDir, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"factureapp/backend/database"
+
+	"github.com/johnfercher/maroto/v2"
+	"github.com/johnfercher/maroto/v2/pkg/components/code"
+	"github.com/johnfercher/maroto/v2/pkg/components/col"
+	"github.com/johnfercher/maroto/v2/pkg/components/text"
+	"github.com/johnfercher/maroto/v2/pkg/config"
+	"github.com/johnfercher/maroto/v2/pkg/consts/align"
+	"github.com/johnfercher/maroto/v2/pkg/consts/barcode"
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// labelsPerRow is the number of shelf labels printed side by side on an A4 sheet
+const labelsPerRow = 3
+
+// GenerateShelfLabels creates an A4 sheet of shelf labels (name, price TTC, barcode) and returns the file path.
+// An empty list prints a label for every product.
+func (s *Service) GenerateShelfLabels(productIDs []uint) (string, error) {
+	db := database.GetDB()
+
+	var products []Product
+	query := db.Preload("Barcodes").Order("name ASC")
+	if len(productIDs) > 0 {
+		query = query.Where("id IN ?", productIDs)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		return "", fmt.Errorf("impossible de récupérer les produits: %w", err)
+	}
+	if len(products) == 0 {
+		return "", fmt.Errorf("aucun produit à étiqueter")
+	}
+
+	cfg := config.NewBuilder().
+		WithLeftMargin(10).
+		WithRightMargin(10).
+		WithTopMargin(10).
+		Build()
+
+	m := maroto.New(cfg)
+
+	for start := 0; start < len(products); start += labelsPerRow {
+		end := start + labelsPerRow
+		if end > len(products) {
+			end = len(products)
+		}
+		batch := products[start:end]
+
+		nameCols := make([]core.Col, labelsPerRow)
+		priceCols := make([]core.Col, labelsPerRow)
+		codeCols := make([]core.Col, labelsPerRow)
+		for i := 0; i < labelsPerRow; i++ {
+			nameCols[i] = col.New(12 / labelsPerRow)
+			priceCols[i] = col.New(12 / labelsPerRow)
+			codeCols[i] = col.New(12 / labelsPerRow)
+		}
+
+		for i, product := range batch {
+			nameCols[i].Add(text.New(product.Name, props.Text{
+				Size:  9,
+				Style: fontstyle.Bold,
+				Align: align.Center,
+				Top:   1,
+			}))
+			priceCols[i].Add(text.New(fmt.Sprintf("%s DH TTC", product.SellingPriceTTC), props.Text{
+				Size:  14,
+				Style: fontstyle.Bold,
+				Align: align.Center,
+			}))
+
+			value, symbology := labelCode(product)
+			barcodeType := barcode.Code128
+			if symbology == SymbologyEAN13 {
+				barcodeType = barcode.EAN
+			}
+			codeCols[i].Add(code.NewBar(value, props.Barcode{
+				Percent: 80,
+				Center:  true,
+				Type:    barcodeType,
+			}))
+		}
+
+		m.AddRow(8, nameCols...)
+		m.AddRow(9, priceCols...)
+		m.AddRow(16, codeCols...)
+		m.AddRow(4)
+	}
+
+	doc, err := m.Generate()
+	if err != nil {
+		return "", fmt.Errorf("échec de la génération des étiquettes: %w", err)
+	}
+
+	outputDir, err := os.UserConfigDir()
+	if err != nil {
+		outputDir = "."
+	}
+	labelDir := filepath.Join(outputDir, "FactureApp", "labels")
+	if err := os.MkdirAll(labelDir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier des étiquettes (%s): vérifiez les permissions ou l'espace disque", labelDir)
+	}
+
+	pdfPath := filepath.Join(labelDir, fmt.Sprintf("Etiquettes_%s.pdf", time.Now().Format("20060102_150405")))
+	if err := doc.Save(pdfPath); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder les étiquettes (%s): vérifiez les permissions et l'espace disque disponible", pdfPath)
+	}
+
+	return pdfPath, nil
+}
+
+// labelCode picks the code printed on a label: the first EAN-13, else the first barcode, else the reference
+func labelCode(product Product) (string, string) {
+	for _, b := range product.Barcodes {
+		if b.Symbology == SymbologyEAN13 {
+			return b.Code, b.Symbology
+		}
+	}
+	if len(product.Barcodes) > 0 {
+		return product.Barcodes[0].Code, product.Barcodes[0].Symbology
+	}
+	return product.Reference, SymbologyCode128
+}
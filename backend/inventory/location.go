@@ -0,0 +1,344 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrateLocations creates the default location and moves the stock recorded so far into it
+func migrateLocations(tx *gorm.DB) error {
+	location := Location{Name: DefaultLocationName, IsDefault: true}
+	if err := tx.Create(&location).Error; err != nil {
+		return fmt.Errorf("échec de la création de l'emplacement par défaut: %w", err)
+	}
+	return tx.Exec(`INSERT INTO location_stocks (product_id, location_id, quantity, min_stock_level)
+		SELECT id, ?, current_stock, min_stock_level FROM products WHERE deleted_at IS NULL`, location.ID).Error
+}
+
+// CreateLocation adds a stock location
+func (s *Service) CreateLocation(name string) (*Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("le nom de l'emplacement est obligatoire")
+	}
+	location := Location{Name: name}
+	if err := database.GetDB().Create(&location).Error; err != nil {
+		if contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("un emplacement nommé '%s' existe déjà", name)
+		}
+		return nil, fmt.Errorf("échec de la création de l'emplacement: %w", err)
+	}
+	return &location, nil
+}
+
+// RenameLocation changes the name of a location
+func (s *Service) RenameLocation(id uint, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("le nom de l'emplacement est obligatoire")
+	}
+	result := database.GetDB().Model(&Location{}).Where("id = ?", id).Update("name", name)
+	if result.Error != nil {
+		if contains(result.Error.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("un emplacement nommé '%s' existe déjà", name)
+		}
+		return fmt.Errorf("échec du renommage de l'emplacement: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("emplacement introuvable")
+	}
+	return nil
+}
+
+// SetDefaultLocation makes a location the one used by sales that do not choose a location
+func (s *Service) SetDefaultLocation(id uint) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if _, err := s.resolveLocation(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Model(&Location{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Location{}).Where("id = ?", id).Update("is_default", true).Error
+	})
+}
+
+// DeleteLocation removes an empty location other than the default one
+func (s *Service) DeleteLocation(id uint) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		location, err := s.resolveLocation(tx, id)
+		if err != nil {
+			return err
+		}
+		if location.IsDefault {
+			return fmt.Errorf("l'emplacement par défaut ne peut pas être supprimé")
+		}
+		var units int64
+		if err := tx.Model(&LocationStock{}).Where("location_id = ?", id).
+			Select("COALESCE(SUM(ABS(quantity)), 0)").Scan(&units).Error; err != nil {
+			return err
+		}
+		if units > 0 {
+			return fmt.Errorf("l'emplacement %s contient encore du stock: transférez-le avant de le supprimer", location.Name)
+		}
+		if err := tx.Where("location_id = ?", id).Delete(&LocationStock{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(location).Error
+	})
+}
+
+// GetLocations returns the stock locations, the default one first
+func (s *Service) GetLocations() ([]Location, error) {
+	var locations []Location
+	if err := database.GetDB().Order("is_default DESC, name ASC").Find(&locations).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des emplacements: %w", err)
+	}
+	return locations, nil
+}
+
+// GetProductLocations returns the stock of a product at each location
+func (s *Service) GetProductLocations(productID uint) ([]ProductLocationStock, error) {
+	var stocks []ProductLocationStock
+	if err := database.GetDB().Table("locations").
+		Select("locations.id AS location_id, locations.name AS location_name, COALESCE(location_stocks.quantity, 0) AS quantity, COALESCE(location_stocks.min_stock_level, 0) AS min_stock_level").
+		Joins("LEFT JOIN location_stocks ON location_stocks.location_id = locations.id AND location_stocks.product_id = ?", productID).
+		Order("locations.is_default DESC, locations.name ASC").
+		Scan(&stocks).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture du stock par emplacement: %w", err)
+	}
+	return stocks, nil
+}
+
+// SetLocationMinStock sets the alert threshold of a product at a location
+func (s *Service) SetLocationMinStock(productID, locationID uint, level int) error {
+	if level < 0 {
+		return fmt.Errorf("le seuil d'alerte ne peut pas être négatif")
+	}
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if _, err := s.resolveLocation(tx, locationID); err != nil {
+			return err
+		}
+		if err := adjustLocation(tx, productID, locationID, 0); err != nil {
+			return err
+		}
+		return tx.Model(&LocationStock{}).Where("product_id = ? AND location_id = ?", productID, locationID).
+			Update("min_stock_level", level).Error
+	})
+}
+
+// DecreaseStockAt decrements the stock of a product at a location (0: the default one) within a transaction,
+// consuming its lots as ConsumeStock does
+func (s *Service) DecreaseStockAt(tx *gorm.DB, productID, locationID uint, quantity int) error {
+	_, err := s.ConsumeStock(tx, productID, locationID, quantity)
+	return err
+}
+
+// IncreaseStockAt increments the stock of a product at a location (0: the default one) within a transaction
+func (s *Service) IncreaseStockAt(tx *gorm.DB, productID, locationID uint, quantity int) error {
+	if err := tx.First(&Product{}, productID).Error; err != nil {
+		return fmt.Errorf("produit introuvable: %w", err)
+	}
+	location, err := s.resolveLocation(tx, locationID)
+	if err != nil {
+		return err
+	}
+	return adjustStock(tx, productID, location.ID, quantity)
+}
+
+// StockAt returns the stock of a product at a location (0: the default one)
+func (s *Service) StockAt(productID, locationID uint) (int, error) {
+	db := database.GetDB()
+	location, err := s.resolveLocation(db, locationID)
+	if err != nil {
+		return 0, err
+	}
+	return stockAt(db, productID, location.ID)
+}
+
+// TransferStock moves units of a product from one location to another
+func (s *Service) TransferStock(productID, fromID, toID uint, quantity int, note string) (*StockTransfer, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("la quantité à transférer doit être supérieure à 0")
+	}
+	var transfer *StockTransfer
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var product Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return fmt.Errorf("produit introuvable: %w", err)
+		}
+		from, err := s.resolveLocation(tx, fromID)
+		if err != nil {
+			return err
+		}
+		to, err := s.resolveLocation(tx, toID)
+		if err != nil {
+			return err
+		}
+		if from.ID == to.ID {
+			return fmt.Errorf("les emplacements de départ et d'arrivée doivent être différents")
+		}
+		available, err := stockAt(tx, productID, from.ID)
+		if err != nil {
+			return err
+		}
+		if available < quantity {
+			return fmt.Errorf("stock insuffisant pour '%s' à l'emplacement %s (demandé: %d, disponible: %d)", product.Name, from.Name, quantity, available)
+		}
+
+		// The total stock of the product does not change
+		if err := adjustLocation(tx, productID, from.ID, -quantity); err != nil {
+			return err
+		}
+		if err := adjustLocation(tx, productID, to.ID, quantity); err != nil {
+			return err
+		}
+		// Lots follow the goods, expired ones included, keeping their number and expiry date
+		allocations, err := consumeLots(tx, productID, from.ID, quantity, true)
+		if err != nil {
+			return err
+		}
+		for _, allocation := range allocations {
+			if _, err := addToLot(tx, productID, to.ID, allocation.LotNumber, allocation.ExpiryDate, allocation.Quantity); err != nil {
+				return err
+			}
+		}
+
+		transfer = &StockTransfer{ProductID: productID, FromLocationID: from.ID, ToLocationID: to.ID, Quantity: quantity, Note: strings.TrimSpace(note)}
+		if err := tx.Create(transfer).Error; err != nil {
+			return fmt.Errorf("échec de l'enregistrement du transfert: %w", err)
+		}
+		reference := fmt.Sprintf("Transfert #%d: %s → %s", transfer.ID, from.Name, to.Name)
+		value := product.BuyingPrice.Mul(float64(quantity))
+		movements := []StockMovement{
+			{ProductID: productID, LocationID: from.ID, Quantity: -quantity, Kind: MovementTransfer, Reference: reference, Value: -value},
+			{ProductID: productID, LocationID: to.ID, Quantity: quantity, Kind: MovementTransfer, Reference: reference, Value: value},
+		}
+		if err := tx.Create(&movements).Error; err != nil {
+			return fmt.Errorf("échec de l'enregistrement des mouvements de stock: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetStockTransfers returns the transfers of a product, most recent first (0: every product)
+func (s *Service) GetStockTransfers(productID uint) ([]StockTransfer, error) {
+	query := database.GetDB().Order("created_at DESC, id DESC")
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	var transfers []StockTransfer
+	if err := query.Find(&transfers).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des transferts: %w", err)
+	}
+	return transfers, nil
+}
+
+// GetLowStock returns the products at or below their alert threshold: on their total stock when
+// locationID is 0, else at that location
+func (s *Service) GetLowStock(locationID uint) ([]LowStockAlert, error) {
+	db := database.GetDB()
+	var alerts []LowStockAlert
+	if locationID == 0 {
+		err := db.Model(&Product{}).
+			Select("id AS product_id, reference, name, current_stock AS quantity, min_stock_level").
+			Where("current_stock <= min_stock_level").Order("name ASC").Scan(&alerts).Error
+		if err != nil {
+			return nil, fmt.Errorf("échec de la lecture des alertes de stock: %w", err)
+		}
+		return alerts, nil
+	}
+
+	location, err := s.resolveLocation(db, locationID)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Table("location_stocks").
+		Select("products.id AS product_id, products.reference, products.name, location_stocks.location_id, ? AS location_name, location_stocks.quantity, location_stocks.min_stock_level", location.Name).
+		Joins("JOIN products ON products.id = location_stocks.product_id AND products.deleted_at IS NULL").
+		Where("location_stocks.location_id = ? AND location_stocks.quantity <= location_stocks.min_stock_level", location.ID).
+		Order("products.name ASC").Scan(&alerts).Error
+	if err != nil {
+		return nil, fmt.Errorf("échec de la lecture des alertes de stock: %w", err)
+	}
+	return alerts, nil
+}
+
+// locationStats sums up the units, value and low-stock products of each location
+func locationStats(db *gorm.DB) ([]LocationStats, error) {
+	var stats []LocationStats
+	stocked := db.Table("location_stocks").
+		Select("location_stocks.*, products.buying_price").
+		Joins("JOIN products ON products.id = location_stocks.product_id AND products.deleted_at IS NULL")
+	err := db.Table("locations").
+		Select(`locations.id AS location_id, locations.name,
+			COALESCE(SUM(stocked.quantity), 0) AS units,
+			COALESCE(SUM(stocked.quantity * stocked.buying_price), 0) AS stock_value,
+			COALESCE(SUM(CASE WHEN stocked.quantity <= stocked.min_stock_level THEN 1 ELSE 0 END), 0) AS low_stock_count`).
+		Joins("LEFT JOIN (?) AS stocked ON stocked.location_id = locations.id", stocked).
+		Group("locations.id, locations.name").
+		Order("locations.is_default DESC, locations.name ASC").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// resolveLocation returns a location, or the default one for 0
+func (s *Service) resolveLocation(tx *gorm.DB, id uint) (*Location, error) {
+	var location Location
+	query := tx.Where("id = ?", id)
+	if id == 0 {
+		query = tx.Where("is_default = ?", true)
+	}
+	if err := query.First(&location).Error; err != nil {
+		if id == 0 {
+			return nil, fmt.Errorf("aucun emplacement de stock par défaut n'est défini")
+		}
+		return nil, fmt.Errorf("emplacement de stock introuvable (ID: %d)", id)
+	}
+	return &location, nil
+}
+
+// stockAt returns the quantity of a product at a location (0 when it was never stocked there)
+func stockAt(tx *gorm.DB, productID, locationID uint) (int, error) {
+	var stocks []LocationStock
+	if err := tx.Where("product_id = ? AND location_id = ?", productID, locationID).Limit(1).Find(&stocks).Error; err != nil {
+		return 0, fmt.Errorf("échec de la lecture du stock: %w", err)
+	}
+	if len(stocks) == 0 {
+		return 0, nil
+	}
+	return stocks[0].Quantity, nil
+}
+
+// adjustStock changes the quantity of a product at a location and its total stock
+func adjustStock(tx *gorm.DB, productID, locationID uint, delta int) error {
+	if err := adjustLocation(tx, productID, locationID, delta); err != nil {
+		return err
+	}
+	if err := tx.Model(&Product{}).Where("id = ?", productID).
+		Update("current_stock", gorm.Expr("current_stock + ?", delta)).Error; err != nil {
+		return fmt.Errorf("échec de la mise à jour du stock: %w", err)
+	}
+	return nil
+}
+
+// adjustLocation changes the quantity of a product at a location only, creating its row when needed
+func adjustLocation(tx *gorm.DB, productID, locationID uint, delta int) error {
+	row := LocationStock{ProductID: productID, LocationID: locationID, Quantity: delta}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "location_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"quantity": gorm.Expr("location_stocks.quantity + ?", delta)}),
+	}).Create(&row).Error; err != nil {
+		return fmt.Errorf("échec de la mise à jour du stock de l'emplacement: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// ReceiveLot adds a received quantity of a product to a lot at a location (0: the default one), creating
+// the lot on its first receipt. The expiry date (DD-MM-YYYY) is optional; a lot received again keeps it.
+func (s *Service) ReceiveLot(productID, locationID uint, lotNumber, expiryDate string, quantity int) (*Lot, error) {
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return nil, fmt.Errorf("le numéro de lot est obligatoire")
+	}
+	if quantity <= 0 {
+		return nil, fmt.Errorf("la quantité reçue doit être supérieure à 0")
+	}
+	var expiry *time.Time
+	if strings.TrimSpace(expiryDate) != "" {
+		date, err := time.Parse("02-01-2006", strings.TrimSpace(expiryDate))
+		if err != nil {
+			return nil, fmt.Errorf("date de péremption invalide: %s (format JJ-MM-AAAA attendu)", expiryDate)
+		}
+		expiry = &date
+	}
+
+	var lot *Lot
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var product Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return fmt.Errorf("produit introuvable: %w", err)
+		}
+		location, err := s.resolveLocation(tx, locationID)
+		if err != nil {
+			return err
+		}
+
+		var existing []Lot
+		if err := tx.Where("product_id = ? AND location_id = ? AND lot_number = ?", productID, location.ID, lotNumber).
+			Limit(1).Find(&existing).Error; err != nil {
+			return fmt.Errorf("échec de la lecture des lots: %w", err)
+		}
+		if len(existing) > 0 && expiry != nil && !sameDate(existing[0].ExpiryDate, expiry) {
+			return fmt.Errorf("le lot %s de '%s' est déjà enregistré avec une autre date de péremption", lotNumber, product.Name)
+		}
+
+		if lot, err = addToLot(tx, productID, location.ID, lotNumber, expiry, quantity); err != nil {
+			return err
+		}
+		if err := adjustStock(tx, productID, location.ID, quantity); err != nil {
+			return err
+		}
+		movement := StockMovement{
+			ProductID:  productID,
+			LocationID: location.ID,
+			Quantity:   quantity,
+			Kind:       MovementReceipt,
+			Reference:  "Lot " + lotNumber,
+			Value:      product.BuyingPrice.Mul(float64(quantity)),
+		}
+		if err := tx.Create(&movement).Error; err != nil {
+			return fmt.Errorf("échec de l'enregistrement du mouvement de stock: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lot, nil
+}
+
+// GetLots returns the lots of a product still in stock, in the order they are consumed (0: every product)
+func (s *Service) GetLots(productID uint) ([]Lot, error) {
+	query := fefoOrder(database.GetDB().Where("quantity > 0"))
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	var lots []Lot
+	if err := query.Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des lots: %w", err)
+	}
+	return lots, nil
+}
+
+// GetExpiringLots returns the lots in stock that have expired or expire within the given number of days,
+// soonest first
+func (s *Service) GetExpiringLots(days int) ([]ExpiringLot, error) {
+	return expiringLots(database.GetDB(), days)
+}
+
+// ConsumeStock takes a sold quantity of a product out of a location (0: the default one). Lots are
+// consumed first expired first out, then by receipt date, and the untracked stock last; expired lots
+// cannot be sold. The lot quantities taken are returned so they can be printed on the invoice.
+func (s *Service) ConsumeStock(tx *gorm.DB, productID, locationID uint, quantity int) ([]LotAllocation, error) {
+	var product Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("produit introuvable: %w", err)
+	}
+	location, err := s.resolveLocation(tx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	available, err := stockAt(tx, productID, location.ID)
+	if err != nil {
+		return nil, err
+	}
+	var expired int
+	if err := tx.Model(&Lot{}).Where("product_id = ? AND location_id = ? AND expiry_date < ?", productID, location.ID, startOfDay()).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&expired).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des lots: %w", err)
+	}
+	if available-expired < quantity {
+		if expired > 0 {
+			return nil, fmt.Errorf("stock insuffisant pour '%s' à l'emplacement %s (demandé: %d, disponible: %d, périmé: %d)", product.Name, location.Name, quantity, available-expired, expired)
+		}
+		return nil, fmt.Errorf("stock insuffisant pour '%s' à l'emplacement %s (demandé: %d, disponible: %d)", product.Name, location.Name, quantity, available)
+	}
+
+	allocations, err := consumeLots(tx, productID, location.ID, quantity, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := adjustStock(tx, productID, location.ID, -quantity); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// ReturnToLot puts a quantity back into the lot it was taken from, e.g. when a sale is credited.
+// The stock itself is restored separately with IncreaseStockAt.
+func (s *Service) ReturnToLot(tx *gorm.DB, lotID uint, quantity int) error {
+	if err := tx.Model(&Lot{}).Where("id = ?", lotID).
+		Update("quantity", gorm.Expr("quantity + ?", quantity)).Error; err != nil {
+		return fmt.Errorf("échec de la mise à jour du lot: %w", err)
+	}
+	return nil
+}
+
+// consumeLots takes a quantity out of the lots of a product at a location in FEFO order and returns what
+// was taken from each. Whatever the lots do not cover comes from the untracked stock. Unless
+// includeExpired, expired lots are skipped.
+func consumeLots(tx *gorm.DB, productID, locationID uint, quantity int, includeExpired bool) ([]LotAllocation, error) {
+	query := tx.Where("product_id = ? AND location_id = ? AND quantity > 0", productID, locationID)
+	if !includeExpired {
+		query = query.Where("expiry_date IS NULL OR expiry_date >= ?", startOfDay())
+	}
+	var lots []Lot
+	if err := fefoOrder(query).Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des lots: %w", err)
+	}
+
+	var allocations []LotAllocation
+	for _, lot := range lots {
+		if quantity == 0 {
+			break
+		}
+		taken := min(lot.Quantity, quantity)
+		if err := tx.Model(&Lot{}).Where("id = ?", lot.ID).
+			Update("quantity", gorm.Expr("quantity - ?", taken)).Error; err != nil {
+			return nil, fmt.Errorf("échec de la mise à jour du lot %s: %w", lot.LotNumber, err)
+		}
+		allocations = append(allocations, LotAllocation{LotID: lot.ID, LotNumber: lot.LotNumber, ExpiryDate: lot.ExpiryDate, Quantity: taken})
+		quantity -= taken
+	}
+	return allocations, nil
+}
+
+// addToLot adds a quantity to the lot of a product at a location, creating it when needed
+func addToLot(tx *gorm.DB, productID, locationID uint, lotNumber string, expiry *time.Time, quantity int) (*Lot, error) {
+	var lot Lot
+	err := tx.Where(Lot{ProductID: productID, LocationID: locationID, LotNumber: lotNumber}).
+		Attrs(Lot{ExpiryDate: expiry, ReceivedAt: time.Now()}).
+		FirstOrCreate(&lot).Error
+	if err != nil {
+		return nil, fmt.Errorf("échec de l'enregistrement du lot %s: %w", lotNumber, err)
+	}
+	lot.Quantity += quantity
+	lot.InitialQuantity += quantity
+	if err := tx.Model(&lot).Updates(map[string]interface{}{"quantity": lot.Quantity, "initial_quantity": lot.InitialQuantity}).Error; err != nil {
+		return nil, fmt.Errorf("échec de l'enregistrement du lot %s: %w", lotNumber, err)
+	}
+	return &lot, nil
+}
+
+// expiringLots lists the lots in stock expiring within days, with their product and location
+func expiringLots(db *gorm.DB, days int) ([]ExpiringLot, error) {
+	today := startOfDay()
+	var lots []ExpiringLot
+	err := db.Table("lots").
+		Select("lots.id AS lot_id, lots.product_id, products.reference, products.name, lots.location_id, locations.name AS location_name, lots.lot_number, lots.expiry_date, lots.quantity").
+		Joins("JOIN products ON products.id = lots.product_id AND products.deleted_at IS NULL").
+		Joins("JOIN locations ON locations.id = lots.location_id").
+		Where("lots.quantity > 0 AND lots.expiry_date IS NOT NULL AND lots.expiry_date <= ?", today.AddDate(0, 0, days)).
+		Order("lots.expiry_date, products.name").
+		Scan(&lots).Error
+	if err != nil {
+		return nil, fmt.Errorf("échec de la lecture des lots à péremption proche: %w", err)
+	}
+	for i := range lots {
+		lots[i].DaysLeft = int(lots[i].ExpiryDate.Sub(today).Hours() / 24)
+		lots[i].Expired = lots[i].DaysLeft < 0
+	}
+	return lots, nil
+}
+
+// fefoOrder sorts lots first expired first out, lots without expiry last, then by receipt
+func fefoOrder(query *gorm.DB) *gorm.DB {
+	return query.Order("expiry_date IS NULL, expiry_date, received_at, id")
+}
+
+// startOfDay returns today's date, as expiry dates are stored
+func startOfDay() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// sameDate reports whether two optional dates are equal
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
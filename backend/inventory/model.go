@@ -1,6 +1,10 @@
 package inventory
 
 import (
+	"time"
+
+	"factureapp/backend/money"
+
 	"gorm.io/gorm"
 )
 
@@ -8,10 +12,247 @@ type Product struct {
 	gorm.Model
 	Reference       string `gorm:"uniqueIndex"` // e.g., "REF-001"
 	Name            string
-	Category        string
-	BuyingPrice     float64
-	SellingPriceTTC float64 // Default price for invoices
-	CurrentStock    int
-	MinStockLevel   int // Threshold for alert (e.g., 5)
+	CategoryID      *uint  `gorm:"index"`
+	Category        string // Category name, kept in sync with CategoryID for display
+	BuyingPrice     money.Amount
+	SellingPriceTTC money.Amount // Default price for invoices
+	CurrentStock    int          // Total of all locations, see LocationStock
+	MinStockLevel   int          // Threshold for alert (e.g., 5)
 	// When stock <= MinStockLevel, this product is flagged
+	DamagedStock int // Returned units unfit for sale, kept apart from CurrentStock
+
+	// Variants (size/colour) point to their parent product and carry their own reference and stock
+	ParentID     *uint `gorm:"index"`
+	VariantSize  string
+	VariantColor string
+
+	ImagePath string // Image copied into the app data folder
+
+	Barcodes []Barcode `gorm:"foreignKey:ProductID"` // A product can carry several codes (supplier EAN, internal code...)
+	Variants []Product `gorm:"foreignKey:ParentID"`
+}
+
+// Category is a node of the managed product category tree
+type Category struct {
+	gorm.Model
+	Name     string `json:"name"`
+	ParentID *uint  `gorm:"index" json:"parentId"`
+}
+
+// CategoryNode is a category with its children, for tree display
+type CategoryNode struct {
+	ID           uint           `json:"id"`
+	Name         string         `json:"name"`
+	ParentID     *uint          `json:"parentId"`
+	ProductCount int64          `json:"productCount"`
+	Children     []CategoryNode `json:"children"`
+}
+
+// Stock states for product filtering
+const (
+	StockStateOK  = "OK"
+	StockStateLow = "LOW" // At or below MinStockLevel
+	StockStateOut = "OUT" // Zero or negative
+)
+
+// ProductFilter narrows the product list; zero values mean "no filter"
+type ProductFilter struct {
+	Query      string `json:"query"`      // Matches name, reference or barcode
+	CategoryID uint   `json:"categoryId"` // Includes sub-categories
+	StockState string `json:"stockState"` // OK, LOW, OUT
+	ParentID   uint   `json:"parentId"`   // Only the variants of this product
+}
+
+// Barcode symbologies
+const (
+	SymbologyEAN13   = "EAN13"
+	SymbologyCode128 = "CODE128"
+)
+
+// Barcode is a scannable code attached to a product
+type Barcode struct {
+	ID        uint   `gorm:"primaryKey"`
+	ProductID uint   `gorm:"index"`
+	Code      string `gorm:"uniqueIndex"`
+	Symbology string // EAN13, CODE128
+	Internal  bool   // Generated in the in-store EAN range
+}
+
+// Stock movement kinds
+const (
+	MovementStockTake = "STOCK_TAKE" // Adjustment posted from a physical count
+	MovementTransfer  = "TRANSFER"   // Move between two locations
+	MovementReceipt   = "RECEIPT"    // Lot received into stock
+)
+
+// StockMovement records a change of a product's stock and why it happened
+type StockMovement struct {
+	ID         uint         `gorm:"primaryKey"`
+	ProductID  uint         `gorm:"index"`
+	LocationID uint         `gorm:"index"`
+	Quantity   int          // Signed: positive adds to stock, negative removes from it
+	Kind       string       `gorm:"size:20;index"` // STOCK_TAKE, TRANSFER or RECEIPT
+	Reference  string       // Source document, e.g. the stock-take session
+	Value      money.Amount // Quantity valued at the buying price
+	CreatedAt  time.Time    `gorm:"index"`
+}
+
+// Stock-take session states
+const (
+	StockTakeOpen      = "OPEN"
+	StockTakePosted    = "POSTED"
+	StockTakeCancelled = "CANCELLED"
+)
+
+// StockTake is a physical inventory count session. The expected quantities are frozen when it starts;
+// posting adjusts each counted product by the variance between its count and that snapshot.
+type StockTake struct {
+	gorm.Model
+	Name       string
+	LocationID uint   // Counted location (0: the default location)
+	CategoryID *uint  // Counted category, with its sub-categories (nil: every product)
+	Status     string `gorm:"size:10;index"`
+	PostedAt   *time.Time
+	Lines      []StockTakeLine `gorm:"foreignKey:StockTakeID"`
+}
+
+// StockTakeLine is the snapshot and count of one product in a session
+type StockTakeLine struct {
+	ID               uint `gorm:"primaryKey"`
+	StockTakeID      uint `gorm:"uniqueIndex:idx_stock_take_lines_product"`
+	ProductID        uint `gorm:"uniqueIndex:idx_stock_take_lines_product"`
+	Reference        string
+	Name             string
+	ExpectedQuantity int          // Stock of the location when the session started
+	CountedQuantity  *int         // nil until counted
+	BuyingPrice      money.Amount // Snapshot used to value the variance
+}
+
+// StockCount is a counted quantity entered for a product
+type StockCount struct {
+	ProductID uint `json:"productId"`
+	Quantity  int  `json:"quantity"`
+}
+
+// StockVariance is the difference between the counted and expected quantity of a product
+type StockVariance struct {
+	ProductID uint         `json:"productId"`
+	Reference string       `json:"reference"`
+	Name      string       `json:"name"`
+	Expected  int          `json:"expected"`
+	Counted   int          `json:"counted"`
+	Variance  int          `json:"variance"` // Counted minus expected
+	Value     money.Amount `json:"value"`    // Variance valued at the buying price
+}
+
+// StockTakeReport sums up the variances of a session
+type StockTakeReport struct {
+	StockTakeID uint            `json:"stockTakeId"`
+	Name        string          `json:"name"`
+	Status      string          `json:"status"`
+	Lines       int             `json:"lines"`
+	Counted     int             `json:"counted"`
+	Variances   []StockVariance `json:"variances"` // Counted products whose count differs from the snapshot
+	Uncounted   []StockVariance `json:"uncounted"` // Left unchanged when the session is posted
+	GainValue   money.Amount    `json:"gainValue"`
+	LossValue   money.Amount    `json:"lossValue"` // Positive
+	NetValue    money.Amount    `json:"netValue"`
+}
+
+// DefaultLocationName is the location holding the stock recorded before locations existed
+const DefaultLocationName = "Magasin"
+
+// Location is a place where stock is kept, e.g. the shop or a depot
+type Location struct {
+	ID        uint   `gorm:"primaryKey"`
+	Name      string `gorm:"uniqueIndex"`
+	IsDefault bool   // Used by sales that do not choose a location (counter, invoices without location)
+	CreatedAt time.Time
+}
+
+// LocationStock is the quantity of a product at a location
+type LocationStock struct {
+	ID            uint `gorm:"primaryKey"`
+	ProductID     uint `gorm:"uniqueIndex:idx_location_stocks_product_location"`
+	LocationID    uint `gorm:"uniqueIndex:idx_location_stocks_product_location;index"`
+	Quantity      int
+	MinStockLevel int // Alert threshold at this location
+}
+
+// StockTransfer records goods moved from one location to another
+type StockTransfer struct {
+	ID             uint `gorm:"primaryKey"`
+	ProductID      uint `gorm:"index"`
+	FromLocationID uint
+	ToLocationID   uint
+	Quantity       int
+	Note           string
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+// ProductLocationStock is the stock of a product at one location
+type ProductLocationStock struct {
+	LocationID    uint   `json:"locationId"`
+	LocationName  string `json:"locationName"`
+	Quantity      int    `json:"quantity"`
+	MinStockLevel int    `json:"minStockLevel"`
+}
+
+// LowStockAlert is a product at or below its alert threshold, globally or at a location
+type LowStockAlert struct {
+	ProductID     uint   `json:"productId"`
+	Reference     string `json:"reference"`
+	Name          string `json:"name"`
+	LocationID    uint   `json:"locationId"` // 0 for the global stock
+	LocationName  string `json:"locationName,omitempty"`
+	Quantity      int    `json:"quantity"`
+	MinStockLevel int    `json:"minStockLevel"`
+}
+
+// LocationStats sums up the stock of a location
+type LocationStats struct {
+	LocationID    uint
+	Name          string
+	Units         int
+	StockValue    money.Amount // Units valued at the buying price
+	LowStockCount int64
+}
+
+// ExpiryAlertDays is how far ahead the dashboard lists the lots about to expire
+const ExpiryAlertDays = 30
+
+// Lot is a batch of a product received at a location, with its number and expiry date.
+// The stock of a location not covered by lots is untracked, e.g. received before lot tracking.
+type Lot struct {
+	ID              uint       `gorm:"primaryKey"`
+	ProductID       uint       `gorm:"uniqueIndex:idx_lots_product_location_number"`
+	LocationID      uint       `gorm:"uniqueIndex:idx_lots_product_location_number"`
+	LotNumber       string     `gorm:"uniqueIndex:idx_lots_product_location_number"`
+	ExpiryDate      *time.Time `gorm:"index"` // nil: does not expire
+	ReceivedAt      time.Time
+	InitialQuantity int
+	Quantity        int // Remaining at the location
+}
+
+// LotAllocation is the quantity of a lot consumed by a sale or a transfer
+type LotAllocation struct {
+	LotID      uint       `json:"lotId"`
+	LotNumber  string     `json:"lotNumber"`
+	ExpiryDate *time.Time `json:"expiryDate,omitempty"`
+	Quantity   int        `json:"quantity"`
+}
+
+// ExpiringLot is a lot in stock that expires soon or has expired
+type ExpiringLot struct {
+	LotID        uint      `json:"lotId"`
+	ProductID    uint      `json:"productId"`
+	Reference    string    `json:"reference"`
+	Name         string    `json:"name"`
+	LocationID   uint      `json:"locationId"`
+	LocationName string    `json:"locationName"`
+	LotNumber    string    `json:"lotNumber"`
+	ExpiryDate   time.Time `json:"expiryDate"`
+	Quantity     int       `json:"quantity"`
+	DaysLeft     int       `json:"daysLeft"` // Negative once expired
+	Expired      bool      `json:"expired"`
 }
@@ -21,51 +21,280 @@ func NewService() *Service {
 
 func (s *Service) Migrate() error {
 	db := database.GetDB()
-	return db.AutoMigrate(&Product{})
+	if err := db.AutoMigrate(&Product{}, &Barcode{}, &Category{}, &StockMovement{}, &StockTake{}, &StockTakeLine{}, &Location{}, &LocationStock{}, &StockTransfer{}, &Lot{}); err != nil {
+		return err
+	}
+
+	// Prices were stored as REAL dirhams before amounts became integer centimes
+	if err := database.RunOnce("inventory_amounts_to_centimes", func(tx *gorm.DB) error {
+		return database.ConvertToCentimes(tx, "products", "buying_price", "selling_price_ttc")
+	}); err != nil {
+		return err
+	}
+
+	// Stock recorded before locations existed was all in one place
+	if err := database.RunOnce("stock_locations", migrateLocations); err != nil {
+		return err
+	}
+
+	return s.migrateLegacyCategories()
 }
 
-// DecreaseStock decrements the stock of a product within a transaction
+// DecreaseStock decrements the stock of a product at the default location within a transaction
 func (s *Service) DecreaseStock(tx *gorm.DB, productID uint, quantity int) error {
+	return s.DecreaseStockAt(tx, productID, 0, quantity)
+}
+
+// IncreaseStock increments the stock of a product at the default location within a transaction (used for cancellations/edits)
+func (s *Service) IncreaseStock(tx *gorm.DB, productID uint, quantity int) error {
+	return s.IncreaseStockAt(tx, productID, 0, quantity)
+}
+
+// AddDamagedStock records units of a product returned damaged within a transaction; they are not sellable
+func (s *Service) AddDamagedStock(tx *gorm.DB, productID uint, quantity int) error {
 	var product Product
 	if err := tx.First(&product, productID).Error; err != nil {
 		return fmt.Errorf("produit introuvable: %w", err)
 	}
 
-	if product.CurrentStock < quantity {
-		return fmt.Errorf("stock insuffisant pour '%s' (demandé: %d, disponible: %d)", product.Name, quantity, product.CurrentStock)
-	}
-
-	product.CurrentStock -= quantity
+	product.DamagedStock += quantity
 	if err := tx.Save(&product).Error; err != nil {
-		return fmt.Errorf("échec de la mise à jour du stock: %w", err)
+		return fmt.Errorf("échec de la mise à jour du stock endommagé: %w", err)
 	}
 
 	return nil
 }
 
-// IncreaseStock increments the stock of a product within a transaction (used for cancellations/edits)
-func (s *Service) IncreaseStock(tx *gorm.DB, productID uint, quantity int) error {
+// GetAllProducts returns the products matching the filter
+func (s *Service) GetAllProducts(filter ProductFilter) ([]Product, error) {
+	db := database.GetDB()
+	query := db.Preload("Barcodes")
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		likeQuery := "%" + q + "%"
+		query = query.Where("name LIKE ? OR reference LIKE ? OR id IN (SELECT product_id FROM barcodes WHERE code LIKE ?)", likeQuery, likeQuery, likeQuery)
+	}
+
+	if filter.CategoryID != 0 {
+		categoryIDs, err := s.categoryWithDescendants(db, filter.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+
+	switch filter.StockState {
+	case "":
+	case StockStateOut:
+		query = query.Where("current_stock <= 0")
+	case StockStateLow:
+		query = query.Where("current_stock > 0 AND current_stock <= min_stock_level")
+	case StockStateOK:
+		query = query.Where("current_stock > min_stock_level")
+	default:
+		return nil, fmt.Errorf("état de stock inconnu: %s", filter.StockState)
+	}
+
+	if filter.ParentID != 0 {
+		query = query.Where("parent_id = ?", filter.ParentID)
+	}
+
+	var products []Product
+	if err := query.Order("name ASC").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// CreateVariant creates a size/colour variant of an existing product.
+// The variant inherits category and prices from its parent unless given, and has its own reference and stock.
+func (s *Service) CreateVariant(parentID uint, variant Product) (*Product, error) {
+	db := database.GetDB()
+
+	var parent Product
+	if err := db.First(&parent, parentID).Error; err != nil {
+		return nil, fmt.Errorf("produit parent introuvable: %w", err)
+	}
+	if parent.ParentID != nil {
+		return nil, fmt.Errorf("impossible de créer une variante d'une variante")
+	}
+	if len(strings.TrimSpace(variant.VariantSize)) == 0 && len(strings.TrimSpace(variant.VariantColor)) == 0 {
+		return nil, fmt.Errorf("une variante doit avoir une taille ou une couleur")
+	}
+
+	variant.ID = 0
+	variant.ParentID = &parent.ID
+	if len(variant.Name) == 0 {
+		variant.Name = strings.TrimSpace(strings.Join([]string{parent.Name, variant.VariantSize, variant.VariantColor}, " "))
+	}
+	if variant.CategoryID == nil {
+		variant.CategoryID = parent.CategoryID
+	}
+	if variant.BuyingPrice == 0 {
+		variant.BuyingPrice = parent.BuyingPrice
+	}
+	if variant.SellingPriceTTC == 0 {
+		variant.SellingPriceTTC = parent.SellingPriceTTC
+	}
+	if variant.MinStockLevel == 0 {
+		variant.MinStockLevel = parent.MinStockLevel
+	}
+	if variant.ImagePath == "" {
+		variant.ImagePath = parent.ImagePath
+	}
+
+	return s.CreateProduct(variant)
+}
+
+// GetProductByCode returns the product matching a scanned or typed code.
+// Barcodes are tried first, then the product reference.
+func (s *Service) GetProductByCode(code string) (*Product, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == 0 {
+		return nil, fmt.Errorf("le code produit est obligatoire")
+	}
+
+	db := database.GetDB()
+
+	var barcodes []Barcode
+	if err := db.Where("code = ?", code).Limit(1).Find(&barcodes).Error; err != nil {
+		return nil, err
+	}
+
 	var product Product
-	if err := tx.First(&product, productID).Error; err != nil {
-		return fmt.Errorf("product not found: %w", err)
+	query := db.Preload("Barcodes")
+	if len(barcodes) > 0 {
+		query = query.Where("id = ?", barcodes[0].ProductID)
+	} else {
+		query = query.Where("reference = ?", code)
 	}
+	if err := query.First(&product).Error; err != nil {
+		return nil, fmt.Errorf("aucun produit ne correspond au code '%s'", code)
+	}
+	return &product, nil
+}
 
-	product.CurrentStock += quantity
-	if err := tx.Save(&product).Error; err != nil {
-		return fmt.Errorf("failed to update stock: %w", err)
+// AddBarcode attaches a validated barcode to a product
+func (s *Service) AddBarcode(productID uint, code string) (*Barcode, error) {
+	normalized, symbology, err := NormalizeBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+
+	var product Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("produit introuvable: %w", err)
+	}
+
+	barcode := Barcode{
+		ProductID: productID,
+		Code:      normalized,
+		Symbology: symbology,
 	}
+	if err := db.Create(&barcode).Error; err != nil {
+		errMsg := err.Error()
+		if contains(errMsg, "UNIQUE constraint failed") || contains(errMsg, "duplicate key") {
+			return nil, fmt.Errorf("le code-barres '%s' est déjà attribué à un produit", normalized)
+		}
+		return nil, fmt.Errorf("échec de l'ajout du code-barres: %w", err)
+	}
+	return &barcode, nil
+}
 
+// DeleteBarcode removes a barcode from its product
+func (s *Service) DeleteBarcode(id uint) error {
+	db := database.GetDB()
+	if err := db.Delete(&Barcode{}, id).Error; err != nil {
+		return fmt.Errorf("échec de la suppression du code-barres: %w", err)
+	}
 	return nil
 }
 
-// GetAllProducts returns all products
-func (s *Service) GetAllProducts() ([]Product, error) {
+// GenerateBarcode assigns the next EAN-13 from the internal range to a product
+func (s *Service) GenerateBarcode(productID uint) (*Barcode, error) {
 	db := database.GetDB()
-	var products []Product
-	if err := db.Find(&products).Error; err != nil {
+
+	var product Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("produit introuvable: %w", err)
+	}
+
+	var barcode *Barcode
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		barcode, err = s.generateBarcode(tx, productID)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
-	return products, nil
+	return barcode, nil
+}
+
+// GenerateMissingBarcodes assigns an internal EAN-13 to every product without a barcode and returns how many were created
+func (s *Service) GenerateMissingBarcodes() (int, error) {
+	db := database.GetDB()
+
+	var productIDs []uint
+	if err := db.Model(&Product{}).
+		Where("id NOT IN (SELECT product_id FROM barcodes)").
+		Order("id").
+		Pluck("id", &productIDs).Error; err != nil {
+		return 0, fmt.Errorf("échec de la recherche des produits sans code-barres: %w", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range productIDs {
+			if _, err := s.generateBarcode(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(productIDs), nil
+}
+
+// generateBarcode creates the next internal EAN-13 ("20" + 10-digit counter + check digit)
+func (s *Service) generateBarcode(tx *gorm.DB, productID uint) (*Barcode, error) {
+	// Codes typed in by hand may already use the in-store range: continue after the highest of them all
+	var last Barcode
+	pattern := InternalEANPrefix + strings.Repeat("[0-9]", 13-len(InternalEANPrefix))
+	if err := tx.Where("code GLOB ?", pattern).Order("code DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des codes-barres: %w", err)
+	}
+
+	next := 1
+	if last.ID != 0 {
+		if _, err := fmt.Sscanf(last.Code[len(InternalEANPrefix):12], "%d", &next); err != nil {
+			return nil, fmt.Errorf("code-barres interne illisible: %s", last.Code)
+		}
+		next++
+	}
+	if next > 9999999999 {
+		return nil, fmt.Errorf("la plage de codes-barres internes est épuisée")
+	}
+
+	body := fmt.Sprintf("%s%010d", InternalEANPrefix, next)
+	check, err := EAN13CheckDigit(body)
+	if err != nil {
+		return nil, err
+	}
+
+	barcode := Barcode{
+		ProductID: productID,
+		Code:      fmt.Sprintf("%s%d", body, check),
+		Symbology: SymbologyEAN13,
+		Internal:  true,
+	}
+	if err := tx.Create(&barcode).Error; err != nil {
+		return nil, fmt.Errorf("échec de la génération du code-barres: %w", err)
+	}
+	return &barcode, nil
 }
 
 // CreateProduct creates a new product
@@ -80,12 +309,38 @@ func (s *Service) CreateProduct(product Product) (*Product, error) {
 	if product.SellingPriceTTC < 0 {
 		return nil, fmt.Errorf("le prix de vente ne peut pas être négatif")
 	}
+	for i := range product.Barcodes {
+		code, symbology, err := NormalizeBarcode(product.Barcodes[i].Code)
+		if err != nil {
+			return nil, err
+		}
+		product.Barcodes[i].Code = code
+		product.Barcodes[i].Symbology = symbology
+	}
 
 	db := database.GetDB()
-	if err := db.Create(&product).Error; err != nil {
+	if err := s.resolveCategory(db, &product); err != nil {
+		return nil, err
+	}
+	product.Variants = nil
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		// The initial stock is received at the default location
+		location, err := s.resolveLocation(tx, 0)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&LocationStock{ProductID: product.ID, LocationID: location.ID, Quantity: product.CurrentStock, MinStockLevel: product.MinStockLevel}).Error
+	})
+	if err != nil {
 		// Check for unique constraint violation (SQLite)
 		errMsg := err.Error()
-		if contains(errMsg, "UNIQUE constraint failed") || contains(errMsg, "duplicate key") {
+		if contains(errMsg, "barcodes.code") {
+			return nil, fmt.Errorf("un des codes-barres est déjà attribué à un autre produit")
+		}
+		if contains(errMsg, "products.reference") || contains(errMsg, "duplicate key") {
 			return nil, fmt.Errorf("un produit avec la référence '%s' existe déjà", product.Reference)
 		}
 		return nil, fmt.Errorf("échec de la création du produit: %w", err)
@@ -93,7 +348,8 @@ func (s *Service) CreateProduct(product Product) (*Product, error) {
 	return &product, nil
 }
 
-// UpdateProduct updates an existing product
+// UpdateProduct updates an existing product. The stock is not edited here: corrections go through a
+// stock-take, which records the variance as a stock movement.
 func (s *Service) UpdateProduct(product Product) error {
 	// Pre-validation
 	if len(product.Name) == 0 {
@@ -104,10 +360,45 @@ func (s *Service) UpdateProduct(product Product) error {
 	}
 
 	db := database.GetDB()
-	if err := db.Save(&product).Error; err != nil {
-		return fmt.Errorf("échec de la mise à jour du produit: %w", err)
+	if err := s.resolveCategory(db, &product); err != nil {
+		return err
 	}
-	return nil
+	if product.ParentID != nil {
+		if *product.ParentID == product.ID {
+			return fmt.Errorf("un produit ne peut pas être sa propre variante")
+		}
+		// Same rules as CreateVariant: variants only hang off a product that is not itself a variant
+		var parent Product
+		if err := db.First(&parent, *product.ParentID).Error; err != nil {
+			return fmt.Errorf("produit parent introuvable: %w", err)
+		}
+		if parent.ParentID != nil {
+			return fmt.Errorf("impossible de créer une variante d'une variante")
+		}
+		var variants int64
+		if err := db.Model(&Product{}).Where("parent_id = ?", product.ID).Count(&variants).Error; err != nil {
+			return fmt.Errorf("échec de la lecture des variantes: %w", err)
+		}
+		if variants > 0 {
+			return fmt.Errorf("ce produit a ses propres variantes: il ne peut pas devenir une variante")
+		}
+	}
+
+	// Barcodes are managed through AddBarcode/DeleteBarcode, variants through CreateVariant
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current Product
+		if err := tx.First(&current, product.ID).Error; err != nil {
+			return fmt.Errorf("produit introuvable: %w", err)
+		}
+		// A stale form would otherwise put back units sold since it was loaded
+		if product.CurrentStock != current.CurrentStock {
+			return fmt.Errorf("le stock de '%s' (%d) ne se modifie pas depuis la fiche produit: rechargez le produit et corrigez le stock par un inventaire", current.Name, current.CurrentStock)
+		}
+		if err := tx.Omit("Barcodes", "Variants", "CurrentStock").Save(&product).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour du produit: %w", err)
+		}
+		return nil
+	})
 }
 
 // DeleteProduct soft deletes a product
@@ -124,16 +415,33 @@ func (s *Service) DeleteProduct(id uint) error {
 		return fmt.Errorf("impossible de supprimer ce produit car il est utilisé dans %d facture(s)", count)
 	}
 
-	// GORM performs a soft delete automatically because Product embeds gorm.Model
-	if err := db.Delete(&Product{}, id).Error; err != nil {
-		return fmt.Errorf("échec de la suppression du produit: %w", err)
+	var variantCount int64
+	if err := db.Model(&Product{}).Where("parent_id = ?", id).Count(&variantCount).Error; err != nil {
+		return fmt.Errorf("échec de la vérification d'utilisation: %w", err)
 	}
-	return nil
+	if variantCount > 0 {
+		return fmt.Errorf("impossible de supprimer ce produit car il a %d variante(s)", variantCount)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// GORM performs a soft delete automatically because Product embeds gorm.Model
+		if err := tx.Delete(&Product{}, id).Error; err != nil {
+			return fmt.Errorf("échec de la suppression du produit: %w", err)
+		}
+
+		// Free the product's barcodes so they can be reused
+		if err := tx.Where("product_id = ?", id).Delete(&Barcode{}).Error; err != nil {
+			return fmt.Errorf("échec de la suppression des codes-barres: %w", err)
+		}
+		return nil
+	})
 }
 
 type InventoryStats struct {
 	TotalProducts int64
 	LowStockCount int64
+	Locations     []LocationStats // Units, value and low-stock products of each location
+	ExpiringLots  []ExpiringLot   // Lots expired or expiring within ExpiryAlertDays
 }
 
 func (s *Service) GetStats() (*InventoryStats, error) {
@@ -148,5 +456,15 @@ func (s *Service) GetStats() (*InventoryStats, error) {
 		return nil, err
 	}
 
+	locations, err := locationStats(db)
+	if err != nil {
+		return nil, err
+	}
+	stats.Locations = locations
+
+	if stats.ExpiringLots, err = expiringLots(db, ExpiryAlertDays); err != nil {
+		return nil, err
+	}
+
 	return &stats, nil
 }
@@ -0,0 +1,298 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// StartStockTake opens a count session at a location (0: the default one) and freezes its stock of every
+// product, or of a category and its sub-categories. Only one session can be open at a time per location.
+func (s *Service) StartStockTake(name string, categoryID *uint, locationID uint) (*StockTake, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Inventaire du " + time.Now().Format("02-01-2006")
+	}
+
+	var session *StockTake
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		location, err := s.resolveLocation(tx, locationID)
+		if err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&StockTake{}).Where("status = ? AND location_id = ?", StockTakeOpen, location.ID).Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return fmt.Errorf("une session d'inventaire est déjà en cours à l'emplacement %s: validez-la ou annulez-la d'abord", location.Name)
+		}
+
+		query := tx.Order("name ASC")
+		if categoryID != nil {
+			ids, err := s.categoryWithDescendants(tx, *categoryID)
+			if err != nil {
+				return err
+			}
+			query = query.Where("category_id IN ?", ids)
+		}
+		var products []Product
+		if err := query.Find(&products).Error; err != nil {
+			return fmt.Errorf("impossible de récupérer les produits: %w", err)
+		}
+		if len(products) == 0 {
+			return fmt.Errorf("aucun produit à inventorier")
+		}
+
+		var stocks []LocationStock
+		if err := tx.Where("location_id = ?", location.ID).Find(&stocks).Error; err != nil {
+			return fmt.Errorf("échec de la lecture du stock de l'emplacement: %w", err)
+		}
+		quantities := make(map[uint]int, len(stocks))
+		for _, stock := range stocks {
+			quantities[stock.ProductID] = stock.Quantity
+		}
+
+		session = &StockTake{Name: name, LocationID: location.ID, CategoryID: categoryID, Status: StockTakeOpen}
+		for _, product := range products {
+			session.Lines = append(session.Lines, StockTakeLine{
+				ProductID:        product.ID,
+				Reference:        product.Reference,
+				Name:             product.Name,
+				ExpectedQuantity: quantities[product.ID],
+				BuyingPrice:      product.BuyingPrice,
+			})
+		}
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("échec de la création de la session d'inventaire: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetStockTakes returns the count sessions, most recent first, without their lines
+func (s *Service) GetStockTakes() ([]StockTake, error) {
+	var sessions []StockTake
+	if err := database.GetDB().Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des sessions d'inventaire: %w", err)
+	}
+	return sessions, nil
+}
+
+// GetStockTake returns a count session with its lines
+func (s *Service) GetStockTake(id uint) (*StockTake, error) {
+	var session StockTake
+	if err := database.GetDB().Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name ASC")
+	}).First(&session, id).Error; err != nil {
+		return nil, fmt.Errorf("session d'inventaire introuvable: %w", err)
+	}
+	return &session, nil
+}
+
+// ScanCount adds a counted quantity (1 when zero) to the product matching a scanned or typed code
+func (s *Service) ScanCount(id uint, code string, quantity int) (*StockTakeLine, error) {
+	if quantity == 0 {
+		quantity = 1
+	}
+	product, err := s.GetProductByCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	var line StockTakeLine
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := openStockTake(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("stock_take_id = ? AND product_id = ?", id, product.ID).First(&line).Error; err != nil {
+			return fmt.Errorf("le produit %s ne fait pas partie de cette session d'inventaire", product.Name)
+		}
+		counted := quantity
+		if line.CountedQuantity != nil {
+			counted += *line.CountedQuantity
+		}
+		if counted < 0 {
+			return fmt.Errorf("la quantité comptée de %s ne peut pas être négative", product.Name)
+		}
+		line.CountedQuantity = &counted
+		return tx.Save(&line).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// SetCounts records counted quantities in bulk, replacing any previous count of these products
+func (s *Service) SetCounts(id uint, counts []StockCount) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := openStockTake(tx, id); err != nil {
+			return err
+		}
+		for i, count := range counts {
+			if count.Quantity < 0 {
+				return fmt.Errorf("ligne %d: la quantité comptée ne peut pas être négative", i+1)
+			}
+			result := tx.Model(&StockTakeLine{}).Where("stock_take_id = ? AND product_id = ?", id, count.ProductID).
+				Update("counted_quantity", count.Quantity)
+			if result.Error != nil {
+				return fmt.Errorf("échec de l'enregistrement du comptage: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("ligne %d: le produit (ID: %d) ne fait pas partie de cette session d'inventaire", i+1, count.ProductID)
+			}
+		}
+		return nil
+	})
+}
+
+// GetStockTakeReport returns the variances of a session, valued at the buying prices of the snapshot
+func (s *Service) GetStockTakeReport(id uint) (*StockTakeReport, error) {
+	session, err := s.GetStockTake(id)
+	if err != nil {
+		return nil, err
+	}
+	return stockTakeReport(session), nil
+}
+
+// PostStockTake closes a session and adjusts the stock of each counted product by its variance, recording
+// a stock movement for each. Sales made during the count are kept, since the variance is relative to the
+// snapshot; uncounted products are left unchanged.
+func (s *Service) PostStockTake(id uint) (*StockTakeReport, error) {
+	var report *StockTakeReport
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := openStockTake(tx, id); err != nil {
+			return err
+		}
+		var session StockTake
+		if err := tx.Preload("Lines").First(&session, id).Error; err != nil {
+			return fmt.Errorf("session d'inventaire introuvable: %w", err)
+		}
+		report = stockTakeReport(&session)
+		if report.Counted == 0 {
+			return fmt.Errorf("aucun produit n'a été compté dans cette session")
+		}
+
+		location, err := s.resolveLocation(tx, session.LocationID)
+		if err != nil {
+			return err
+		}
+		reference := fmt.Sprintf("Inventaire #%d - %s", session.ID, session.Name)
+		for _, variance := range report.Variances {
+			if err := adjustStock(tx, variance.ProductID, location.ID, variance.Variance); err != nil {
+				return fmt.Errorf("échec de l'ajustement du stock de %s: %w", variance.Name, err)
+			}
+			// Missing goods are taken out of the lots as a sale would; surplus goods are untracked
+			if variance.Variance < 0 {
+				if _, err := consumeLots(tx, variance.ProductID, location.ID, -variance.Variance, true); err != nil {
+					return err
+				}
+			}
+			movement := StockMovement{
+				ProductID:  variance.ProductID,
+				LocationID: location.ID,
+				Quantity:   variance.Variance,
+				Kind:       MovementStockTake,
+				Reference:  reference,
+				Value:      variance.Value,
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				return fmt.Errorf("échec de l'enregistrement du mouvement de stock de %s: %w", variance.Name, err)
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&session).Updates(map[string]interface{}{"status": StockTakePosted, "posted_at": now}).Error; err != nil {
+			return fmt.Errorf("échec de la clôture de la session d'inventaire: %w", err)
+		}
+		report.Status = StockTakePosted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// CancelStockTake abandons an open session without touching the stock
+func (s *Service) CancelStockTake(id uint) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := openStockTake(tx, id); err != nil {
+			return err
+		}
+		return tx.Model(&StockTake{}).Where("id = ?", id).Update("status", StockTakeCancelled).Error
+	})
+}
+
+// GetStockMovements returns the stock movements of a product, most recent first (0: every product)
+func (s *Service) GetStockMovements(productID uint) ([]StockMovement, error) {
+	query := database.GetDB().Order("created_at DESC, id DESC")
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	var movements []StockMovement
+	if err := query.Find(&movements).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des mouvements de stock: %w", err)
+	}
+	return movements, nil
+}
+
+// openStockTake checks that a session exists and is still open
+func openStockTake(tx *gorm.DB, id uint) error {
+	var session StockTake
+	if err := tx.First(&session, id).Error; err != nil {
+		return fmt.Errorf("session d'inventaire introuvable: %w", err)
+	}
+	if session.Status != StockTakeOpen {
+		return fmt.Errorf("la session d'inventaire %s est close", session.Name)
+	}
+	return nil
+}
+
+// stockTakeReport computes the variances of a session loaded with its lines
+func stockTakeReport(session *StockTake) *StockTakeReport {
+	report := &StockTakeReport{
+		StockTakeID: session.ID,
+		Name:        session.Name,
+		Status:      session.Status,
+		Lines:       len(session.Lines),
+		Variances:   []StockVariance{},
+		Uncounted:   []StockVariance{},
+	}
+	for _, line := range session.Lines {
+		variance := StockVariance{
+			ProductID: line.ProductID,
+			Reference: line.Reference,
+			Name:      line.Name,
+			Expected:  line.ExpectedQuantity,
+		}
+		if line.CountedQuantity == nil {
+			report.Uncounted = append(report.Uncounted, variance)
+			continue
+		}
+		report.Counted++
+		variance.Counted = *line.CountedQuantity
+		variance.Variance = variance.Counted - variance.Expected
+		if variance.Variance == 0 {
+			continue
+		}
+		variance.Value = line.BuyingPrice.Mul(float64(variance.Variance))
+		if variance.Value > 0 {
+			report.GainValue += variance.Value
+		} else {
+			report.LossValue -= variance.Value
+		}
+		report.Variances = append(report.Variances, variance)
+	}
+	report.NetValue = report.GainValue - report.LossValue
+	return report
+}
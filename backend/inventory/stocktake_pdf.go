@@ -0,0 +1,167 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"factureapp/backend/database"
+
+	"github.com/johnfercher/maroto/v2"
+	"github.com/johnfercher/maroto/v2/pkg/components/col"
+	"github.com/johnfercher/maroto/v2/pkg/components/line"
+	"github.com/johnfercher/maroto/v2/pkg/components/text"
+	"github.com/johnfercher/maroto/v2/pkg/config"
+	"github.com/johnfercher/maroto/v2/pkg/consts/align"
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// sheetColumn is a column of a stock-take table: heading, width on the 12-column grid and alignment
+type sheetColumn struct {
+	Heading string
+	Width   int
+	Align   align.Type
+}
+
+// GenerateCountSheet creates the printable count sheet of a session: every product to count with an
+// empty box for the counted quantity. Expected quantities are not printed so the count stays blind.
+func (s *Service) GenerateCountSheet(id uint) (string, error) {
+	session, err := s.GetStockTake(id)
+	if err != nil {
+		return "", err
+	}
+
+	m := s.stockTakeDocument("FEUILLE DE COMPTAGE", session)
+	columns := []sheetColumn{
+		{"RÉFÉRENCE", 3, align.Left},
+		{"DÉSIGNATION", 6, align.Left},
+		{"QTÉ COMPTÉE", 3, align.Center},
+	}
+	addHeadings(m, columns)
+	for _, l := range session.Lines {
+		addCells(m, columns, l.Reference, l.Name, "")
+		m.AddRow(1, col.New(9), col.New(3).Add(line.New(props.Line{Thickness: 0.2})))
+	}
+	m.AddRow(12)
+	m.AddRow(6, text.NewCol(12, "Compté par: ____________________     Date: ____________     Signature: ____________________", props.Text{Size: 9}))
+
+	return saveStockTakePDF(m, fmt.Sprintf("Feuille_comptage_%d.pdf", session.ID))
+}
+
+// GenerateVarianceReport creates the variance report of a session: the products whose count differs from
+// the snapshot with their value at the buying price, the totals and the products left uncounted
+func (s *Service) GenerateVarianceReport(id uint) (string, error) {
+	session, err := s.GetStockTake(id)
+	if err != nil {
+		return "", err
+	}
+	report := stockTakeReport(session)
+
+	m := s.stockTakeDocument("RAPPORT D'ÉCARTS D'INVENTAIRE", session)
+	m.AddRow(6, text.NewCol(12, fmt.Sprintf("%d produit(s) compté(s) sur %d, %d écart(s)", report.Counted, report.Lines, len(report.Variances)), props.Text{Size: 9}))
+	m.AddRow(3)
+
+	columns := []sheetColumn{
+		{"RÉFÉRENCE", 2, align.Left},
+		{"DÉSIGNATION", 4, align.Left},
+		{"THÉORIQUE", 1, align.Center},
+		{"COMPTÉ", 1, align.Center},
+		{"ÉCART", 1, align.Center},
+		{"VALEUR (DH)", 3, align.Right},
+	}
+	addHeadings(m, columns)
+	for _, v := range report.Variances {
+		addCells(m, columns, v.Reference, v.Name, fmt.Sprint(v.Expected), fmt.Sprint(v.Counted), fmt.Sprintf("%+d", v.Variance), v.Value.String())
+	}
+	if len(report.Variances) == 0 {
+		m.AddRow(6, text.NewCol(12, "Aucun écart constaté", props.Text{Size: 9, Style: fontstyle.Italic, Align: align.Center}))
+	}
+
+	m.AddRow(3)
+	totalProps := props.Text{Size: 9, Style: fontstyle.Bold, Align: align.Right}
+	m.AddRow(6, text.NewCol(9, "Excédents:", totalProps), text.NewCol(3, report.GainValue.String(), totalProps))
+	m.AddRow(6, text.NewCol(9, "Manquants:", totalProps), text.NewCol(3, (-report.LossValue).String(), totalProps))
+	m.AddRow(6, text.NewCol(9, "Écart net:", totalProps), text.NewCol(3, report.NetValue.String(), totalProps))
+
+	if len(report.Uncounted) > 0 {
+		m.AddRow(8)
+		m.AddRow(6, text.NewCol(12, "Produits non comptés (stock inchangé)", props.Text{Size: 10, Style: fontstyle.Bold}))
+		uncounted := []sheetColumn{
+			{"RÉFÉRENCE", 3, align.Left},
+			{"DÉSIGNATION", 7, align.Left},
+			{"THÉORIQUE", 2, align.Center},
+		}
+		addHeadings(m, uncounted)
+		for _, v := range report.Uncounted {
+			addCells(m, uncounted, v.Reference, v.Name, fmt.Sprint(v.Expected))
+		}
+	}
+
+	return saveStockTakePDF(m, fmt.Sprintf("Ecarts_inventaire_%d.pdf", session.ID))
+}
+
+// stockTakeDocument starts an A4 stock-take document with its title and session details
+func (s *Service) stockTakeDocument(title string, session *StockTake) core.Maroto {
+	cfg := config.NewBuilder().
+		WithPageNumber().
+		WithLeftMargin(10).
+		WithRightMargin(10).
+		WithTopMargin(10).
+		Build()
+	m := maroto.New(cfg)
+
+	m.AddRow(10, text.NewCol(12, title, props.Text{Size: 14, Style: fontstyle.Bold, Align: align.Center}))
+	status := map[string]string{StockTakeOpen: "en cours", StockTakePosted: "validée", StockTakeCancelled: "annulée"}[session.Status]
+	locationName := ""
+	if location, err := s.resolveLocation(database.GetDB(), session.LocationID); err == nil {
+		locationName = ", " + location.Name
+	}
+	m.AddRow(6, text.NewCol(12, fmt.Sprintf("%s - session #%d%s démarrée le %s (%s)", session.Name, session.ID, locationName, session.CreatedAt.Format("02-01-2006 15:04"), status),
+		props.Text{Size: 9, Align: align.Center}))
+	m.AddRow(6)
+	return m
+}
+
+// addHeadings adds the heading row of a stock-take table
+func addHeadings(m core.Maroto, columns []sheetColumn) {
+	cols := make([]core.Col, len(columns))
+	for i, c := range columns {
+		cols[i] = text.NewCol(c.Width, c.Heading, props.Text{Size: 8, Style: fontstyle.Bold, Align: c.Align, Top: 2})
+	}
+	m.AddRow(7, cols...).WithStyle(&props.Cell{BackgroundColor: &props.Color{Red: 230, Green: 230, Blue: 230}})
+}
+
+// addCells adds a row of a stock-take table
+func addCells(m core.Maroto, columns []sheetColumn, values ...string) {
+	cols := make([]core.Col, len(columns))
+	for i, c := range columns {
+		cols[i] = text.NewCol(c.Width, values[i], props.Text{Size: 9, Align: c.Align, Top: 1.5})
+	}
+	m.AddRow(7, cols...)
+}
+
+// saveStockTakePDF generates a stock-take document into the inventory folder and returns its path
+func saveStockTakePDF(m core.Maroto, name string) (string, error) {
+	doc, err := m.Generate()
+	if err != nil {
+		return "", fmt.Errorf("échec de la génération du document d'inventaire: %w", err)
+	}
+
+	outputDir, err := os.UserConfigDir()
+	if err != nil {
+		outputDir = "."
+	}
+	dir := filepath.Join(outputDir, "FactureApp", "inventory")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier d'inventaire (%s): vérifiez les permissions ou l'espace disque", dir)
+	}
+
+	pdfPath := filepath.Join(dir, fmt.Sprintf("%s_%s", time.Now().Format("20060102_150405"), name))
+	if err := doc.Save(pdfPath); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder le document (%s): vérifiez les permissions et l'espace disque disponible", pdfPath)
+	}
+	return pdfPath, nil
+}
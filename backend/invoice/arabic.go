@@ -0,0 +1,76 @@
+package invoice
+
+import (
+	"strings"
+)
+
+var (
+	arabicUnits    = []string{"", "واحد", "اثنان", "ثلاثة", "أربعة", "خمسة", "ستة", "سبعة", "ثمانية", "تسعة"}
+	arabicTeens    = []string{"عشرة", "أحد عشر", "اثنا عشر", "ثلاثة عشر", "أربعة عشر", "خمسة عشر", "ستة عشر", "سبعة عشر", "ثمانية عشر", "تسعة عشر"}
+	arabicTens     = []string{"", "عشرة", "عشرون", "ثلاثون", "أربعون", "خمسون", "ستون", "سبعون", "ثمانون", "تسعون"}
+	arabicHundreds = []string{"", "مائة", "مائتان", "ثلاثمائة", "أربعمائة", "خمسمائة", "ستمائة", "سبعمائة", "ثمانمائة", "تسعمائة"}
+)
+
+// arabicScale names a power of thousand in its singular, dual and plural (3 to 10) forms
+type arabicScale struct {
+	value                  int
+	singular, dual, plural string
+}
+
+var arabicScales = []arabicScale{
+	{1000000000, "مليار", "ملياران", "مليارات"},
+	{1000000, "مليون", "مليونان", "ملايين"},
+	{1000, "ألف", "ألفان", "آلاف"},
+}
+
+// IntToArabic converts an integer to Arabic words, e.g. 1250 → "ألف ومائتان وخمسون"
+func IntToArabic(n int) string {
+	if n == 0 {
+		return "صفر"
+	}
+
+	if n < 0 {
+		return "ناقص " + IntToArabic(-n)
+	}
+
+	parts := []string{}
+
+	for _, scale := range arabicScales {
+		if n < scale.value {
+			continue
+		}
+		count := n / scale.value
+		n %= scale.value
+		switch {
+		case count == 1:
+			parts = append(parts, scale.singular)
+		case count == 2:
+			parts = append(parts, scale.dual)
+		case count <= 10:
+			parts = append(parts, IntToArabic(count)+" "+scale.plural)
+		default:
+			parts = append(parts, IntToArabic(count)+" "+scale.singular)
+		}
+	}
+
+	// Hundreds
+	if n >= 100 {
+		parts = append(parts, arabicHundreds[n/100])
+		n %= 100
+	}
+
+	// Units come before the tens: 21 → "واحد وعشرون"
+	if n > 0 {
+		if n < 10 {
+			parts = append(parts, arabicUnits[n])
+		} else if n < 20 {
+			parts = append(parts, arabicTeens[n-10])
+		} else if n%10 == 0 {
+			parts = append(parts, arabicTens[n/10])
+		} else {
+			parts = append(parts, arabicUnits[n%10]+" و"+arabicTens[n/10])
+		}
+	}
+
+	return strings.Join(parts, " و")
+}
@@ -0,0 +1,238 @@
+package invoice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"factureapp/backend/database"
+	"factureapp/backend/settings"
+)
+
+// originalDir is the sub-folder of each month holding the immutable copies of the first PDF issued
+const originalDir = "originaux"
+
+// ArchiveRoot returns the folder under which invoice PDFs are organised by year and month
+func (s *Service) ArchiveRoot() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	return s.settingsService.Get(settings.KeyArchiveRoot, filepath.Join(configDir, "FactureApp", "archives"))
+}
+
+// SetArchiveRoot changes the archive folder; already archived PDFs stay where they are
+func (s *Service) SetArchiveRoot(root string) error {
+	root = strings.TrimSpace(root)
+	if root == "" || !filepath.IsAbs(root) {
+		return fmt.Errorf("le dossier d'archives doit être un chemin absolu")
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("impossible de créer le dossier d'archives (%s): vérifiez les permissions ou l'espace disque", root)
+	}
+
+	// Check that PDFs can actually be written there
+	probe, err := os.CreateTemp(root, ".facture-*")
+	if err != nil {
+		return fmt.Errorf("le dossier d'archives n'est pas accessible en écriture (%s)", root)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	return s.settingsService.Set(settings.KeyArchiveRoot, filepath.Clean(root))
+}
+
+// PDFFileName returns the file name of an invoice PDF, built from its number and client,
+// e.g. "FA-0012-2025_Societe_Atlas.pdf"
+func PDFFileName(invoice *InvoiceResponse) string {
+	number := invoice.FormattedID
+	if invoice.CustomFormattedID != "" {
+		number = invoice.CustomFormattedID
+	}
+	name := "FA-" + safeFileComponent(strings.ReplaceAll(number, " - ", "-"), 30)
+	if client := safeFileComponent(invoice.ClientName, 40); client != "" {
+		name += "_" + client
+	}
+	return name + ".pdf"
+}
+
+// safeFileComponent keeps letters, digits, dashes and underscores, replacing spaces by underscores,
+// so that the name is valid on every file system
+func safeFileComponent(value string, maxLen int) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.TrimSpace(value) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-':
+			b.WriteRune(r)
+			underscore = false
+		case !underscore && b.Len() > 0:
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	runes := []rune(strings.TrimRight(b.String(), "_"))
+	if len(runes) > maxLen {
+		runes = runes[:maxLen]
+	}
+	return strings.TrimRight(string(runes), "_")
+}
+
+// monthDir returns (and creates) the archive folder of the invoice's year and month
+func (s *Service) monthDir(invoice *InvoiceResponse) (string, error) {
+	date, err := time.Parse("02-01-2006", invoice.Date)
+	if err != nil {
+		return "", fmt.Errorf("date de facture invalide: %s", invoice.Date)
+	}
+	dir := filepath.Join(s.ArchiveRoot(), date.Format("2006"), date.Format("01"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier PDF (%s): vérifiez les permissions ou l'espace disque", dir)
+	}
+	return dir, nil
+}
+
+// archiveOriginal keeps a read-only copy of the first PDF issued for an invoice and records its hash.
+// Later regenerations never replace it.
+func (s *Service) archiveOriginal(invoice *InvoiceResponse, pdfPath string) error {
+	if invoice.ArchivePath != "" {
+		return nil
+	}
+
+	dir, err := s.monthDir(invoice)
+	if err != nil {
+		return err
+	}
+	dir = filepath.Join(dir, originalDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("impossible de créer le dossier d'archives (%s): vérifiez les permissions ou l'espace disque", dir)
+	}
+
+	// Never overwrite an archived file, even one left by another invoice with the same name
+	archivePath := filepath.Join(dir, filepath.Base(pdfPath))
+	if _, err := os.Stat(archivePath); err == nil {
+		archivePath = filepath.Join(dir, fmt.Sprintf("%s_%d.pdf", strings.TrimSuffix(filepath.Base(pdfPath), ".pdf"), invoice.ID))
+	}
+
+	if err := copyReadOnly(pdfPath, archivePath); err != nil {
+		return fmt.Errorf("impossible d'archiver le PDF (%s): vérifiez les permissions et l'espace disque disponible", archivePath)
+	}
+	hash, err := fileHash(archivePath)
+	if err != nil {
+		return fmt.Errorf("impossible de calculer l'empreinte du PDF archivé: %w", err)
+	}
+
+	now := time.Now()
+	db := database.GetDB()
+	if err := db.Model(&Invoice{}).Where("id = ?", invoice.ID).Updates(map[string]interface{}{
+		"archive_path": archivePath,
+		"archive_hash": hash,
+		"archived_at":  now,
+	}).Error; err != nil {
+		return fmt.Errorf("échec de l'enregistrement de l'archive: %w", err)
+	}
+
+	invoice.ArchivePath, invoice.ArchiveHash, invoice.ArchivedAt = archivePath, hash, &now
+	return nil
+}
+
+// GetArchivedPDFs lists the archived invoice PDFs of a year (0: all) and month (0: all), checking each file's hash
+func (s *Service) GetArchivedPDFs(year int, month int) ([]ArchivedPDF, error) {
+	db := database.GetDB()
+	query := db.Where("archive_path <> ''")
+	if year != 0 {
+		query = query.Where("year = ?", year)
+	}
+	if month != 0 {
+		query = query.Where("CAST(strftime('%m', date) AS INTEGER) = ?", month)
+	}
+
+	var invoices []Invoice
+	if err := query.Order("date DESC, sequence_number DESC").Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des archives: %w", err)
+	}
+
+	archives := make([]ArchivedPDF, 0, len(invoices))
+	for _, inv := range invoices {
+		formattedID := inv.FormattedID
+		if inv.CustomFormattedID != "" {
+			formattedID = inv.CustomFormattedID
+		}
+		archive := ArchivedPDF{
+			InvoiceID:   inv.ID,
+			FormattedID: formattedID,
+			ClientName:  inv.ClientName,
+			Date:        inv.Date.Format("02-01-2006"),
+			Path:        inv.ArchivePath,
+			Hash:        inv.ArchiveHash,
+		}
+		if inv.ArchivedAt != nil {
+			archive.ArchivedAt = *inv.ArchivedAt
+		}
+		hash, err := fileHash(inv.ArchivePath)
+		archive.Intact = err == nil && hash == inv.ArchiveHash
+		archives = append(archives, archive)
+	}
+	return archives, nil
+}
+
+// ArchivedPDFPath returns the archived PDF of an invoice after checking that it was not altered
+func (s *Service) ArchivedPDFPath(invoiceID uint) (string, error) {
+	db := database.GetDB()
+	var inv Invoice
+	if err := db.First(&inv, invoiceID).Error; err != nil {
+		return "", fmt.Errorf("facture introuvable: %w", err)
+	}
+	if inv.ArchivePath == "" {
+		return "", fmt.Errorf("cette facture n'a pas encore de PDF archivé")
+	}
+
+	hash, err := fileHash(inv.ArchivePath)
+	if err != nil {
+		return "", fmt.Errorf("le PDF archivé est introuvable: %s", inv.ArchivePath)
+	}
+	if hash != inv.ArchiveHash {
+		return "", fmt.Errorf("le PDF archivé a été modifié depuis son émission (empreinte différente): %s", inv.ArchivePath)
+	}
+	return inv.ArchivePath, nil
+}
+
+// fileHash returns the hex SHA-256 of a file
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyReadOnly copies a file and makes the copy read-only
+func copyReadOnly(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0444)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
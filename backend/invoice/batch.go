@@ -0,0 +1,179 @@
+package invoice
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+
+	"github.com/johnfercher/maroto/v2/pkg/merge"
+)
+
+// Batch export formats
+const (
+	BatchZIP    = "ZIP" // One PDF per invoice in a ZIP archive
+	BatchMerged = "PDF" // All invoices in a single PDF, for printing
+)
+
+// BatchExportRequest selects the invoices to export: explicit IDs, or every invoice of a date range
+type BatchExportRequest struct {
+	InvoiceIDs []uint `json:"invoiceIds"` // Takes precedence over the date range
+	From       string `json:"from"`       // DD-MM-YYYY, inclusive
+	To         string `json:"to"`         // DD-MM-YYYY, inclusive
+	Format     string `json:"format"`     // ZIP or PDF
+	TemplateID uint   `json:"templateId"` // 0: default template
+}
+
+// BatchProgress reports the progress of a batch export
+type BatchProgress struct {
+	Done        int    `json:"done"`
+	Total       int    `json:"total"`
+	FormattedID string `json:"formattedId"` // Invoice just processed
+}
+
+// ExportBatch generates the PDFs of several invoices and bundles them in a ZIP or a merged PDF,
+// calling progress after each invoice. It returns the path of the exported file.
+func (s *Service) ExportBatch(req BatchExportRequest, progress func(BatchProgress)) (string, error) {
+	format := strings.ToUpper(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = BatchZIP
+	}
+	if format != BatchZIP && format != BatchMerged {
+		return "", fmt.Errorf("format d'export inconnu: %s (ZIP ou PDF attendu)", req.Format)
+	}
+
+	invoices, label, err := s.batchInvoices(req)
+	if err != nil {
+		return "", err
+	}
+	if len(invoices) == 0 {
+		return "", fmt.Errorf("aucune facture à exporter")
+	}
+
+	exportDir := filepath.Join(s.ArchiveRoot(), "exports")
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier d'export (%s): vérifiez les permissions ou l'espace disque", exportDir)
+	}
+	exportPath := filepath.Join(exportDir, "Factures_"+label)
+
+	// Each invoice is generated like a single PDF, so the first export also archives the original
+	pdfPaths := make([]string, 0, len(invoices))
+	for i, inv := range invoices {
+		pdfPath, err := s.GeneratePDFWithTemplate(inv.ID, req.TemplateID)
+		if err != nil {
+			return "", fmt.Errorf("facture %s: %w", inv.FormattedID, err)
+		}
+		pdfPaths = append(pdfPaths, pdfPath)
+		if progress != nil {
+			progress(BatchProgress{Done: i + 1, Total: len(invoices), FormattedID: inv.FormattedID})
+		}
+	}
+
+	if format == BatchMerged {
+		exportPath += ".pdf"
+		if err := mergePDFs(pdfPaths, exportPath); err != nil {
+			return "", err
+		}
+		return exportPath, nil
+	}
+
+	exportPath += ".zip"
+	if err := zipPDFs(pdfPaths, exportPath); err != nil {
+		return "", err
+	}
+	return exportPath, nil
+}
+
+// batchInvoices loads the selected invoices in chronological order, with a label for the export file name
+func (s *Service) batchInvoices(req BatchExportRequest) ([]Invoice, string, error) {
+	db := database.GetDB()
+	query := db.Where("status <> ?", StatusDraft).Order("date ASC, sequence_number ASC")
+
+	var label string
+	if len(req.InvoiceIDs) > 0 {
+		query = query.Where("id IN ?", req.InvoiceIDs)
+		label = "selection_" + time.Now().Format("20060102_150405")
+	} else {
+		from, err := time.Parse("02-01-2006", req.From)
+		if err != nil {
+			return nil, "", fmt.Errorf("date de début invalide: %s (format JJ-MM-AAAA attendu)", req.From)
+		}
+		to, err := time.Parse("02-01-2006", req.To)
+		if err != nil {
+			return nil, "", fmt.Errorf("date de fin invalide: %s (format JJ-MM-AAAA attendu)", req.To)
+		}
+		if to.Before(from) {
+			return nil, "", fmt.Errorf("la date de fin doit être postérieure à la date de début")
+		}
+		query = query.Where("date >= ? AND date < ?", from, to.AddDate(0, 0, 1))
+		label = from.Format("2006-01-02") + "_" + to.Format("2006-01-02")
+	}
+
+	var invoices []Invoice
+	if err := query.Find(&invoices).Error; err != nil {
+		return nil, "", fmt.Errorf("échec de la lecture des factures: %w", err)
+	}
+	return invoices, label, nil
+}
+
+// mergePDFs concatenates PDFs into a single file
+func mergePDFs(pdfPaths []string, outputPath string) error {
+	pdfs := make([][]byte, 0, len(pdfPaths))
+	for _, pdfPath := range pdfPaths {
+		data, err := os.ReadFile(pdfPath)
+		if err != nil {
+			return fmt.Errorf("impossible de lire le PDF (%s): %w", pdfPath, err)
+		}
+		pdfs = append(pdfs, data)
+	}
+
+	merged := pdfs[0]
+	if len(pdfs) > 1 {
+		var err error
+		merged, err = merge.Bytes(pdfs...)
+		if err != nil {
+			return fmt.Errorf("échec de la fusion des PDF: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(outputPath, merged, 0644); err != nil {
+		return fmt.Errorf("impossible de sauvegarder le PDF (%s): vérifiez les permissions et l'espace disque disponible", outputPath)
+	}
+	return nil
+}
+
+// zipPDFs writes PDFs into a ZIP archive, flat, under their own file names
+func zipPDFs(pdfPaths []string, outputPath string) error {
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("impossible de créer l'archive ZIP (%s): vérifiez les permissions et l'espace disque disponible", outputPath)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	for _, pdfPath := range pdfPaths {
+		data, err := os.ReadFile(pdfPath)
+		if err != nil {
+			return fmt.Errorf("impossible de lire le PDF (%s): %w", pdfPath, err)
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     filepath.Base(pdfPath),
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("échec de l'écriture de l'archive ZIP: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("échec de l'écriture de l'archive ZIP: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("échec de l'écriture de l'archive ZIP: %w", err)
+	}
+	return out.Close()
+}
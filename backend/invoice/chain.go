@@ -0,0 +1,104 @@
+package invoice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// chainHash links a content hash to the hash of the previous invoice of the year
+func chainHash(previousHash, contentHash string) string {
+	sum := sha256.Sum256([]byte(previousHash + "\n" + contentHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// seal records the content hash of an invoice being issued and chains it to previousHash,
+// the chain hash of the previous invoice of the year (empty for the first one)
+func (s *Service) seal(inv *Invoice, previousHash string) {
+	inv.ContentHash = ContentHash(s.toResponse(inv))
+	inv.PreviousHash = previousHash
+	inv.ChainHash = chainHash(previousHash, inv.ContentHash)
+}
+
+// sealExisting chains the invoices issued before sealing existed, year by year in numbering order
+func (s *Service) sealExisting(tx *gorm.DB) error {
+	var invoices []Invoice
+	if err := tx.Preload("Items").Order("year, sequence_number, id").Find(&invoices).Error; err != nil {
+		return fmt.Errorf("échec du chargement des factures à sceller: %w", err)
+	}
+
+	year, previousHash := 0, ""
+	for i := range invoices {
+		inv := &invoices[i]
+		if inv.Year != year {
+			year, previousHash = inv.Year, ""
+		}
+		s.seal(inv, previousHash)
+		if err := tx.Model(&Invoice{}).Where("id = ?", inv.ID).Updates(map[string]interface{}{
+			"content_hash":  inv.ContentHash,
+			"previous_hash": inv.PreviousHash,
+			"chain_hash":    inv.ChainHash,
+		}).Error; err != nil {
+			return fmt.Errorf("échec du scellement de la facture %s: %w", inv.FormattedID, err)
+		}
+		previousHash = inv.ChainHash
+	}
+	return nil
+}
+
+// VerifyInvoiceChain walks the invoices of a year in numbering order and reports the first broken link:
+// a missing number, an invoice whose content changed after issue, or a seal that was rewritten
+func (s *Service) VerifyInvoiceChain(year int) (*ChainVerification, error) {
+	var invoices []Invoice
+	if err := database.GetDB().Preload("Items").Where("year = ? AND status <> ?", year, StatusDraft).
+		Order("sequence_number, id").Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("échec du chargement des factures de %d: %w", year, err)
+	}
+
+	result := &ChainVerification{Year: year, Intact: true}
+	previousHash, expected := "", 1
+	for i := range invoices {
+		inv := &invoices[i]
+		broken := func(reason string) (*ChainVerification, error) {
+			result.Intact = false
+			result.Break = &ChainBreak{
+				InvoiceID:      inv.ID,
+				FormattedID:    inv.FormattedID,
+				SequenceNumber: inv.SequenceNumber,
+				Reason:         reason,
+			}
+			return result, nil
+		}
+
+		switch {
+		case inv.SequenceNumber > expected:
+			result.Intact = false
+			result.Break = &ChainBreak{
+				FormattedID:    fmt.Sprintf("%04d - %d", expected, year),
+				SequenceNumber: expected,
+				Reason:         fmt.Sprintf("facture manquante avant la facture %s", inv.FormattedID),
+			}
+			return result, nil
+		case inv.SequenceNumber < expected:
+			return broken("numéro en double")
+		case inv.ChainHash == "":
+			return broken("facture non scellée")
+		case inv.PreviousHash != previousHash:
+			return broken("le lien vers la facture précédente ne correspond pas")
+		case ContentHash(s.toResponse(inv)) != inv.ContentHash:
+			return broken("le contenu de la facture a été modifié après son émission")
+		case chainHash(inv.PreviousHash, inv.ContentHash) != inv.ChainHash:
+			return broken("le sceau de la facture a été réécrit")
+		}
+
+		previousHash = inv.ChainHash
+		expected++
+		result.Checked++
+		result.HeadHash = inv.ChainHash
+	}
+	return result, nil
+}
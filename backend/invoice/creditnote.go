@@ -0,0 +1,295 @@
+package invoice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+	"factureapp/backend/money"
+
+	"gorm.io/gorm"
+)
+
+// quantityEpsilon absorbs float rounding when comparing credited quantities
+const quantityEpsilon = 1e-9
+
+// CreateCreditNote credits all or part of an issued invoice, optionally returning the goods to stock.
+// Lines are credited at their invoiced price net of the global discount; once every line is fully
+// credited the invoice is cancelled.
+func (s *Service) CreateCreditNote(invoiceID uint, req CreditNoteRequest) (*CreditNote, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("le motif de l'avoir est obligatoire")
+	}
+	date := today()
+	if strings.TrimSpace(req.Date) != "" {
+		var err error
+		date, err = time.Parse("02-01-2006", strings.TrimSpace(req.Date))
+		if err != nil {
+			return nil, fmt.Errorf("date d'avoir invalide: %s (format JJ-MM-AAAA attendu)", req.Date)
+		}
+	}
+
+	var note *CreditNote
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var inv Invoice
+		if err := tx.Preload("Items.Lots").First(&inv, invoiceID).Error; err != nil {
+			return fmt.Errorf("facture introuvable: %w", err)
+		}
+		var err error
+		note, err = s.creditInvoice(tx, &inv, date, reason, req.Lines, req.Restock)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// creditInvoice creates, within a transaction, a credit note for lines of an invoice loaded with its items
+func (s *Service) creditInvoice(tx *gorm.DB, inv *Invoice, date time.Time, reason string, lines []CreditNoteLineRequest, restock bool) (*CreditNote, error) {
+	switch inv.Status {
+	case StatusDraft:
+		return nil, fmt.Errorf("un brouillon n'a pas d'avoir: modifiez-le ou supprimez-le")
+	case StatusCancelled:
+		return nil, fmt.Errorf("la facture %s est déjà annulée", inv.FormattedID)
+	}
+	if date.Before(inv.Date) {
+		return nil, fmt.Errorf("la date de l'avoir ne peut pas précéder la date de la facture")
+	}
+
+	credited, err := creditedQuantities(tx, inv.ID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := creditLines(inv, credited, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fully credited once no line has a remaining quantity
+	full := true
+	for _, item := range inv.Items {
+		if item.Quantity-credited[item.ID] > quantityEpsilon {
+			full = false
+			break
+		}
+	}
+
+	note := &CreditNote{
+		Date:          date,
+		Year:          date.Year(),
+		InvoiceID:     inv.ID,
+		InvoiceNumber: displayNumber(s.toResponse(inv)),
+		ClientName:    inv.ClientName,
+		ClientICE:     inv.ClientICE,
+		Reason:        reason,
+		Restocked:     restock,
+		Currency:      inv.Currency,
+		Items:         items,
+	}
+	if err := creditTotals(tx, inv, note, full); err != nil {
+		return nil, err
+	}
+
+	var last CreditNote
+	tx.Where("year = ?", note.Year).Order("sequence_number DESC").First(&last)
+	note.SequenceNumber = last.SequenceNumber + 1
+	note.FormattedID = fmt.Sprintf("AV %04d - %d", note.SequenceNumber, note.Year)
+
+	if restock {
+		for i, item := range items {
+			if err := s.restockLine(tx, inv, item); err != nil {
+				return nil, fmt.Errorf("échec de la remise en stock de l'article %s: %w", item.Description, err)
+			}
+			items[i].Restocked = true
+		}
+	}
+
+	if err := tx.Create(note).Error; err != nil {
+		return nil, fmt.Errorf("échec de la création de l'avoir: %w", err)
+	}
+	if full {
+		if err := tx.Model(&Invoice{}).Where("id = ?", inv.ID).Update("status", StatusCancelled).Error; err != nil {
+			return nil, fmt.Errorf("échec de l'annulation de la facture: %w", err)
+		}
+	}
+	return note, nil
+}
+
+// restockLine returns the credited goods of a line to the stock of the invoice's location and to the lots
+// they were taken from, the last lot allocated first
+func (s *Service) restockLine(tx *gorm.DB, inv *Invoice, item CreditNoteItem) error {
+	quantity := int(item.Quantity)
+	if err := s.inventoryService.IncreaseStockAt(tx, item.ProductID, inv.LocationID, quantity); err != nil {
+		return err
+	}
+	for _, invoiceItem := range inv.Items {
+		if invoiceItem.ID != item.InvoiceItemID {
+			continue
+		}
+		for i := len(invoiceItem.Lots) - 1; i >= 0 && quantity > 0; i-- {
+			lot := &invoiceItem.Lots[i]
+			back := min(lot.Quantity-lot.Returned, quantity)
+			if back <= 0 {
+				continue
+			}
+			if err := s.inventoryService.ReturnToLot(tx, lot.LotID, back); err != nil {
+				return err
+			}
+			lot.Returned += back
+			if err := tx.Model(lot).Update("returned", lot.Returned).Error; err != nil {
+				return fmt.Errorf("échec de la mise à jour du lot %s: %w", lot.LotNumber, err)
+			}
+			quantity -= back
+		}
+	}
+	return nil
+}
+
+// CancelInvoice cancels an issued invoice with a credit note for everything not yet credited,
+// returning the goods to stock
+func (s *Service) CancelInvoice(id uint, reason string) (*CreditNote, error) {
+	return s.CreateCreditNote(id, CreditNoteRequest{Reason: reason, Restock: true})
+}
+
+// GetCreditNotes returns the credit notes of an invoice, oldest first
+func (s *Service) GetCreditNotes(invoiceID uint) ([]CreditNote, error) {
+	var notes []CreditNote
+	if err := database.GetDB().Preload("Items").Where("invoice_id = ?", invoiceID).
+		Order("date, sequence_number").Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des avoirs: %w", err)
+	}
+	return notes, nil
+}
+
+// creditedQuantities returns the quantity already credited on each line of an invoice
+func creditedQuantities(tx *gorm.DB, invoiceID uint) (map[uint]float64, error) {
+	var rows []struct {
+		InvoiceItemID uint
+		Quantity      float64
+	}
+	if err := tx.Table("credit_note_items").
+		Select("credit_note_items.invoice_item_id, SUM(credit_note_items.quantity) AS quantity").
+		Joins("JOIN credit_notes ON credit_notes.id = credit_note_items.credit_note_id").
+		Where("credit_notes.invoice_id = ? AND credit_notes.deleted_at IS NULL", invoiceID).
+		Group("credit_note_items.invoice_item_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des quantités déjà créditées: %w", err)
+	}
+	credited := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		credited[row.InvoiceItemID] = row.Quantity
+	}
+	return credited, nil
+}
+
+// creditedAmount is the TTC total credited on an invoice, in its currency and in MAD
+type creditedAmount struct {
+	InvoiceID   uint
+	TotalTTC    money.Amount
+	TotalTTCMAD money.Amount `gorm:"column:total_ttc_mad"`
+}
+
+// creditedAmounts returns the TTC total already credited on each of the given invoices
+func creditedAmounts(tx *gorm.DB, invoiceIDs []uint) (map[uint]creditedAmount, error) {
+	credited := make(map[uint]creditedAmount, len(invoiceIDs))
+	if len(invoiceIDs) == 0 {
+		return credited, nil
+	}
+	var rows []creditedAmount
+	if err := tx.Model(&CreditNote{}).
+		Select("invoice_id, SUM(total_ttc) AS total_ttc, SUM(total_ttc_mad) AS total_ttc_mad").
+		Where("invoice_id IN ?", invoiceIDs).
+		Group("invoice_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des avoirs: %w", err)
+	}
+	for _, row := range rows {
+		credited[row.InvoiceID] = row
+	}
+	return credited, nil
+}
+
+// creditLines checks the requested quantities against what remains to credit on each invoice line
+// and adds them to credited; no requested line credits everything remaining
+func creditLines(inv *Invoice, credited map[uint]float64, lines []CreditNoteLineRequest) ([]CreditNoteItem, error) {
+	if len(lines) == 0 {
+		for _, item := range inv.Items {
+			if remaining := item.Quantity - credited[item.ID]; remaining > quantityEpsilon {
+				lines = append(lines, CreditNoteLineRequest{InvoiceItemID: item.ID, Quantity: remaining})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("la facture %s est déjà entièrement créditée", inv.FormattedID)
+		}
+	}
+
+	byID := make(map[uint]InvoiceItem, len(inv.Items))
+	for _, item := range inv.Items {
+		byID[item.ID] = item
+	}
+
+	items := make([]CreditNoteItem, 0, len(lines))
+	for i, line := range lines {
+		item, ok := byID[line.InvoiceItemID]
+		if !ok {
+			return nil, fmt.Errorf("ligne %d: l'article n'appartient pas à la facture %s", i+1, inv.FormattedID)
+		}
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("ligne %d: la quantité à créditer doit être supérieure à 0", i+1)
+		}
+		remaining := item.Quantity - credited[item.ID]
+		if line.Quantity-remaining > quantityEpsilon {
+			return nil, fmt.Errorf("article %s: %g à créditer, il ne reste que %g sur les %g facturés",
+				item.Description, line.Quantity, remaining, item.Quantity)
+		}
+		credited[item.ID] += line.Quantity
+
+		share := line.Quantity / item.Quantity
+		items = append(items, CreditNoteItem{
+			InvoiceItemID: item.ID,
+			ProductID:     item.ProductID,
+			Description:   item.Description,
+			Quantity:      line.Quantity,
+			TotalHT:       item.TotalHT.Mul(share),
+			TotalTTC:      item.TotalTTC.Mul(share),
+		})
+	}
+	return items, nil
+}
+
+// creditTotals sets the credited totals: the lines net of the invoice's global discount, or, for the
+// credit note completing the cancellation, what remains of the invoice totals so that they balance exactly
+func creditTotals(tx *gorm.DB, inv *Invoice, note *CreditNote, full bool) error {
+	if full {
+		var previous struct {
+			TotalHT  money.Amount
+			TotalTTC money.Amount
+		}
+		if err := tx.Model(&CreditNote{}).Where("invoice_id = ?", inv.ID).
+			Select("COALESCE(SUM(total_ht), 0) AS total_ht, COALESCE(SUM(total_ttc), 0) AS total_ttc").
+			Scan(&previous).Error; err != nil {
+			return fmt.Errorf("échec de la lecture des avoirs précédents: %w", err)
+		}
+		note.TotalHT = inv.TotalHT - previous.TotalHT
+		note.TotalTTC = inv.TotalTTC - previous.TotalTTC
+	} else {
+		var linesHT, linesTTC money.Amount
+		for _, item := range note.Items {
+			linesHT += item.TotalHT
+			linesTTC += item.TotalTTC
+		}
+		note.TotalHT, note.TotalTTC = linesHT, linesTTC
+		if inv.SubtotalHT > 0 {
+			note.TotalHT = linesHT.Mul(inv.TotalHT.Float() / inv.SubtotalHT.Float())
+		}
+		if inv.SubtotalTTC > 0 {
+			note.TotalTTC = linesTTC.Mul(inv.TotalTTC.Float() / inv.SubtotalTTC.Float())
+		}
+	}
+	note.TotalTVA = note.TotalTTC - note.TotalHT
+	note.TotalHTMAD, note.TotalTVAMAD, note.TotalTTCMAD = madTotals(note.TotalHT, note.TotalTTC, inv.ExchangeRate)
+	return nil
+}
@@ -0,0 +1,139 @@
+package invoice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/currency"
+	"factureapp/backend/database"
+	"factureapp/backend/inventory"
+	"factureapp/backend/money"
+)
+
+// DuplicateInvoice copies an invoice into a new invoice request dated newDate (DD-MM-YYYY, empty: today).
+// Client, terms, lines and discounts are kept; the number, payment details and due date are left for
+// the new invoice. With refreshPrices, unit prices come from the current selling price of each product.
+// Nothing is saved: the request is returned for editing, with warnings on the current stock of its location.
+func (s *Service) DuplicateInvoice(id uint, newDate string, refreshPrices bool) (*InvoiceDuplicate, error) {
+	date := today()
+	if strings.TrimSpace(newDate) != "" {
+		var err error
+		date, err = time.Parse("02-01-2006", strings.TrimSpace(newDate))
+		if err != nil {
+			return nil, fmt.Errorf("date invalide: %s (format JJ-MM-AAAA attendu)", newDate)
+		}
+	}
+
+	source, err := s.GetInvoiceByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tvaExempt := source.TVAExempt
+	req := InvoiceCreateRequest{
+		Date:            date.Format("02-01-2006"),
+		ClientName:      source.ClientName,
+		ClientCity:      source.ClientCity,
+		ClientICE:       source.ClientICE,
+		PaymentMethod:   source.PaymentMethod,
+		PricingBasis:    source.PricingBasis,
+		Currency:        source.Currency,
+		TVAExempt:       &tvaExempt,
+		ExemptionReason: source.ExemptionReason,
+		Language:        source.Language,
+		LocationID:      source.LocationID,
+		DiscountType:    source.DiscountType,
+		DiscountValue:   source.DiscountValue,
+	}
+	// Foreign-currency invoices take the rate of the new date
+	if source.Currency == currency.MAD {
+		req.ExchangeRate = 1
+	}
+
+	duplicate := &InvoiceDuplicate{SourceID: source.ID, SourceNumber: displayNumber(source)}
+	exchangeRate := 1.0
+	if refreshPrices && source.Currency != currency.MAD {
+		if exchangeRate, err = s.currencyService.RateAt(source.Currency, date); err != nil {
+			refreshPrices = false
+			duplicate.Warnings = append(duplicate.Warnings, fmt.Sprintf("prix d'origine conservés: %v", err))
+		}
+	}
+
+	// Stock is checked once per product, on the quantity of all its lines
+	requested := make(map[uint]float64)
+	products := make(map[uint]*inventory.Product)
+	for i, item := range source.Items {
+		line := InvoiceItemRequest{
+			ProductID:     item.ProductID,
+			Description:   item.Description,
+			Quantity:      item.Quantity,
+			PrixUnitTTC:   item.PrixUnitTTC,
+			PrixUnitHT:    item.PrixUnitHT,
+			DiscountType:  item.DiscountType,
+			DiscountValue: item.DiscountValue,
+		}
+		req.Items = append(req.Items, line)
+
+		product, seen := products[item.ProductID]
+		if !seen {
+			product = &inventory.Product{}
+			if err := database.GetDB().First(product, item.ProductID).Error; err != nil {
+				product = nil
+			}
+			products[item.ProductID] = product
+		}
+		if product == nil {
+			duplicate.Warnings = append(duplicate.Warnings, fmt.Sprintf("article %d (%s): le produit n'existe plus, retirez la ligne", i+1, item.Description))
+			continue
+		}
+		requested[item.ProductID] += item.Quantity
+		if refreshPrices {
+			refreshPrice(&req.Items[i], product.SellingPriceTTC, exchangeRate, tvaExempt)
+		}
+	}
+	for i, item := range req.Items {
+		quantity, ok := requested[item.ProductID]
+		if !ok {
+			continue
+		}
+		delete(requested, item.ProductID)
+		stock, err := s.inventoryService.StockAt(item.ProductID, source.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		if quantity > float64(stock) {
+			duplicate.Warnings = append(duplicate.Warnings, fmt.Sprintf("article %d (%s): stock insuffisant (demandé: %g, disponible: %d)",
+				i+1, item.Description, quantity, stock))
+		}
+	}
+
+	duplicate.Request = req
+	return duplicate, nil
+}
+
+// DuplicateAsDraft duplicates an invoice and saves the copy as a draft, keeping the duplication warnings
+func (s *Service) DuplicateAsDraft(id uint, newDate string, refreshPrices bool) (*InvoiceResponse, error) {
+	duplicate, err := s.DuplicateInvoice(id, newDate, refreshPrices)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.CreateDraft(duplicate.Request)
+	if err != nil {
+		return nil, err
+	}
+	resp.Warnings = append(duplicate.Warnings, resp.Warnings...)
+	return resp, nil
+}
+
+// refreshPrice sets the unit prices of a line from a product's selling price TTC in MAD.
+// Exempt invoices are charged the price excluding TVA.
+func refreshPrice(line *InvoiceItemRequest, sellingPriceTTC money.Amount, exchangeRate float64, tvaExempt bool) {
+	ttc := sellingPriceTTC.Div(exchangeRate)
+	ht := sellingPriceTTC.Div(exchangeRate * (1 + TVARate/100))
+	line.PrixUnitHT = ht
+	line.PrixUnitTTC = ttc
+	if tvaExempt {
+		line.PrixUnitTTC = ht
+	}
+}
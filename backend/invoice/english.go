@@ -0,0 +1,59 @@
+package invoice
+
+import (
+	"strings"
+)
+
+var (
+	englishUnits = []string{"", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+	englishTeens = []string{"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens  = []string{"", "ten", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+)
+
+// IntToEnglish converts an integer to English words
+func IntToEnglish(n int) string {
+	if n == 0 {
+		return "zero"
+	}
+
+	if n < 0 {
+		return "minus " + IntToEnglish(-n)
+	}
+
+	parts := []string{}
+
+	scales := []struct {
+		value int
+		name  string
+	}{
+		{1000000000, "billion"},
+		{1000000, "million"},
+		{1000, "thousand"},
+	}
+	for _, scale := range scales {
+		if n >= scale.value {
+			parts = append(parts, IntToEnglish(n/scale.value)+" "+scale.name)
+			n %= scale.value
+		}
+	}
+
+	// Hundreds
+	if n >= 100 {
+		parts = append(parts, englishUnits[n/100]+" hundred")
+		n %= 100
+	}
+
+	if n > 0 {
+		if n < 10 {
+			parts = append(parts, englishUnits[n])
+		} else if n < 20 {
+			parts = append(parts, englishTeens[n-10])
+		} else if n%10 == 0 {
+			parts = append(parts, englishTens[n/10])
+		} else {
+			parts = append(parts, englishTens[n/10]+"-"+englishUnits[n%10])
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
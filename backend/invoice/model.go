@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"factureapp/backend/inventory"
+	"factureapp/backend/money"
 
 	"gorm.io/gorm"
 )
@@ -24,41 +25,113 @@ type EffetInfo struct {
 	Reference    string `json:"reference"`
 }
 
+// Discount types, for line and invoice discounts (remises)
+const (
+	DiscountPercent = "PERCENT" // DiscountValue is a percentage (0-100)
+	DiscountAmount  = "AMOUNT"  // DiscountValue is a fixed amount in DH, in the invoice's pricing basis
+)
+
+// Pricing bases: whether unit prices are entered TTC (HT back-computed) or HT (TVA added)
+const (
+	PricingTTC = "TTC"
+	PricingHT  = "HT"
+)
+
+// Invoice statuses: a draft reserves no number and no stock until it is issued; an issued invoice
+// is only corrected with a credit note, and is cancelled once fully credited
+const (
+	StatusDraft     = "DRAFT"
+	StatusIssued    = "ISSUED"
+	StatusPaid      = "PAID"
+	StatusCancelled = "CANCELLED"
+)
+
 // InvoiceItem represents a single line item on an invoice
 type InvoiceItem struct {
 	ID          uint              `gorm:"primaryKey" json:"id"`
 	InvoiceID   uint              `gorm:"index" json:"invoiceId"`
-	ProductID   uint              `json:"productId"` // Foreign Key to Inventory
+	ProductID   uint              `gorm:"index" json:"productId"` // Foreign Key to Inventory
 	Product     inventory.Product `json:"product"`
 	Description string            `json:"description"`
 	Quantity    float64           `json:"quantity"`
-	BuyingPrice float64           `json:"buyingPrice"` // Snapshot of product buying price at time of sale
-	PrixUnitTTC float64           `json:"prixUnitTTC"`
-	TotalTTC    float64           `json:"totalTTC"`
+	BuyingPrice money.Amount      `json:"buyingPrice"` // Snapshot of product buying price at time of sale
+	PrixUnitTTC money.Amount      `json:"prixUnitTTC"`
+	PrixUnitHT  money.Amount      `json:"prixUnitHT"` // Entered price on HT invoices, derived from the TTC price otherwise
+	GrossTTC    money.Amount      `json:"grossTTC"`   // Quantity x PrixUnitTTC, before the line discount
+
+	DiscountType   string       `json:"discountType,omitempty"` // PERCENT, AMOUNT or empty
+	DiscountValue  float64      `json:"discountValue"`
+	DiscountAmount money.Amount `json:"discountAmount"` // Resolved discount in DH, in the invoice's pricing basis
+
+	TotalHT  money.Amount `json:"totalHT"`  // Net of the line discount
+	TotalTTC money.Amount `json:"totalTTC"` // Net of the line discount
+
+	Lots []InvoiceItemLot `gorm:"foreignKey:InvoiceItemID" json:"lots,omitempty"` // Lots the goods were taken from when issued
+}
+
+// InvoiceItemLot is the quantity of a stock lot delivered on an invoice line
+type InvoiceItemLot struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	InvoiceItemID uint       `gorm:"index" json:"invoiceItemId"`
+	LotID         uint       `gorm:"index" json:"lotId"`
+	LotNumber     string     `json:"lotNumber"`
+	ExpiryDate    *time.Time `json:"expiryDate,omitempty"`
+	Quantity      int        `json:"quantity"`
+	Returned      int        `json:"returned"` // Put back into the lot by credit notes and returns
 }
 
 // Invoice represents the main invoice entity
 type Invoice struct {
 	gorm.Model
-	FormattedID       string    `gorm:"uniqueIndex;size:15" json:"formattedId"` // Format: "0001 - 2025"
-	CustomFormattedID string    `json:"customFormattedId"`                      // Optional custom override
-	SequenceNumber    int       `json:"sequenceNumber"`
-	Year              int       `json:"year"`
-	Date              time.Time `json:"date"`
+	FormattedID       string    `gorm:"uniqueIndex:idx_invoices_number,where:formatted_id <> '';size:15" json:"formattedId"` // Format: "0001 - 2025", empty on drafts
+	CustomFormattedID string    `json:"customFormattedId"`                                                                   // Optional custom override
+	Status            string    `gorm:"size:10;default:ISSUED;index" json:"status"`                                          // DRAFT, ISSUED, PAID or CANCELLED
+	SequenceNumber    int       `gorm:"index:idx_invoices_year_sequence,priority:2" json:"sequenceNumber"`
+	Year              int       `gorm:"index:idx_invoices_year_sequence,priority:1" json:"year"`
+	Date              time.Time `gorm:"index" json:"date"`
 
 	// Client information
 	ClientName string `json:"clientName"`
 	ClientCity string `json:"clientCity"`
-	ClientICE  string `gorm:"size:15" json:"clientIce"` // 15 characters validation
+	ClientICE  string `gorm:"size:15;index" json:"clientIce"` // 15 characters validation
+
+	// Pricing basis: TTC (HT back-computed from the total) or HT (TVA added to the total)
+	PricingBasis string `gorm:"size:3;default:TTC" json:"pricingBasis"`
+
+	// Currency of all the invoice amounts; the exchange rate is captured at the invoice date
+	Currency     string  `gorm:"size:3;default:MAD" json:"currency"`
+	ExchangeRate float64 `gorm:"default:1" json:"exchangeRate"` // MAD for 1 unit of Currency
+
+	// TVA exemption (exports): no TVA is charged and the legal reason is printed
+	TVAExempt       bool   `json:"tvaExempt"`
+	ExemptionReason string `json:"exemptionReason,omitempty"`
+
+	// Language of the printed invoice and amount in words: fr, en, ar or fr-ar (bilingual)
+	Language string `gorm:"size:5;default:fr" json:"language"`
+
+	// Stock location the goods are drawn from (0: the default location)
+	LocationID uint `json:"locationId"`
+
+	// Global discount, applied to the sum of the lines before the TVA split
+	SubtotalHT     money.Amount `json:"subtotalHT"`  // Sum of net line totals HT
+	SubtotalTTC    money.Amount `json:"subtotalTTC"` // Sum of net line totals TTC
+	DiscountType   string       `json:"discountType,omitempty"`
+	DiscountValue  float64      `json:"discountValue"`
+	DiscountAmount money.Amount `json:"discountAmount"`
 
 	// Calculated totals
-	TotalHT      float64 `json:"totalHT"`
-	TotalTVA     float64 `json:"totalTVA"`
-	TotalTTC     float64 `json:"totalTTC"`
-	TotalInWords string  `json:"totalInWords"`
+	TotalHT      money.Amount `json:"totalHT"`
+	TotalTVA     money.Amount `json:"totalTVA"`
+	TotalTTC     money.Amount `json:"totalTTC"`
+	TotalInWords string       `json:"totalInWords"`
+
+	// MAD equivalents of the totals, used by stats and accounting exports
+	TotalHTMAD  money.Amount `gorm:"column:total_ht_mad" json:"totalHTMAD"`
+	TotalTVAMAD money.Amount `gorm:"column:total_tva_mad" json:"totalTVAMAD"`
+	TotalTTCMAD money.Amount `gorm:"column:total_ttc_mad;index" json:"totalTTCMAD"`
 
 	// Payment information
-	PaymentMethod string `json:"paymentMethod"` // CHEQUE, EFFET, ESPECE
+	PaymentMethod string `gorm:"index" json:"paymentMethod"` // CHEQUE, EFFET, ESPECE
 
 	// Embedded structs for payment details (stored as JSON)
 	ChequeNumber    string `json:"chequeNumber,omitempty"`
@@ -71,6 +144,20 @@ type Invoice struct {
 	EffetBank         string `json:"effetBank,omitempty"`
 	EffetReference    string `json:"effetReference,omitempty"`
 
+	// Payment follow-up: unpaid invoices past their due date are overdue and can be reminded
+	DueDate *time.Time `gorm:"index" json:"dueDate"`
+	PaidAt  *time.Time `json:"paidAt"`
+
+	// Tamper-evident seal set at issue: the content hash, chained to the previous invoice of the year
+	ContentHash  string `gorm:"size:64" json:"contentHash,omitempty"`
+	PreviousHash string `gorm:"size:64" json:"previousHash,omitempty"`
+	ChainHash    string `gorm:"size:64" json:"chainHash,omitempty"`
+
+	// Immutable copy of the first PDF issued, with its SHA-256 hash
+	ArchivePath string     `json:"archivePath,omitempty"`
+	ArchiveHash string     `gorm:"size:64" json:"archiveHash,omitempty"`
+	ArchivedAt  *time.Time `json:"archivedAt,omitempty"`
+
 	// Related items
 	Items []InvoiceItem `gorm:"foreignKey:InvoiceID" json:"items"`
 }
@@ -83,38 +170,337 @@ type InvoiceCreateRequest struct {
 	ClientCity        string `json:"clientCity"`
 	ClientICE         string `json:"clientIce"`
 	PaymentMethod     string `json:"paymentMethod"`
+	PricingBasis      string `json:"pricingBasis"` // TTC or HT; empty uses the client's basis
+
+	// Currency and TVA exemption; empty values use the client's defaults
+	Currency        string  `json:"currency"`
+	ExchangeRate    float64 `json:"exchangeRate"` // 0 uses the rate recorded for the invoice date
+	TVAExempt       *bool   `json:"tvaExempt"`
+	ExemptionReason string  `json:"exemptionReason"`
+	Language        string  `json:"language"`   // fr, en, ar or fr-ar; empty uses the client's language
+	LocationID      uint    `json:"locationId"` // Stock location to draw from; 0 uses the default location
+
+	// Global discount
+	DiscountType  string  `json:"discountType"`
+	DiscountValue float64 `json:"discountValue"`
 
 	// Payment details
 	ChequeInfo *ChequeInfo `json:"chequeInfo,omitempty"`
 	EffetInfo  *EffetInfo  `json:"effetInfo,omitempty"`
 
+	// Due date, DD-MM-YYYY; empty uses the effet due date, else the client's payment terms
+	DueDate string `json:"dueDate"`
+
 	// Items
 	Items []InvoiceItemRequest `json:"items"`
 }
 
 // InvoiceItemRequest is the DTO for invoice items
 type InvoiceItemRequest struct {
-	ProductID   uint    `json:"productId"`
-	Description string  `json:"description"`
-	Quantity    float64 `json:"quantity"`
-	PrixUnitTTC float64 `json:"prixUnitTTC"`
+	ProductID   uint         `json:"productId"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity"`
+	PrixUnitTTC money.Amount `json:"prixUnitTTC"` // Used on TTC invoices
+	PrixUnitHT  money.Amount `json:"prixUnitHT"`  // Used on HT invoices
+
+	DiscountType  string  `json:"discountType"`
+	DiscountValue float64 `json:"discountValue"`
+}
+
+// InvoiceDuplicate is a copy of an invoice, ready to be edited and saved as a new invoice
+type InvoiceDuplicate struct {
+	SourceID     uint                 `json:"sourceId"`
+	SourceNumber string               `json:"sourceNumber"` // Empty when the source is a draft
+	Request      InvoiceCreateRequest `json:"request"`
+	Warnings     []string             `json:"warnings,omitempty"` // Stock shortages and prices left unchanged
 }
 
 // InvoiceResponse is the response DTO
 type InvoiceResponse struct {
 	ID                uint          `json:"id"`
 	FormattedID       string        `json:"formattedId"`
+	Status            string        `json:"status"`
 	CustomFormattedID string        `json:"customFormattedId"`
 	Date              string        `json:"date"`
 	ClientName        string        `json:"clientName"`
 	ClientCity        string        `json:"clientCity"`
 	ClientICE         string        `json:"clientIce"`
-	TotalHT           float64       `json:"totalHT"`
-	TotalTVA          float64       `json:"totalTVA"`
-	TotalTTC          float64       `json:"totalTTC"`
+	PricingBasis      string        `json:"pricingBasis"`
+	Currency          string        `json:"currency"`
+	ExchangeRate      float64       `json:"exchangeRate"`
+	TVAExempt         bool          `json:"tvaExempt"`
+	ExemptionReason   string        `json:"exemptionReason,omitempty"`
+	Language          string        `json:"language"`
+	LocationID        uint          `json:"locationId"`
+	SubtotalHT        money.Amount  `json:"subtotalHT"`
+	SubtotalTTC       money.Amount  `json:"subtotalTTC"`
+	DiscountType      string        `json:"discountType,omitempty"`
+	DiscountValue     float64       `json:"discountValue"`
+	DiscountAmount    money.Amount  `json:"discountAmount"`
+	TotalHT           money.Amount  `json:"totalHT"`
+	TotalTVA          money.Amount  `json:"totalTVA"`
+	TotalTTC          money.Amount  `json:"totalTTC"`
 	TotalInWords      string        `json:"totalInWords"`
+	TotalHTMAD        money.Amount  `json:"totalHTMAD"`
+	TotalTVAMAD       money.Amount  `json:"totalTVAMAD"`
+	TotalTTCMAD       money.Amount  `json:"totalTTCMAD"`
 	PaymentMethod     string        `json:"paymentMethod"`
 	ChequeInfo        *ChequeInfo   `json:"chequeInfo,omitempty"`
 	EffetInfo         *EffetInfo    `json:"effetInfo,omitempty"`
+	DueDate           string        `json:"dueDate"`          // DD-MM-YYYY
+	PaidAt            string        `json:"paidAt,omitempty"` // DD-MM-YYYY, empty while unpaid
+	ArchivePath       string        `json:"archivePath,omitempty"`
+	ArchiveHash       string        `json:"archiveHash,omitempty"`
+	ArchivedAt        *time.Time    `json:"archivedAt,omitempty"`
 	Items             []InvoiceItem `json:"items"`
+	Warnings          []string      `json:"warnings,omitempty"` // Non-blocking pricing warnings raised at save time
+}
+
+// ArchivedPDF describes the archived PDF of an invoice
+type ArchivedPDF struct {
+	InvoiceID   uint      `json:"invoiceId"`
+	FormattedID string    `json:"formattedId"`
+	ClientName  string    `json:"clientName"`
+	Date        string    `json:"date"` // Invoice date, DD-MM-YYYY
+	Path        string    `json:"path"`
+	Hash        string    `json:"hash"`
+	ArchivedAt  time.Time `json:"archivedAt"`
+	Intact      bool      `json:"intact"` // The file exists and still matches its hash
+}
+
+// Reminder levels, from a courteous reminder to a formal notice
+const (
+	ReminderFirst        = 1 // 1ère relance
+	ReminderSecond       = 2 // 2ème relance
+	ReminderFormalNotice = 3 // Mise en demeure
+)
+
+// Reminder channels
+const (
+	ReminderPDF   = "PDF"   // Letter printed or sent by post
+	ReminderEmail = "EMAIL" // Letter sent by email
+)
+
+// Reminder records a reminder letter issued for an overdue invoice
+type Reminder struct {
+	gorm.Model
+	InvoiceID   uint         `gorm:"index" json:"invoiceId"`
+	Level       int          `json:"level"`   // 1, 2 or 3 (mise en demeure)
+	Title       string       `json:"title"`   // e.g. "1ère relance"
+	Channel     string       `json:"channel"` // PDF or EMAIL
+	SentTo      string       `json:"sentTo,omitempty"`
+	Subject     string       `json:"subject"`
+	Body        string       `json:"body"` // Letter text, reused as the email message
+	PDFPath     string       `json:"pdfPath"`
+	AmountDue   money.Amount `json:"amountDue"`
+	Currency    string       `json:"currency"`
+	DaysOverdue int          `json:"daysOverdue"`
+}
+
+// OverdueInvoice is an unpaid invoice past its due date, for the dashboard
+type OverdueInvoice struct {
+	InvoiceID         uint         `json:"invoiceId"`
+	FormattedID       string       `json:"formattedId"`
+	ClientName        string       `json:"clientName"`
+	ClientICE         string       `json:"clientIce"`
+	Date              string       `json:"date"`    // DD-MM-YYYY
+	DueDate           string       `json:"dueDate"` // DD-MM-YYYY
+	DaysOverdue       int          `json:"daysOverdue"`
+	AmountDue         money.Amount `json:"amountDue"` // In the invoice currency
+	Currency          string       `json:"currency"`
+	AmountDueMAD      money.Amount `json:"amountDueMAD"`
+	LastReminderLevel int          `json:"lastReminderLevel"` // 0: never reminded
+	LastReminderAt    *time.Time   `json:"lastReminderAt,omitempty"`
+	NextReminderLevel int          `json:"nextReminderLevel"`
+}
+
+// Outcomes of the verification of a scanned invoice QR code
+const (
+	QRValid       = "VALID"        // The invoice exists and matches the printed copy
+	QRModified    = "MODIFIED"     // The invoice exists but differs from the printed copy
+	QRUnknown     = "UNKNOWN"      // No invoice with this number
+	QROtherSeller = "OTHER_SELLER" // Issued under another company ICE
+)
+
+// QRVerification is the result of checking a scanned invoice QR code against the database
+type QRVerification struct {
+	Status      string       `json:"status"`
+	Message     string       `json:"message"`
+	Differences []string     `json:"differences,omitempty"` // Printed values that no longer match
+	InvoiceID   uint         `json:"invoiceId,omitempty"`
+	FormattedID string       `json:"formattedId"`
+	ClientName  string       `json:"clientName,omitempty"` // From the database, to compare with the paper copy
+	Date        string       `json:"date,omitempty"`       // DD-MM-YYYY, from the database
+	TotalTTC    money.Amount `json:"totalTTC"`             // From the database
+	Currency    string       `json:"currency,omitempty"`
+}
+
+// ChainBreak is the first invoice of a year whose seal does not match
+type ChainBreak struct {
+	InvoiceID      uint   `json:"invoiceId,omitempty"` // 0 when the invoice is missing
+	FormattedID    string `json:"formattedId"`
+	SequenceNumber int    `json:"sequenceNumber"`
+	Reason         string `json:"reason"`
+}
+
+// ChainVerification is the result of checking the hash chain of a year's invoices
+type ChainVerification struct {
+	Year     int         `json:"year"`
+	Checked  int         `json:"checked"` // Invoices verified before the first broken link
+	Intact   bool        `json:"intact"`
+	HeadHash string      `json:"headHash"` // Chain hash of the last invoice verified, to note down outside the application
+	Break    *ChainBreak `json:"break,omitempty"`
+}
+
+// CreditNote (avoir) credits all or part of an issued invoice; an invoice fully credited is cancelled
+type CreditNote struct {
+	gorm.Model
+	FormattedID    string    `gorm:"uniqueIndex;size:20" json:"formattedId"` // Format: "AV 0001 - 2025"
+	SequenceNumber int       `json:"sequenceNumber"`
+	Year           int       `json:"year"`
+	Date           time.Time `json:"date"`
+	InvoiceID      uint      `gorm:"index" json:"invoiceId"`
+	InvoiceNumber  string    `json:"invoiceNumber"`
+	ClientName     string    `json:"clientName"`
+	ClientICE      string    `gorm:"size:15" json:"clientIce"`
+	Reason         string    `json:"reason"`
+	Restocked      bool      `json:"restocked"` // Credited quantities were returned to stock, see the lines
+
+	// Credited amounts, positive, in the invoice currency and net of its global discount
+	Currency    string       `gorm:"size:3" json:"currency"`
+	TotalHT     money.Amount `json:"totalHT"`
+	TotalTVA    money.Amount `json:"totalTVA"`
+	TotalTTC    money.Amount `json:"totalTTC"`
+	TotalHTMAD  money.Amount `gorm:"column:total_ht_mad" json:"totalHTMAD"`
+	TotalTVAMAD money.Amount `gorm:"column:total_tva_mad" json:"totalTVAMAD"`
+	TotalTTCMAD money.Amount `gorm:"column:total_ttc_mad" json:"totalTTCMAD"`
+
+	Items []CreditNoteItem `gorm:"foreignKey:CreditNoteID" json:"items"`
+}
+
+// CreditNoteItem is the credited quantity of an invoice line
+type CreditNoteItem struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	CreditNoteID  uint         `gorm:"index" json:"creditNoteId"`
+	InvoiceItemID uint         `gorm:"index" json:"invoiceItemId"`
+	ProductID     uint         `json:"productId"`
+	Description   string       `json:"description"`
+	Quantity      float64      `json:"quantity"`
+	TotalHT       money.Amount `json:"totalHT"`   // Before the invoice's global discount
+	TotalTTC      money.Amount `json:"totalTTC"`  // Before the invoice's global discount
+	Restocked     bool         `json:"restocked"` // The credited quantity was returned to stock
+}
+
+// CreditNoteRequest is the DTO for crediting an invoice
+type CreditNoteRequest struct {
+	Date    string                  `json:"date"` // DD-MM-YYYY, empty: today
+	Reason  string                  `json:"reason"`
+	Restock bool                    `json:"restock"` // Return the credited quantities to stock
+	Lines   []CreditNoteLineRequest `json:"lines"`   // Empty: everything not yet credited
+}
+
+// CreditNoteLineRequest is a quantity to credit on an invoice line
+type CreditNoteLineRequest struct {
+	InvoiceItemID uint    `json:"invoiceItemId"`
+	Quantity      float64 `json:"quantity"`
+}
+
+// Condition of returned goods
+const (
+	ConditionResalable = "RESALABLE" // Back in stock for sale
+	ConditionDamaged   = "DAMAGED"   // Kept apart as damaged stock
+)
+
+// Return records goods brought back by a customer against lines of an invoice. It is settled by a
+// credit note, and by a refund payment when the invoice had already been paid.
+type Return struct {
+	gorm.Model
+	FormattedID      string    `gorm:"uniqueIndex;size:20" json:"formattedId"` // Format: "RET 0001 - 2025"
+	SequenceNumber   int       `json:"sequenceNumber"`
+	Year             int       `json:"year"`
+	Date             time.Time `json:"date"`
+	InvoiceID        uint      `gorm:"index" json:"invoiceId"`
+	InvoiceNumber    string    `json:"invoiceNumber"`
+	ClientName       string    `json:"clientName"`
+	Reason           string    `json:"reason"`
+	CreditNoteID     uint      `gorm:"index" json:"creditNoteId"`
+	CreditNoteNumber string    `json:"creditNoteNumber"`
+
+	// Refund paid back to the customer, in the invoice currency (zero when the credit note is kept as a balance)
+	Currency        string       `gorm:"size:3" json:"currency"`
+	RefundAmount    money.Amount `json:"refundAmount"`
+	RefundMethod    string       `json:"refundMethod,omitempty"` // ESPECE, CHEQUE or VIREMENT
+	RefundReference string       `json:"refundReference,omitempty"`
+
+	Items []ReturnItem `gorm:"foreignKey:ReturnID" json:"items"`
+}
+
+// ReturnItem is a returned quantity of an invoice line and the condition it came back in
+type ReturnItem struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	ReturnID      uint    `gorm:"index" json:"returnId"`
+	InvoiceItemID uint    `gorm:"index" json:"invoiceItemId"`
+	ProductID     uint    `json:"productId"`
+	Description   string  `json:"description"`
+	Quantity      float64 `json:"quantity"`
+	Condition     string  `gorm:"size:10" json:"condition"` // RESALABLE or DAMAGED
+}
+
+// ReturnRequest is the DTO for recording a customer return
+type ReturnRequest struct {
+	Date   string              `json:"date"` // DD-MM-YYYY, empty: today
+	Reason string              `json:"reason"`
+	Lines  []ReturnLineRequest `json:"lines"`
+
+	// Pay the credited amount back; only for paid invoices. Otherwise the credit note stays as a balance.
+	Refund          bool   `json:"refund"`
+	RefundMethod    string `json:"refundMethod"`
+	RefundReference string `json:"refundReference"`
+}
+
+// ReturnLineRequest is a quantity returned on an invoice line
+type ReturnLineRequest struct {
+	InvoiceItemID uint    `json:"invoiceItemId"`
+	Quantity      float64 `json:"quantity"`
+	Condition     string  `json:"condition"` // RESALABLE or DAMAGED (empty: RESALABLE)
+}
+
+// Invoice list orderings
+const (
+	SortByDate   = "DATE"
+	SortByNumber = "NUMBER"
+	SortByClient = "CLIENT"
+	SortByAmount = "AMOUNT" // MAD equivalent of the total TTC
+)
+
+// InvoiceQuery filters, sorts and pages the invoice list; zero values mean "no filter"
+type InvoiceQuery struct {
+	From          string       `json:"from"` // DD-MM-YYYY, inclusive
+	To            string       `json:"to"`   // DD-MM-YYYY, inclusive
+	Year          int          `json:"year"`
+	Client        string       `json:"client"` // Part of the client name
+	ICE           string       `json:"ice"`
+	MinTTC        money.Amount `json:"minTTC"` // MAD equivalent of the total TTC
+	MaxTTC        money.Amount `json:"maxTTC"`
+	PaymentMethod string       `json:"paymentMethod"`
+	Status        string       `json:"status"`
+	ProductID     uint         `json:"productId"` // Invoices with a line of this product
+	Text          string       `json:"text"`      // Part of a line description
+
+	SortBy     string `json:"sortBy"` // DATE (default), NUMBER, CLIENT or AMOUNT
+	Descending bool   `json:"descending"`
+
+	// Offset pagination by page number, or keyset pagination from the cursor of the previous page
+	Page     int    `json:"page"`     // From 1
+	PageSize int    `json:"pageSize"` // 0: 50, at most 200
+	Cursor   string `json:"cursor"`
+}
+
+// InvoicePage is a page of the invoice list
+type InvoicePage struct {
+	Invoices   []InvoiceResponse `json:"invoices"`
+	Total      int64             `json:"total"` // Invoices matching the filters, over all pages
+	Page       int               `json:"page"`  // 0 when paging by cursor
+	PageSize   int               `json:"pageSize"`
+	NextCursor string            `json:"nextCursor,omitempty"` // Empty on the last page
 }
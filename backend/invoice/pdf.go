@@ -4,9 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"factureapp/backend/currency"
+	"factureapp/backend/layout"
+	"factureapp/backend/locale"
+	"factureapp/backend/money"
 
 	"github.com/johnfercher/maroto/v2"
+	"github.com/johnfercher/maroto/v2/pkg/components/code"
 	"github.com/johnfercher/maroto/v2/pkg/components/col"
+	"github.com/johnfercher/maroto/v2/pkg/components/image"
 	"github.com/johnfercher/maroto/v2/pkg/components/line"
 	"github.com/johnfercher/maroto/v2/pkg/components/text"
 	"github.com/johnfercher/maroto/v2/pkg/config"
@@ -17,192 +26,469 @@ import (
 )
 
 const (
-	// CompanyICE is the placeholder for company ICE number
-	CompanyICE = "001844544000022" // Replace with actual company ICE
-
-	// TopMarginMM is the blank margin at top for pre-printed stationery
-	TopMarginMM = 40.0
+	// CompanyICE is the company ICE number used until one is saved in the company settings
+	CompanyICE = "001844544000022"
 )
 
 // Color definitions
 var (
-	primaryColor  = &props.Color{Red: 0, Green: 0, Blue: 0}       // Black
-	headerBgColor = &props.Color{Red: 230, Green: 230, Blue: 230} // Light gray
-	lineColor     = &props.Color{Red: 128, Green: 128, Blue: 128} // Medium gray
-	totalBgColor  = &props.Color{Red: 200, Green: 200, Blue: 200} // Gray
-	darkGray      = &props.Color{Red: 80, Green: 80, Blue: 80}    // Dark gray
+	totalBgColor = &props.Color{Red: 200, Green: 200, Blue: 200} // Gray
+	darkGray     = &props.Color{Red: 80, Green: 80, Blue: 80}    // Dark gray
 )
 
-// GeneratePDF creates a PDF invoice and returns the file path
+// GeneratePDF creates a PDF invoice with the default template and returns the file path
 func (s *Service) GeneratePDF(invoiceID uint) (string, error) {
+	return s.GeneratePDFWithTemplate(invoiceID, 0)
+}
+
+// GeneratePDFWithTemplate creates a PDF invoice with the given template (0: default) and returns the file path
+func (s *Service) GeneratePDFWithTemplate(invoiceID uint, templateID uint) (string, error) {
 	// Get invoice data
 	invoice, err := s.GetInvoiceByID(invoiceID)
 	if err != nil {
 		return "", fmt.Errorf("impossible de récupérer la facture: %w", err)
 	}
 
+	tpl, err := s.layoutService.GetTemplate(templateID)
+	if err != nil {
+		return "", err
+	}
+
+	// Drafts are printed for review only: outside the archive, and never archived
+	if invoice.Status == StatusDraft {
+		pdfPath := filepath.Join(draftDir(), fmt.Sprintf("Brouillon_%d.pdf", invoice.ID))
+		if err := os.MkdirAll(filepath.Dir(pdfPath), 0755); err != nil {
+			return "", fmt.Errorf("impossible de créer le dossier PDF (%s): vérifiez les permissions ou l'espace disque", filepath.Dir(pdfPath))
+		}
+		if err := s.renderPDF(invoice, tpl, pdfPath); err != nil {
+			return "", err
+		}
+		return pdfPath, nil
+	}
+
+	// Named after the invoice number and client, in the year/month folder of the archive:
+	// regenerating replaces this working copy, the first one issued is also archived read-only
+	dir, err := s.monthDir(invoice)
+	if err != nil {
+		return "", err
+	}
+	pdfPath := filepath.Join(dir, PDFFileName(invoice))
+	if err := s.renderPDF(invoice, tpl, pdfPath); err != nil {
+		return "", err
+	}
+	if err := s.archiveOriginal(invoice, pdfPath); err != nil {
+		return "", err
+	}
+	return pdfPath, nil
+}
+
+// PreviewTemplate renders an invoice (0: a sample invoice) with an unsaved template, so that
+// layout changes can be checked before saving, and returns the file path
+func (s *Service) PreviewTemplate(invoiceID uint, tpl layout.Template) (string, error) {
+	if err := layout.Normalize(&tpl); err != nil {
+		return "", err
+	}
+	if tpl.ID != 0 && tpl.LogoPath == "" {
+		if saved, err := s.layoutService.GetTemplate(tpl.ID); err == nil {
+			tpl.LogoPath = saved.LogoPath
+		}
+	}
+
+	invoice := sampleInvoice()
+	if invoiceID != 0 {
+		var err error
+		invoice, err = s.GetInvoiceByID(invoiceID)
+		if err != nil {
+			return "", fmt.Errorf("impossible de récupérer la facture: %w", err)
+		}
+	}
+
+	// Previews are not archived
+	pdfDir := draftDir()
+	if err := os.MkdirAll(pdfDir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier PDF (%s): vérifiez les permissions ou l'espace disque", pdfDir)
+	}
+	pdfPath := filepath.Join(pdfDir, "Apercu_Modele.pdf")
+	if err := s.renderPDF(invoice, &tpl, pdfPath); err != nil {
+		return "", err
+	}
+	return pdfPath, nil
+}
+
+// draftDir is the folder of the PDFs that are not archived: template previews and drafts
+func draftDir() string {
+	outputDir, err := os.UserConfigDir()
+	if err != nil {
+		outputDir = "."
+	}
+	return filepath.Join(outputDir, "FactureApp", "pdfs")
+}
+
+// renderPDF lays out an invoice with a template and saves it to pdfPath
+func (s *Service) renderPDF(invoice *InvoiceResponse, tpl *layout.Template, pdfPath string) error {
+	p := pdfLayout{
+		lang:     invoice.Language,
+		tpl:      tpl,
+		primary:  hexColor(tpl.PrimaryColor),
+		headerBg: hexColor(tpl.HeaderBgColor),
+		line:     hexColor(tpl.LineColor),
+	}
+
 	// Configure Maroto
-	cfg := config.NewBuilder().
+	builder := config.NewBuilder().
 		WithPageNumber().
-		WithLeftMargin(15).
-		WithRightMargin(15).
-		WithTopMargin(TopMarginMM). // 40mm top margin for pre-printed stationery
-		Build()
+		WithLeftMargin(tpl.LeftMargin).
+		WithRightMargin(tpl.RightMargin).
+		WithTopMargin(tpl.TopMargin). // Pre-printed stationery keeps its letterhead area blank
+		WithBottomMargin(tpl.BottomMargin)
+
+	// Arabic text (labels, or a client name or description) needs the embedded Unicode font,
+	// the standard PDF fonts only cover Latin-1
+	if locale.HasArabic(p.lang) || invoiceHasArabic(invoice) {
+		fonts, err := locale.Fonts()
+		if err != nil {
+			return fmt.Errorf("échec du chargement de la police arabe: %w", err)
+		}
+		builder = builder.
+			WithCustomFonts(fonts).
+			WithDefaultFont(&props.Font{Family: locale.FontFamily, Size: 10, Color: p.primary})
+	} else {
+		builder = builder.WithDefaultFont(&props.Font{Family: tpl.FontFamily, Size: 10, Color: p.primary})
+	}
+
+	m := maroto.New(builder.Build())
 
-	m := maroto.New(cfg)
+	// Logo and company block on plain paper
+	s.addLetterhead(m, p)
 
 	// Header section
-	s.addHeader(m, invoice)
+	s.addHeader(m, p, invoice)
 
 	// Separator line
-	s.addSeparatorLine(m)
+	s.addSeparatorLine(m, p)
 
 	// Add spacing
 	m.AddRow(8)
 
 	// Items table with borders
-	s.addItemsTable(m, invoice)
+	s.addItemsTable(m, p, invoice)
 
 	// Add spacing
 	m.AddRow(10)
 
 	// Totals section
-	s.addTotals(m, invoice)
+	s.addTotals(m, p, invoice)
 
 	// Separator line
-	s.addSeparatorLine(m)
+	s.addSeparatorLine(m, p)
 
 	// Add spacing
 	m.AddRow(6)
 
 	// Legal text (Total in words)
-	s.addLegalText(m, invoice)
+	s.addLegalText(m, p, invoice)
 
 	// Add spacing
 	m.AddRow(6)
 
 	// Payment details
-	s.addPaymentDetails(m, invoice)
+	s.addPaymentDetails(m, p, invoice)
 
 	// Add spacing before footer
 	m.AddRow(15)
 
-	// Company ICE footer
-	s.addFooter(m)
+	// QR code to check the printed copy against our records; a draft has nothing to verify yet
+	if invoice.Status != StatusDraft {
+		s.addVerificationCode(m, p, invoice)
+	}
+
+	// Company ICE and legal mentions footer
+	s.addFooter(m, p)
 
 	// Generate PDF
 	doc, err := m.Generate()
 	if err != nil {
-		return "", fmt.Errorf("échec de la génération du PDF: %w", err)
+		return fmt.Errorf("échec de la génération du PDF: %w", err)
 	}
 
-	// Save to file
-	outputDir, err := os.UserConfigDir()
-	if err != nil {
-		outputDir = "."
-	}
-	pdfDir := filepath.Join(outputDir, "FactureApp", "pdfs")
-	if err := os.MkdirAll(pdfDir, 0755); err != nil {
-		return "", fmt.Errorf("impossible de créer le dossier PDF (%s): vérifiez les permissions ou l'espace disque", pdfDir)
+	if err := doc.Save(pdfPath); err != nil {
+		return fmt.Errorf("impossible de sauvegarder le PDF (%s): vérifiez les permissions et l'espace disque disponible", pdfPath)
 	}
+	return nil
+}
 
-	// Create safe filename
-	safeName := fmt.Sprintf("Facture_%04d_%d.pdf", invoice.ID, invoice.ID)
-	pdfPath := filepath.Join(pdfDir, safeName)
+// hexColor converts a validated #RRGGBB template colour
+func hexColor(value string) *props.Color {
+	var r, g, b int
+	fmt.Sscanf(value, "#%02x%02x%02x", &r, &g, &b)
+	return &props.Color{Red: r, Green: g, Blue: b}
+}
 
-	if err := doc.Save(pdfPath); err != nil {
-		return "", fmt.Errorf("impossible de sauvegarder le PDF (%s): vérifiez les permissions et l'espace disque disponible", pdfPath)
+// sampleInvoice is the invoice rendered by template previews when no invoice is chosen
+func sampleInvoice() *InvoiceResponse {
+	items := []InvoiceItem{
+		{Description: "Peinture acrylique blanche 20L", Quantity: 2, PrixUnitTTC: 45000, PrixUnitHT: 37500, GrossTTC: 90000, TotalHT: 75000, TotalTTC: 90000},
+		{Description: "Rouleau professionnel 230 mm", Quantity: 5, PrixUnitTTC: 6000, PrixUnitHT: 5000, GrossTTC: 30000,
+			DiscountType: DiscountPercent, DiscountValue: 10, DiscountAmount: 3000, TotalHT: 22500, TotalTTC: 27000},
+	}
+	return &InvoiceResponse{
+		FormattedID:   "0001 - 2026",
+		Date:          time.Now().Format("02-01-2006"),
+		ClientName:    "Client Exemple SARL",
+		ClientCity:    "Casablanca",
+		ClientICE:     "000000000000000",
+		PricingBasis:  PricingTTC,
+		Currency:      currency.MAD,
+		ExchangeRate:  1,
+		Language:      locale.French,
+		SubtotalHT:    97500,
+		SubtotalTTC:   117000,
+		TotalHT:       97500,
+		TotalTVA:      19500,
+		TotalTTC:      117000,
+		TotalInWords:  "Arrêté la présente facture à la somme de : Mille cent soixante-dix dirhams",
+		TotalTTCMAD:   117000,
+		PaymentMethod: "ESPECE",
+		Items:         items,
 	}
+}
 
-	return pdfPath, nil
+// invoiceHasArabic reports whether the invoice data contains Arabic text
+func invoiceHasArabic(invoice *InvoiceResponse) bool {
+	if locale.ContainsArabic(invoice.ClientName + invoice.ClientCity) {
+		return true
+	}
+	for _, item := range invoice.Items {
+		if locale.ContainsArabic(item.Description) {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *Service) addSeparatorLine(m core.Maroto) {
+func (s *Service) addSeparatorLine(m core.Maroto, p pdfLayout) {
 	m.AddRow(3,
 		col.New(12).Add(
 			line.New(props.Line{
-				Color:     lineColor,
+				Color:     p.line,
 				Thickness: 0.5,
 			}),
 		),
 	)
 }
 
-func (s *Service) addHeader(m core.Maroto, invoice *InvoiceResponse) {
+// pdfLayout renders an invoice with a template: labels and text in the invoice language
+// (mirroring the layout for Arabic), and the template's columns and colours
+type pdfLayout struct {
+	lang     string
+	tpl      *layout.Template
+	primary  *props.Color
+	headerBg *props.Color
+	line     *props.Color
+}
+
+// label translates a French label, e.g. "Date" or "Date / التاريخ" on bilingual invoices
+func (p pdfLayout) label(key string) string {
+	return locale.Label(p.lang, key)
+}
+
+// text creates a text component in visual order; right-to-left invoices swap left and right alignment
+func (p pdfLayout) text(value string, prop props.Text) core.Component {
+	if locale.IsRTL(p.lang) {
+		switch prop.Align {
+		case "", align.Left:
+			prop.Align = align.Right
+		case align.Right:
+			prop.Align = align.Left
+		}
+	}
+	return text.New(locale.Visual(value), prop)
+}
+
+// lotsLabel lists the lots delivered on a line, e.g. "Lot A12 (Exp. 05/2027) x 3, Lot B7 x 2";
+// the quantities are only printed when the lots do not cover the whole line alone
+func (p pdfLayout) lotsLabel(lots []InvoiceItemLot, quantity int) string {
+	withQuantities := len(lots) > 1 || lots[0].Quantity != quantity
+	parts := make([]string, len(lots))
+	for i, lot := range lots {
+		parts[i] = p.label("Lot") + " " + lot.LotNumber
+		if lot.ExpiryDate != nil {
+			parts[i] += fmt.Sprintf(" (%s %s)", p.label("Exp."), lot.ExpiryDate.Format("01/2006"))
+		}
+		if withQuantities {
+			parts[i] += fmt.Sprintf(" x %d", lot.Quantity)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// heading creates a table header cell; bilingual invoices stack the French and Arabic labels
+func (p pdfLayout) heading(key string, prop props.Text) []core.Component {
+	if p.lang != locale.Bilingual {
+		return []core.Component{p.text(p.label(key), prop)}
+	}
+	arabic := prop
+	arabic.Top = prop.Top + 5
+	return []core.Component{
+		p.text(key, prop),
+		p.text(locale.Translate(locale.Arabic, key), arabic),
+	}
+}
+
+// cols lays out a row from left to right, or from right to left on Arabic invoices
+func (p pdfLayout) cols(cols ...core.Col) []core.Col {
+	if !locale.IsRTL(p.lang) {
+		return cols
+	}
+	reversed := make([]core.Col, len(cols))
+	for i, c := range cols {
+		reversed[len(cols)-1-i] = c
+	}
+	return reversed
+}
+
+// columns returns the template's item table columns; without line discounts the discount
+// column is dropped and its width given to the description
+func (p pdfLayout) columns(hasLineDiscount bool) []layout.Column {
+	if hasLineDiscount {
+		return p.tpl.Columns
+	}
+	discountWidth := 0
+	for _, c := range p.tpl.Columns {
+		if c.Key == layout.ColumnDiscount {
+			discountWidth = c.Width
+		}
+	}
+	var columns []layout.Column
+	for _, c := range p.tpl.Columns {
+		switch c.Key {
+		case layout.ColumnDiscount:
+			continue
+		case layout.ColumnDescription:
+			c.Width += discountWidth
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// paragraph adds a full-width text; text containing Arabic is wrapped here, one row per line,
+// because it must be reordered after wrapping
+func (p pdfLayout) paragraph(m core.Maroto, value string, height float64, prop props.Text) {
+	if !locale.HasArabic(p.lang) {
+		m.AddRow(height, col.New(12).Add(p.text(value, prop)))
+		return
+	}
+	for _, line := range locale.Wrap(value, 100) {
+		m.AddRow(5, col.New(12).Add(p.text(line, prop)))
+	}
+}
+
+func (s *Service) addHeader(m core.Maroto, p pdfLayout, invoice *InvoiceResponse) {
 	// Determine which ID to show
-	displayID := invoice.FormattedID
-	if invoice.CustomFormattedID != "" {
-		displayID = invoice.CustomFormattedID
+	displayID := displayNumber(invoice)
+	if invoice.Status == StatusDraft {
+		displayID = p.label("BROUILLON")
 	}
 
 	// Invoice number with blue color
-	m.AddRow(10,
+	m.AddRow(10, p.cols(
 		col.New(6).Add(
-			text.New("FACTURE N°: "+displayID, props.Text{
+			p.text(p.label("FACTURE N°")+": "+displayID, props.Text{
 				Size:  14,
 				Style: fontstyle.Bold,
-				Color: primaryColor,
+				Color: p.primary,
 			}),
 		),
 		col.New(6).Add(
-			text.New("Client: "+invoice.ClientName, props.Text{
+			p.text(p.label("Client")+": "+invoice.ClientName, props.Text{
 				Size:  12,
 				Style: fontstyle.Bold,
 				Align: align.Right,
 			}),
 		),
-	)
+	)...)
 
-	m.AddRow(6,
+	m.AddRow(6, p.cols(
 		col.New(6).Add(
-			text.New("Date: "+invoice.Date, props.Text{
+			p.text(p.label("Date")+": "+invoice.Date, props.Text{
 				Size: 10,
 			}),
 		),
 		col.New(6).Add(
-			text.New("Ville: "+invoice.ClientCity, props.Text{
+			p.text(p.label("Ville")+": "+invoice.ClientCity, props.Text{
 				Size:  10,
 				Align: align.Right,
 			}),
 		),
-	)
+	)...)
 
-	m.AddRow(6,
+	m.AddRow(6, p.cols(
 		col.New(6),
 		col.New(6).Add(
-			text.New("ICE: "+invoice.ClientICE, props.Text{
+			p.text(p.label("ICE")+": "+invoice.ClientICE, props.Text{
 				Size:  10,
 				Align: align.Right,
 				Color: darkGray,
 			}),
 		),
-	)
+	)...)
 }
 
-func (s *Service) addItemsTable(m core.Maroto, invoice *InvoiceResponse) {
+func (s *Service) addItemsTable(m core.Maroto, p pdfLayout, invoice *InvoiceResponse) {
 	// Table header with background
 	headerProps := props.Text{
 		Size:  10,
 		Style: fontstyle.Bold,
 		Align: align.Center,
-		Color: primaryColor,
+		Color: p.primary,
+	}
+
+	// The discount column is only shown when at least one line carries a discount
+	hasLineDiscount := false
+	for _, item := range invoice.Items {
+		if item.DiscountAmount > 0 {
+			hasLineDiscount = true
+			break
+		}
+	}
+
+	// HT invoices show the entered HT prices and amounts
+	unitHeader, totalHeader := "PRIX UNIT. TTC", "TOTAL TTC"
+	if invoice.PricingBasis == PricingHT {
+		unitHeader, totalHeader = "PU HT", "MONTANT HT"
+	}
+
+	// Bilingual headers are printed on two lines
+	headerHeight := 9.0
+	if p.lang == locale.Bilingual {
+		headerHeight = 12
+		headerProps.Size = 8
+		headerProps.Top = 1
 	}
 
-	// Header row with background color
-	m.AddRow(9,
-		col.New(5).Add(text.New("DESCRIPTION", headerProps)),
-		col.New(2).Add(text.New("QTÉ", headerProps)),
-		col.New(3).Add(text.New("PRIX UNIT. TTC", headerProps)),
-		col.New(2).Add(text.New("TOTAL TTC", headerProps)),
-	).WithStyle(&props.Cell{
-		BackgroundColor: headerBgColor,
+	// Header row with background color, columns as defined by the template
+	columns := p.columns(hasLineDiscount)
+	headings := map[string]string{
+		layout.ColumnDescription: "DESCRIPTION",
+		layout.ColumnQuantity:    "QTÉ",
+		layout.ColumnUnitPrice:   unitHeader,
+		layout.ColumnDiscount:    "REMISE",
+		layout.ColumnTotal:       totalHeader,
+	}
+	var headerCols []core.Col
+	for _, c := range columns {
+		headerCols = append(headerCols, col.New(c.Width).Add(p.heading(headings[c.Key], headerProps)...))
+	}
+	m.AddRow(headerHeight, p.cols(headerCols...)...).WithStyle(&props.Cell{
+		BackgroundColor: p.headerBg,
 	})
 
 	// Header bottom line
 	m.AddRow(1,
 		col.New(12).Add(
 			line.New(props.Line{
-				Color:     primaryColor,
+				Color:     p.primary,
 				Thickness: 1.0,
 			}),
 		),
@@ -223,18 +509,40 @@ func (s *Service) addItemsTable(m core.Maroto, invoice *InvoiceResponse) {
 			}
 		}
 
-		m.AddRow(8,
-			col.New(5).Add(text.New(item.Description, props.Text{
-				Size: 9,
-			})),
-			col.New(2).Add(text.New(fmt.Sprintf("%.0f", item.Quantity), cellProps)),
-			col.New(3).Add(text.New(fmt.Sprintf("%.2f DH", item.PrixUnitTTC), cellProps)),
-			col.New(2).Add(text.New(fmt.Sprintf("%.2f DH", item.TotalTTC), props.Text{
-				Size:  9,
-				Align: align.Right,
-				Style: fontstyle.Bold,
-			})),
-		).WithStyle(rowStyle)
+		totalProps := props.Text{
+			Size:  9,
+			Align: align.Right,
+			Style: fontstyle.Bold,
+		}
+
+		unitPrice, lineTotal := item.PrixUnitTTC, item.TotalTTC
+		if invoice.PricingBasis == PricingHT {
+			unitPrice, lineTotal = item.PrixUnitHT, item.TotalHT
+		}
+
+		var rowCols []core.Col
+		for _, c := range columns {
+			var cell core.Component
+			switch c.Key {
+			case layout.ColumnDescription:
+				cell = p.text(item.Description, props.Text{
+					Size: 9,
+				})
+			case layout.ColumnQuantity:
+				cell = p.text(fmt.Sprintf("%.0f", item.Quantity), cellProps)
+			case layout.ColumnUnitPrice:
+				cell = p.text(amountLabel(unitPrice, invoice.Currency), cellProps)
+			case layout.ColumnDiscount:
+				cell = p.text(discountLabel(item.DiscountType, item.DiscountValue, item.DiscountAmount, invoice.Currency), cellProps)
+			case layout.ColumnTotal:
+				cell = p.text(amountLabel(lineTotal, invoice.Currency), totalProps)
+			}
+			rowCols = append(rowCols, col.New(c.Width).Add(cell))
+		}
+		m.AddRow(8, p.cols(rowCols...)...).WithStyle(rowStyle)
+		if len(item.Lots) > 0 {
+			m.AddRow(5, col.New(12).Add(p.text(p.lotsLabel(item.Lots, int(item.Quantity)), props.Text{Size: 7, Style: fontstyle.Italic}))).WithStyle(rowStyle)
+		}
 
 		// Row separator line
 		m.AddRow(1,
@@ -251,14 +559,14 @@ func (s *Service) addItemsTable(m core.Maroto, invoice *InvoiceResponse) {
 	m.AddRow(1,
 		col.New(12).Add(
 			line.New(props.Line{
-				Color:     lineColor,
+				Color:     p.line,
 				Thickness: 0.8,
 			}),
 		),
 	)
 }
 
-func (s *Service) addTotals(m core.Maroto, invoice *InvoiceResponse) {
+func (s *Service) addTotals(m core.Maroto, p pdfLayout, invoice *InvoiceResponse) {
 	labelProps := props.Text{
 		Size:  10,
 		Align: align.Right,
@@ -270,66 +578,147 @@ func (s *Service) addTotals(m core.Maroto, invoice *InvoiceResponse) {
 		Style: fontstyle.Bold,
 	}
 
-	// Total HT
-	m.AddRow(7,
-		col.New(8),
-		col.New(2).Add(text.New("Total HT:", labelProps)),
-		col.New(2).Add(text.New(fmt.Sprintf("%.2f DH", invoice.TotalHT), valueProps)),
-	)
+	// Global discount: show the subtotal and the discount before the TVA split
+	if invoice.DiscountAmount > 0 {
+		subtotalLabel, subtotal := p.label("Sous-total TTC"), invoice.SubtotalTTC
+		if invoice.PricingBasis == PricingHT {
+			subtotalLabel, subtotal = p.label("Sous-total HT"), invoice.SubtotalHT
+		}
+		m.AddRow(7, p.cols(
+			col.New(6),
+			col.New(4).Add(p.text(subtotalLabel+":", labelProps)),
+			col.New(2).Add(p.text(amountLabel(subtotal, invoice.Currency), valueProps)),
+		)...)
+		m.AddRow(7, p.cols(
+			col.New(6),
+			col.New(4).Add(p.text(p.label("Remise")+" "+discountLabel(invoice.DiscountType, invoice.DiscountValue, invoice.DiscountAmount, invoice.Currency)+":", labelProps)),
+			col.New(2).Add(p.text("-"+amountLabel(invoice.DiscountAmount, invoice.Currency), props.Text{
+				Size:  10,
+				Align: align.Right,
+				Color: darkGray,
+			})),
+		)...)
+	}
 
-	// TVA
-	m.AddRow(7,
-		col.New(8),
-		col.New(2).Add(text.New("TVA 20%:", labelProps)),
-		col.New(2).Add(text.New(fmt.Sprintf("%.2f DH", invoice.TotalTVA), props.Text{
+	// Total HT
+	m.AddRow(7, p.cols(
+		col.New(6),
+		col.New(4).Add(p.text(p.label("Total HT")+":", labelProps)),
+		col.New(2).Add(p.text(amountLabel(invoice.TotalHT, invoice.Currency), valueProps)),
+	)...)
+
+	// TVA (exempt invoices print the exemption instead of an amount)
+	tvaLabel, tvaValue := fmt.Sprintf("%s %g%%:", p.label("TVA"), TVARate), amountLabel(invoice.TotalTVA, invoice.Currency)
+	if invoice.TVAExempt {
+		tvaLabel, tvaValue = p.label("TVA")+":", p.label("Exonérée")
+	}
+	m.AddRow(7, p.cols(
+		col.New(6),
+		col.New(4).Add(p.text(tvaLabel, labelProps)),
+		col.New(2).Add(p.text(tvaValue, props.Text{
 			Size:  10,
 			Align: align.Right,
 			Color: darkGray,
 		})),
-	)
+	)...)
 
 	// Line before total
-	m.AddRow(2,
+	m.AddRow(2, p.cols(
 		col.New(8),
 		col.New(4).Add(
 			line.New(props.Line{
-				Color:     primaryColor,
+				Color:     p.primary,
 				Thickness: 1.0,
 			}),
 		),
-	)
+	)...)
 
 	// Total TTC with highlight
-	m.AddRow(10,
-		col.New(8),
-		col.New(2).Add(text.New("TOTAL TTC:", props.Text{
+	m.AddRow(10, p.cols(
+		col.New(6),
+		col.New(4).Add(p.text(p.label("TOTAL TTC")+":", props.Text{
 			Size:  12,
 			Style: fontstyle.Bold,
 			Align: align.Right,
-			Color: primaryColor,
+			Color: p.primary,
 		})),
-		col.New(2).Add(text.New(fmt.Sprintf("%.2f DH", invoice.TotalTTC), props.Text{
+		col.New(2).Add(p.text(amountLabel(invoice.TotalTTC, invoice.Currency), props.Text{
 			Size:  12,
 			Style: fontstyle.Bold,
 			Align: align.Right,
-			Color: primaryColor,
+			Color: p.primary,
 		})),
-	)
+	)...)
+
+	// Foreign-currency invoices: exchange rate and MAD equivalent
+	if invoice.Currency != "" && invoice.Currency != currency.MAD {
+		m.AddRow(6, p.cols(
+			col.New(4),
+			col.New(6).Add(p.text(fmt.Sprintf("%s: 1 %s = %.4f MAD", p.label("Taux de change"), invoice.Currency, invoice.ExchangeRate), labelProps)),
+			col.New(2),
+		)...)
+		m.AddRow(6, p.cols(
+			col.New(6),
+			col.New(4).Add(p.text(p.label("Contre-valeur TTC")+":", labelProps)),
+			col.New(2).Add(p.text(amountLabel(invoice.TotalTTCMAD, currency.MAD), props.Text{
+				Size:  10,
+				Align: align.Right,
+				Color: darkGray,
+			})),
+		)...)
+	}
 }
 
-func (s *Service) addLegalText(m core.Maroto, invoice *InvoiceResponse) {
-	m.AddRow(12,
-		col.New(12).Add(
-			text.New(invoice.TotalInWords, props.Text{
-				Size:  9,
-				Style: fontstyle.BoldItalic,
-				Color: darkGray,
-			}),
-		),
-	)
+// amountLabel formats an amount with its currency symbol, e.g. "12.50 DH" or "12.50 EUR"
+func amountLabel(amount money.Amount, currencyCode string) string {
+	return fmt.Sprintf("%s %s", amount, currency.Symbol(currencyCode))
+}
+
+// discountLabel formats a discount for display, e.g. "10% (-12.50 DH)" or "-12.50 DH"
+func discountLabel(discountType string, value float64, amount money.Amount, currencyCode string) string {
+	switch {
+	case amount <= 0:
+		return "-"
+	case discountType == DiscountPercent:
+		return fmt.Sprintf("%g%% (-%s)", value, amountLabel(amount, currencyCode))
+	default:
+		return "-" + amountLabel(amount, currencyCode)
+	}
+}
+
+func (s *Service) addLegalText(m core.Maroto, p pdfLayout, invoice *InvoiceResponse) {
+	wordsProps := props.Text{
+		Size:  9,
+		Style: fontstyle.BoldItalic,
+		Color: darkGray,
+	}
+	p.paragraph(m, invoice.TotalInWords, 12, wordsProps)
+
+	// Bilingual invoices store the French words and print the Arabic ones below
+	if p.lang == locale.Bilingual {
+		arabic := p
+		arabic.lang = locale.Arabic
+		arabic.paragraph(m, s.ConvertToWords(invoice.TotalTTC, invoice.Currency, locale.Arabic), 6, wordsProps)
+	}
+
+	if invoice.TVAExempt {
+		reasonProps := props.Text{
+			Size:  9,
+			Style: fontstyle.Bold,
+			Color: darkGray,
+		}
+		if p.lang == locale.Bilingual {
+			p.paragraph(m, invoice.ExemptionReason, 6, reasonProps)
+			arabic := p
+			arabic.lang = locale.Arabic
+			arabic.paragraph(m, locale.Translate(locale.Arabic, invoice.ExemptionReason), 6, reasonProps)
+		} else {
+			p.paragraph(m, locale.Translate(p.lang, invoice.ExemptionReason), 6, reasonProps)
+		}
+	}
 }
 
-func (s *Service) addPaymentDetails(m core.Maroto, invoice *InvoiceResponse) {
+func (s *Service) addPaymentDetails(m core.Maroto, p pdfLayout, invoice *InvoiceResponse) {
 	rowStyle := &props.Cell{
 		BackgroundColor: &props.Color{Red: 245, Green: 245, Blue: 245},
 	}
@@ -340,88 +729,177 @@ func (s *Service) addPaymentDetails(m core.Maroto, invoice *InvoiceResponse) {
 	}
 	valueProps := props.Text{
 		Size:  9,
-		Color: primaryColor,
+		Color: p.primary,
 	}
 
 	switch invoice.PaymentMethod {
 	case "ESPECE":
-		m.AddRow(8,
-			col.New(3).Add(text.New("Mode de paiement:", labelProps)),
-			col.New(9).Add(text.New("Espèce", valueProps)),
-		).WithStyle(rowStyle)
+		m.AddRow(8, p.cols(
+			col.New(4).Add(p.text(p.label("Mode de paiement")+":", labelProps)),
+			col.New(8).Add(p.text(p.label("Espèce"), valueProps)),
+		)...).WithStyle(rowStyle)
 
 	case "CHEQUE":
 		if invoice.ChequeInfo != nil {
 			// Row 1: Payment type
-			m.AddRow(7,
-				col.New(3).Add(text.New("Mode de paiement:", labelProps)),
-				col.New(9).Add(text.New("Chèque", valueProps)),
-			).WithStyle(rowStyle)
+			m.AddRow(7, p.cols(
+				col.New(4).Add(p.text(p.label("Mode de paiement")+":", labelProps)),
+				col.New(8).Add(p.text(p.label("Chèque"), valueProps)),
+			)...).WithStyle(rowStyle)
 
 			// Row 2: Cheque number
-			m.AddRow(7,
-				col.New(3).Add(text.New("N° Chèque:", labelProps)),
-				col.New(9).Add(text.New(invoice.ChequeInfo.Number, valueProps)),
-			).WithStyle(rowStyle)
+			m.AddRow(7, p.cols(
+				col.New(4).Add(p.text(p.label("N° Chèque")+":", labelProps)),
+				col.New(8).Add(p.text(invoice.ChequeInfo.Number, valueProps)),
+			)...).WithStyle(rowStyle)
 
 			// Row 3: Bank
-			m.AddRow(7,
-				col.New(3).Add(text.New("Banque:", labelProps)),
-				col.New(9).Add(text.New(invoice.ChequeInfo.Bank, valueProps)),
-			).WithStyle(rowStyle)
+			m.AddRow(7, p.cols(
+				col.New(4).Add(p.text(p.label("Banque")+":", labelProps)),
+				col.New(8).Add(p.text(invoice.ChequeInfo.Bank, valueProps)),
+			)...).WithStyle(rowStyle)
 
 			// Row 4: City
 			if invoice.ChequeInfo.City != "" {
-				m.AddRow(7,
-					col.New(3).Add(text.New("Ville:", labelProps)),
-					col.New(9).Add(text.New(invoice.ChequeInfo.City, valueProps)),
-				).WithStyle(rowStyle)
+				m.AddRow(7, p.cols(
+					col.New(4).Add(p.text(p.label("Ville")+":", labelProps)),
+					col.New(8).Add(p.text(invoice.ChequeInfo.City, valueProps)),
+				)...).WithStyle(rowStyle)
 			}
 
 			// Row 5: Reference
 			if invoice.ChequeInfo.Reference != "" {
-				m.AddRow(7,
-					col.New(3).Add(text.New("Référence:", labelProps)),
-					col.New(9).Add(text.New(invoice.ChequeInfo.Reference, valueProps)),
-				).WithStyle(rowStyle)
+				m.AddRow(7, p.cols(
+					col.New(4).Add(p.text(p.label("Référence")+":", labelProps)),
+					col.New(8).Add(p.text(invoice.ChequeInfo.Reference, valueProps)),
+				)...).WithStyle(rowStyle)
 			}
 		}
 
 	case "EFFET":
 		if invoice.EffetInfo != nil {
 			// Row 1: Payment type
-			m.AddRow(7,
-				col.New(3).Add(text.New("Mode de paiement:", labelProps)),
-				col.New(9).Add(text.New("Effet", valueProps)),
-			).WithStyle(rowStyle)
+			m.AddRow(7, p.cols(
+				col.New(4).Add(p.text(p.label("Mode de paiement")+":", labelProps)),
+				col.New(8).Add(p.text(p.label("Effet"), valueProps)),
+			)...).WithStyle(rowStyle)
 
 			// Row 2: City
-			m.AddRow(7,
-				col.New(3).Add(text.New("Ville:", labelProps)),
-				col.New(9).Add(text.New(invoice.EffetInfo.City, valueProps)),
-			).WithStyle(rowStyle)
+			m.AddRow(7, p.cols(
+				col.New(4).Add(p.text(p.label("Ville")+":", labelProps)),
+				col.New(8).Add(p.text(invoice.EffetInfo.City, valueProps)),
+			)...).WithStyle(rowStyle)
 
 			// Row 3: Due date
-			m.AddRow(7,
-				col.New(3).Add(text.New("Échéance:", labelProps)),
-				col.New(9).Add(text.New(invoice.EffetInfo.DateEcheance, valueProps)),
-			).WithStyle(rowStyle)
+			m.AddRow(7, p.cols(
+				col.New(4).Add(p.text(p.label("Échéance")+":", labelProps)),
+				col.New(8).Add(p.text(invoice.EffetInfo.DateEcheance, valueProps)),
+			)...).WithStyle(rowStyle)
 		}
 	}
 }
 
-func (s *Service) addFooter(m core.Maroto) {
-	// Separator line
-	s.addSeparatorLine(m)
-
-	m.AddRow(10,
-		col.New(12).Add(
-			text.New("ICE Société: "+CompanyICE, props.Text{
-				Size:  11,
-				Style: fontstyle.Bold,
-				Align: align.Center,
-				Color: primaryColor,
+// addVerificationCode prints the QR code holding the seller ICE, number, date, total and content hash,
+// checked by VerifyQRPayload when a copy is scanned
+func (s *Service) addVerificationCode(m core.Maroto, p pdfLayout, invoice *InvoiceResponse) {
+	hash := ContentHash(invoice)
+	m.AddRow(28, p.cols(
+		code.NewQrCol(2, s.QRPayload(invoice), props.Rect{Percent: 100, Center: true}),
+		col.New(10).Add(
+			p.text(p.label("Scannez ce code pour vérifier l'authenticité de la facture"), props.Text{
+				Size:  8,
+				Top:   9,
+				Color: darkGray,
+			}),
+			p.text(p.label("Empreinte")+": "+hash[:16], props.Text{
+				Size:  7,
+				Top:   14,
+				Color: darkGray,
 			}),
 		),
-	)
+	)...)
+}
+
+func (s *Service) addFooter(m core.Maroto, p pdfLayout) {
+	// Separator line
+	s.addSeparatorLine(m, p)
+
+	if p.tpl.ShowCompany {
+		m.AddRow(10,
+			col.New(12).Add(
+				p.text(p.label("ICE Société")+": "+s.settingsService.GetCompany(CompanyICE).ICE, props.Text{
+					Size:  11,
+					Style: fontstyle.Bold,
+					Align: align.Center,
+					Color: p.primary,
+				}),
+			),
+		)
+	}
+
+	// Legal mentions, on plain paper only
+	if p.tpl.PrePrinted {
+		return
+	}
+	for _, footerLine := range templateLines(p.tpl.FooterText) {
+		m.AddRow(4,
+			col.New(12).Add(
+				p.text(footerLine, props.Text{
+					Size:  8,
+					Align: align.Center,
+					Color: darkGray,
+				}),
+			),
+		)
+	}
+}
+
+// addLetterhead prints the template's logo and company block; pre-printed paper already carries them
+func (s *Service) addLetterhead(m core.Maroto, p pdfLayout) {
+	headerLines := templateLines(p.tpl.HeaderText)
+	if p.tpl.PrePrinted || (p.tpl.LogoPath == "" && len(headerLines) == 0) {
+		return
+	}
+
+	height := 25.0
+	if lines := float64(len(headerLines))*5 + 2; lines > height {
+		height = lines
+	}
+
+	logo := col.New(4)
+	if p.tpl.LogoPath != "" {
+		if _, err := os.Stat(p.tpl.LogoPath); err == nil {
+			logo.Add(image.NewFromFile(p.tpl.LogoPath, props.Rect{Percent: 100}))
+		}
+	}
+
+	company := col.New(8)
+	for i, headerLine := range headerLines {
+		lineProps := props.Text{
+			Size:  9,
+			Top:   float64(i) * 5,
+			Align: align.Right,
+			Color: p.primary,
+		}
+		if i == 0 {
+			lineProps.Size = 12
+			lineProps.Style = fontstyle.Bold
+		}
+		company.Add(p.text(headerLine, lineProps))
+	}
+
+	m.AddRow(height, p.cols(logo, company)...)
+	s.addSeparatorLine(m, p)
+	m.AddRow(4)
+}
+
+// templateLines splits a template text block into its non-empty lines
+func templateLines(block string) []string {
+	var lines []string
+	for _, l := range strings.Split(block, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
 }
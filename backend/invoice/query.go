@@ -0,0 +1,215 @@
+package invoice
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// Page sizes of the invoice list
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// sortColumns are the columns of each ordering; NUMBER sorts by year, then sequence number, so that
+// idx_invoices_year_sequence serves it
+var sortColumns = map[string][]string{
+	SortByDate:   {"date"},
+	SortByNumber: {"year", "sequence_number"},
+	SortByClient: {"client_name"},
+	SortByAmount: {"total_ttc_mad"},
+}
+
+// invoiceCursor is the position after the last invoice of a page: its sort values and ID
+type invoiceCursor struct {
+	SortBy string        `json:"s"`
+	Values []interface{} `json:"v"`
+	ID     uint          `json:"id"`
+}
+
+// QueryInvoices returns one page of the invoices matching the filters, with the total count.
+// Only the invoices of the page are loaded with their lines.
+func (s *Service) QueryInvoices(q InvoiceQuery) (*InvoicePage, error) {
+	sortBy := strings.ToUpper(strings.TrimSpace(q.SortBy))
+	if sortBy == "" {
+		sortBy = SortByDate
+	}
+	columns, ok := sortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("tri inconnu: %s (DATE, NUMBER, CLIENT ou AMOUNT attendu)", q.SortBy)
+	}
+
+	pageSize := q.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	db := database.GetDB()
+	query, err := filterInvoices(db.Model(&Invoice{}), q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &InvoicePage{PageSize: pageSize}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, fmt.Errorf("échec du comptage des factures: %w", err)
+	}
+
+	direction, compare := "ASC", ">"
+	if q.Descending {
+		direction, compare = "DESC", "<"
+	}
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor, sortBy)
+		if err != nil {
+			return nil, err
+		}
+		// Row value comparison: the rows after (sort values..., id) in the ordering
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)+1), ", ")
+		query = query.Where(fmt.Sprintf("(%s, id) %s (%s)", strings.Join(columns, ", "), compare, placeholders),
+			append(cursor.Values, cursor.ID)...)
+	} else {
+		page.Page = q.Page
+		if page.Page < 1 {
+			page.Page = 1
+		}
+		query = query.Offset((page.Page - 1) * pageSize)
+	}
+
+	// One more row than the page tells whether there is a next page
+	for _, column := range columns {
+		query = query.Order(column + " " + direction)
+	}
+	var invoices []Invoice
+	if err := query.Preload("Items.Lots").Order("id " + direction).
+		Limit(pageSize + 1).Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("échec de la recherche des factures: %w", err)
+	}
+	if len(invoices) > pageSize {
+		invoices = invoices[:pageSize]
+		page.NextCursor = encodeCursor(sortBy, &invoices[pageSize-1])
+	}
+
+	page.Invoices = make([]InvoiceResponse, len(invoices))
+	for i := range invoices {
+		page.Invoices[i] = *s.toResponse(&invoices[i])
+	}
+	return page, nil
+}
+
+// filterInvoices applies the filters of a query
+func filterInvoices(query *gorm.DB, q InvoiceQuery) (*gorm.DB, error) {
+	if from := strings.TrimSpace(q.From); from != "" {
+		date, err := time.Parse("02-01-2006", from)
+		if err != nil {
+			return nil, fmt.Errorf("date de début invalide: %s (format JJ-MM-AAAA attendu)", q.From)
+		}
+		query = query.Where("date >= ?", date)
+	}
+	if to := strings.TrimSpace(q.To); to != "" {
+		date, err := time.Parse("02-01-2006", to)
+		if err != nil {
+			return nil, fmt.Errorf("date de fin invalide: %s (format JJ-MM-AAAA attendu)", q.To)
+		}
+		query = query.Where("date < ?", date.AddDate(0, 0, 1))
+	}
+	if q.Year != 0 {
+		query = query.Where("year = ?", q.Year)
+	}
+	if client := strings.TrimSpace(q.Client); client != "" {
+		query = query.Where("client_name LIKE ?", "%"+client+"%")
+	}
+	if ice := strings.TrimSpace(q.ICE); ice != "" {
+		query = query.Where("client_ice = ?", ice)
+	}
+	if q.MinTTC != 0 {
+		query = query.Where("total_ttc_mad >= ?", q.MinTTC)
+	}
+	if q.MaxTTC != 0 {
+		if q.MaxTTC < q.MinTTC {
+			return nil, fmt.Errorf("le montant maximum doit être supérieur au montant minimum")
+		}
+		query = query.Where("total_ttc_mad <= ?", q.MaxTTC)
+	}
+	if method := strings.ToUpper(strings.TrimSpace(q.PaymentMethod)); method != "" {
+		switch method {
+		case "CHEQUE", "EFFET", "ESPECE":
+			query = query.Where("payment_method = ?", method)
+		default:
+			return nil, fmt.Errorf("mode de paiement inconnu: %s (CHEQUE, EFFET ou ESPECE attendu)", q.PaymentMethod)
+		}
+	}
+	if status := strings.ToUpper(strings.TrimSpace(q.Status)); status != "" {
+		switch status {
+		case StatusDraft, StatusIssued, StatusPaid, StatusCancelled:
+			query = query.Where("status = ?", status)
+		default:
+			return nil, fmt.Errorf("statut inconnu: %s (DRAFT, ISSUED, PAID ou CANCELLED attendu)", q.Status)
+		}
+	}
+	if q.ProductID != 0 {
+		query = query.Where("id IN (SELECT invoice_id FROM invoice_items WHERE product_id = ?)", q.ProductID)
+	}
+	if text := strings.TrimSpace(q.Text); text != "" {
+		query = query.Where("id IN (SELECT invoice_id FROM invoice_items WHERE description LIKE ?)", "%"+text+"%")
+	}
+	return query, nil
+}
+
+// encodeCursor returns the opaque cursor following an invoice in the given ordering
+func encodeCursor(sortBy string, inv *Invoice) string {
+	cursor := invoiceCursor{SortBy: sortBy, ID: inv.ID}
+	switch sortBy {
+	case SortByDate:
+		cursor.Values = []interface{}{inv.Date.Format(time.RFC3339Nano)}
+	case SortByNumber:
+		cursor.Values = []interface{}{inv.Year, inv.SequenceNumber}
+	case SortByClient:
+		cursor.Values = []interface{}{inv.ClientName}
+	case SortByAmount:
+		cursor.Values = []interface{}{inv.TotalTTCMAD.Centimes()}
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor, which must come from a page in the same ordering
+func decodeCursor(value, sortBy string) (*invoiceCursor, error) {
+	invalid := fmt.Errorf("curseur de pagination invalide: relancez la recherche depuis la première page")
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalid
+	}
+	var cursor invoiceCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.SortBy != sortBy || len(cursor.Values) != len(sortColumns[sortBy]) {
+		return nil, invalid
+	}
+
+	for i, value := range cursor.Values {
+		switch v := value.(type) {
+		case string:
+			if sortBy == SortByDate {
+				date, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return nil, invalid
+				}
+				cursor.Values[i] = date
+			}
+		case float64:
+			cursor.Values[i] = int64(v)
+		default:
+			return nil, invalid
+		}
+	}
+	return &cursor, nil
+}
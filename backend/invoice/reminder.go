@@ -0,0 +1,410 @@
+package invoice
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+	"factureapp/backend/locale"
+	"factureapp/backend/money"
+
+	"github.com/johnfercher/maroto/v2"
+	"github.com/johnfercher/maroto/v2/pkg/components/col"
+	"github.com/johnfercher/maroto/v2/pkg/config"
+	"github.com/johnfercher/maroto/v2/pkg/consts/align"
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/core"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// Overdue list orderings
+const (
+	OverdueByAmount = "AMOUNT" // Largest amounts first
+	OverdueByAge    = "AGE"    // Oldest due dates first
+)
+
+// reminderTitles are the letter titles of each reminder level
+var reminderTitles = map[int]string{
+	ReminderFirst:        "1ère relance",
+	ReminderSecond:       "2ème relance",
+	ReminderFormalNotice: "Mise en demeure",
+}
+
+// reminderFilePrefixes start the PDF file name of each reminder level
+var reminderFilePrefixes = map[int]string{
+	ReminderFirst:        "Relance1",
+	ReminderSecond:       "Relance2",
+	ReminderFormalNotice: "MiseEnDemeure",
+}
+
+// MarkInvoicePaid records the payment date (DD-MM-YYYY, empty: today) of an invoice
+func (s *Service) MarkInvoicePaid(id uint, paidOn string) error {
+	paidAt := today()
+	if strings.TrimSpace(paidOn) != "" {
+		var err error
+		paidAt, err = time.Parse("02-01-2006", strings.TrimSpace(paidOn))
+		if err != nil {
+			return fmt.Errorf("date de paiement invalide: %s (format JJ-MM-AAAA attendu)", paidOn)
+		}
+	}
+
+	db := database.GetDB()
+	var inv Invoice
+	if err := db.First(&inv, id).Error; err != nil {
+		return fmt.Errorf("facture introuvable: %w", err)
+	}
+	if inv.Status != StatusIssued && inv.Status != StatusPaid {
+		return fmt.Errorf("seule une facture émise peut être payée (statut: %s)", inv.Status)
+	}
+	if paidAt.Before(inv.Date) {
+		return fmt.Errorf("la date de paiement ne peut pas précéder la date de la facture")
+	}
+	if err := db.Model(&inv).Updates(map[string]interface{}{"paid_at": paidAt, "status": StatusPaid}).Error; err != nil {
+		return fmt.Errorf("échec de l'enregistrement du paiement: %w", err)
+	}
+	return nil
+}
+
+// MarkInvoiceUnpaid cancels a recorded payment, e.g. a bounced cheque
+func (s *Service) MarkInvoiceUnpaid(id uint) error {
+	db := database.GetDB()
+	var inv Invoice
+	if err := db.First(&inv, id).Error; err != nil {
+		return fmt.Errorf("facture introuvable: %w", err)
+	}
+	if inv.Status != StatusPaid {
+		return fmt.Errorf("la facture %s n'est pas marquée payée", inv.FormattedID)
+	}
+	if err := db.Model(&inv).Updates(map[string]interface{}{"paid_at": nil, "status": StatusIssued}).Error; err != nil {
+		return fmt.Errorf("échec de l'annulation du paiement: %w", err)
+	}
+	return nil
+}
+
+// GetOverdueInvoices lists the unpaid invoices past their due date, sorted by amount (MAD equivalent) or by age
+func (s *Service) GetOverdueInvoices(sortBy string) ([]OverdueInvoice, error) {
+	sortBy = strings.ToUpper(strings.TrimSpace(sortBy))
+	if sortBy == "" {
+		sortBy = OverdueByAmount
+	}
+	if sortBy != OverdueByAmount && sortBy != OverdueByAge {
+		return nil, fmt.Errorf("tri inconnu: %s (AMOUNT ou AGE attendu)", sortBy)
+	}
+
+	db := database.GetDB()
+	var invoices []Invoice
+	if err := db.Where("status = ? AND due_date < ?", StatusIssued, today()).Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des factures impayées: %w", err)
+	}
+
+	ids := make([]uint, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.ID
+	}
+	credited, err := creditedAmounts(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// The amount due is net of the partial credit notes
+	overdue := make([]OverdueInvoice, 0, len(invoices))
+	for _, inv := range invoices {
+		amountDue := inv.TotalTTC - credited[inv.ID].TotalTTC
+		if amountDue <= 0 {
+			continue
+		}
+		formattedID := inv.FormattedID
+		if inv.CustomFormattedID != "" {
+			formattedID = inv.CustomFormattedID
+		}
+		item := OverdueInvoice{
+			InvoiceID:    inv.ID,
+			FormattedID:  formattedID,
+			ClientName:   inv.ClientName,
+			ClientICE:    inv.ClientICE,
+			Date:         inv.Date.Format("02-01-2006"),
+			DueDate:      inv.DueDate.Format("02-01-2006"),
+			DaysOverdue:  daysOverdue(*inv.DueDate),
+			AmountDue:    amountDue,
+			Currency:     inv.Currency,
+			AmountDueMAD: inv.TotalTTCMAD - credited[inv.ID].TotalTTCMAD,
+		}
+
+		var last []Reminder
+		if err := db.Where("invoice_id = ?", inv.ID).Order("created_at DESC").Limit(1).Find(&last).Error; err != nil {
+			return nil, fmt.Errorf("échec de la lecture des relances: %w", err)
+		}
+		if len(last) > 0 {
+			item.LastReminderLevel = last[0].Level
+			item.LastReminderAt = &last[0].CreatedAt
+		}
+		item.NextReminderLevel = nextReminderLevel(item.LastReminderLevel)
+		overdue = append(overdue, item)
+	}
+
+	sort.SliceStable(overdue, func(i, j int) bool {
+		a, b := overdue[i], overdue[j]
+		if sortBy == OverdueByAge && a.DaysOverdue != b.DaysOverdue {
+			return a.DaysOverdue > b.DaysOverdue
+		}
+		if a.AmountDueMAD != b.AmountDueMAD {
+			return a.AmountDueMAD > b.AmountDueMAD
+		}
+		return a.DaysOverdue > b.DaysOverdue
+	})
+	return overdue, nil
+}
+
+// GetReminders returns the reminder history of an invoice, oldest first
+func (s *Service) GetReminders(invoiceID uint) ([]Reminder, error) {
+	db := database.GetDB()
+	var reminders []Reminder
+	if err := db.Where("invoice_id = ?", invoiceID).Order("created_at ASC").Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des relances: %w", err)
+	}
+	return reminders, nil
+}
+
+// GenerateReminder writes the reminder letter of an overdue invoice as a PDF and records it in the history.
+// Level 0 takes the level following the last reminder.
+func (s *Service) GenerateReminder(invoiceID uint, level int) (*Reminder, error) {
+	reminder, err := s.PrepareReminder(invoiceID, level)
+	if err != nil {
+		return nil, err
+	}
+	reminder.Channel = ReminderPDF
+	if err := s.RecordReminder(reminder); err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// PrepareReminder writes the reminder letter of an overdue invoice as a PDF without recording it,
+// so that the caller can first send it. Level 0 takes the level following the last reminder.
+func (s *Service) PrepareReminder(invoiceID uint, level int) (*Reminder, error) {
+	inv, err := s.GetInvoiceByID(invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de récupérer la facture: %w", err)
+	}
+	switch inv.Status {
+	case StatusPaid:
+		return nil, fmt.Errorf("la facture %s est déjà payée", inv.FormattedID)
+	case StatusDraft, StatusCancelled:
+		return nil, fmt.Errorf("seule une facture émise impayée peut être relancée")
+	}
+	due, err := time.Parse("02-01-2006", inv.DueDate)
+	if err != nil {
+		return nil, fmt.Errorf("la facture %s n'a pas de date d'échéance", inv.FormattedID)
+	}
+	days := daysOverdue(due)
+	if days <= 0 {
+		return nil, fmt.Errorf("la facture %s n'est pas encore échue (échéance le %s)", inv.FormattedID, inv.DueDate)
+	}
+
+	credited, err := creditedAmounts(database.GetDB(), []uint{invoiceID})
+	if err != nil {
+		return nil, err
+	}
+	amountDue := inv.TotalTTC - credited[invoiceID].TotalTTC
+	if amountDue <= 0 {
+		return nil, fmt.Errorf("la facture %s est entièrement créditée: il n'y a rien à relancer", inv.FormattedID)
+	}
+
+	if level == 0 {
+		history, err := s.GetReminders(invoiceID)
+		if err != nil {
+			return nil, err
+		}
+		last := 0
+		if len(history) > 0 {
+			last = history[len(history)-1].Level
+		}
+		level = nextReminderLevel(last)
+	}
+	title, ok := reminderTitles[level]
+	if !ok {
+		return nil, fmt.Errorf("niveau de relance invalide: %d (1, 2 ou 3 attendu)", level)
+	}
+
+	number := inv.FormattedID
+	if inv.CustomFormattedID != "" {
+		number = inv.CustomFormattedID
+	}
+	reminder := &Reminder{
+		InvoiceID:   invoiceID,
+		Level:       level,
+		Title:       title,
+		Subject:     fmt.Sprintf("%s - Facture n° %s du %s", title, number, inv.Date),
+		Body:        reminderBody(level, number, inv, days, amountDue),
+		AmountDue:   amountDue,
+		Currency:    inv.Currency,
+		DaysOverdue: days,
+	}
+
+	dir := filepath.Join(s.ArchiveRoot(), "relances", time.Now().Format("2006"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier des relances (%s): vérifiez les permissions ou l'espace disque", dir)
+	}
+	reminder.PDFPath = filepath.Join(dir, fmt.Sprintf("%s_%s_%s.pdf",
+		reminderFilePrefixes[level], strings.TrimSuffix(PDFFileName(inv), ".pdf"), time.Now().Format("20060102")))
+
+	if err := s.renderReminder(inv, number, reminder); err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// RecordReminder adds a prepared reminder to the invoice's history
+func (s *Service) RecordReminder(reminder *Reminder) error {
+	db := database.GetDB()
+	if err := db.Create(reminder).Error; err != nil {
+		return fmt.Errorf("échec de l'enregistrement de la relance: %w", err)
+	}
+	return nil
+}
+
+// nextReminderLevel follows the last level sent, staying at the formal notice once reached
+func nextReminderLevel(last int) int {
+	if last >= ReminderFormalNotice {
+		return ReminderFormalNotice
+	}
+	return last + 1
+}
+
+// reminderBody is the letter text of each level, from a courteous reminder to a formal notice.
+// The amount claimed is what remains due once the credit notes are deducted.
+func reminderBody(level int, number string, inv *InvoiceResponse, days int, amountDue money.Amount) string {
+	amount := amountLabel(amountDue, inv.Currency)
+	if amountDue != inv.TotalTTC {
+		amount += " (avoirs déduits)"
+	}
+	switch level {
+	case ReminderFirst:
+		return fmt.Sprintf("Madame, Monsieur,\n\n"+
+			"Sauf erreur de notre part, la facture n° %s du %s, d'un montant de %s, arrivée à échéance le %s, "+
+			"reste impayée à ce jour.\n\n"+
+			"Nous vous remercions de bien vouloir procéder à son règlement dans les meilleurs délais. "+
+			"Si votre paiement a été effectué entre-temps, nous vous prions de ne pas tenir compte de ce courrier.",
+			number, inv.Date, amount, inv.DueDate)
+	case ReminderSecond:
+		return fmt.Sprintf("Madame, Monsieur,\n\n"+
+			"Malgré notre précédente relance, la facture n° %s du %s, d'un montant de %s, échue le %s, "+
+			"reste impayée à ce jour, soit un retard de %d jours.\n\n"+
+			"Nous vous prions de procéder à son règlement sous huitaine. À défaut, nous serons contraints "+
+			"d'engager les démarches nécessaires au recouvrement de cette créance.",
+			number, inv.Date, amount, inv.DueDate, days)
+	default:
+		return fmt.Sprintf("Madame, Monsieur,\n\n"+
+			"Malgré nos relances restées sans effet, la facture n° %s du %s, échue le %s, "+
+			"demeure impayée, soit un retard de %d jours.\n\n"+
+			"Par la présente, nous vous mettons en demeure de nous régler la somme de %s dans un délai de "+
+			"quinze jours à compter de la réception de ce courrier. À défaut de règlement dans ce délai, "+
+			"nous engagerons sans autre avis une procédure de recouvrement judiciaire, dont les frais resteront à votre charge.",
+			number, inv.Date, inv.DueDate, days, amount)
+	}
+}
+
+// renderReminder lays out a reminder letter with the default template and saves it to the reminder's PDF path
+func (s *Service) renderReminder(inv *InvoiceResponse, number string, reminder *Reminder) error {
+	tpl, err := s.layoutService.GetTemplate(0)
+	if err != nil {
+		return err
+	}
+	p := pdfLayout{
+		lang:     locale.French,
+		tpl:      tpl,
+		primary:  hexColor(tpl.PrimaryColor),
+		headerBg: hexColor(tpl.HeaderBgColor),
+		line:     hexColor(tpl.LineColor),
+	}
+
+	builder := config.NewBuilder().
+		WithLeftMargin(tpl.LeftMargin).
+		WithRightMargin(tpl.RightMargin).
+		WithTopMargin(tpl.TopMargin).
+		WithBottomMargin(tpl.BottomMargin)
+	if invoiceHasArabic(inv) {
+		fonts, err := locale.Fonts()
+		if err != nil {
+			return fmt.Errorf("échec du chargement de la police arabe: %w", err)
+		}
+		builder = builder.
+			WithCustomFonts(fonts).
+			WithDefaultFont(&props.Font{Family: locale.FontFamily, Size: 10, Color: p.primary})
+	} else {
+		builder = builder.WithDefaultFont(&props.Font{Family: tpl.FontFamily, Size: 10, Color: p.primary})
+	}
+	m := maroto.New(builder.Build())
+
+	s.addLetterhead(m, p)
+
+	// Date and addressee
+	m.AddRow(8, col.New(12).Add(p.text("Le "+time.Now().Format("02-01-2006"), props.Text{Size: 10, Align: align.Right})))
+	for i, addressLine := range []string{inv.ClientName, inv.ClientCity, "ICE : " + inv.ClientICE} {
+		lineProps := props.Text{Size: 10, Align: align.Right}
+		if i == 0 {
+			lineProps.Style = fontstyle.Bold
+		}
+		m.AddRow(5, col.New(6), col.New(6).Add(p.text(addressLine, lineProps)))
+	}
+	m.AddRow(10)
+
+	m.AddRow(8, col.New(12).Add(p.text("Objet : "+reminder.Subject, props.Text{Size: 11, Style: fontstyle.Bold})))
+	if reminder.Level == ReminderFormalNotice {
+		m.AddRow(6, col.New(12).Add(p.text("Lettre recommandée avec accusé de réception", props.Text{Size: 9, Style: fontstyle.Italic, Color: darkGray})))
+	}
+	m.AddRow(4)
+
+	for _, paragraph := range strings.Split(reminder.Body, "\n\n") {
+		height := 6 + float64(len(paragraph)/95)*5
+		m.AddRow(height, col.New(12).Add(p.text(paragraph, props.Text{Size: 10, Align: align.Justify})))
+		m.AddRow(3)
+	}
+
+	// Invoice summary
+	headers := []struct {
+		label string
+		width int
+	}{{"Facture", 3}, {"Date", 2}, {"Échéance", 2}, {"Retard", 2}, {"Montant dû", 3}}
+	values := []string{number, inv.Date, inv.DueDate, fmt.Sprintf("%d jours", reminder.DaysOverdue), amountLabel(reminder.AmountDue, inv.Currency)}
+	var headerCols, valueCols []core.Col
+	for i, h := range headers {
+		headerCols = append(headerCols, col.New(h.width).Add(p.text(h.label, props.Text{Size: 9, Style: fontstyle.Bold, Align: align.Center, Top: 1.5})))
+		valueCols = append(valueCols, col.New(h.width).Add(p.text(values[i], props.Text{Size: 9, Align: align.Center, Top: 1.5})))
+	}
+	m.AddRow(7, headerCols...).WithStyle(&props.Cell{BackgroundColor: p.headerBg})
+	m.AddRow(7, valueCols...)
+	s.addSeparatorLine(m, p)
+	m.AddRow(10)
+
+	m.AddRow(8, col.New(12).Add(p.text("Veuillez agréer, Madame, Monsieur, l'expression de nos salutations distinguées.", props.Text{Size: 10})))
+	m.AddRow(15)
+	m.AddRow(6, col.New(6), col.New(6).Add(p.text("La Direction", props.Text{Size: 10, Style: fontstyle.Bold, Align: align.Center})))
+	m.AddRow(20)
+
+	s.addFooter(m, p)
+
+	doc, err := m.Generate()
+	if err != nil {
+		return fmt.Errorf("échec de la génération du PDF: %w", err)
+	}
+	if err := doc.Save(reminder.PDFPath); err != nil {
+		return fmt.Errorf("impossible de sauvegarder le PDF (%s): vérifiez les permissions et l'espace disque disponible", reminder.PDFPath)
+	}
+	return nil
+}
+
+// today returns the current date at midnight UTC, like the stored invoice dates
+func today() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysOverdue counts the days elapsed since a due date (negative before it)
+func daysOverdue(due time.Time) int {
+	return int(today().Sub(due.UTC().Truncate(24*time.Hour)).Hours() / 24)
+}
@@ -0,0 +1,141 @@
+package invoice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+
+	"gorm.io/gorm"
+)
+
+// CreateReturn records goods brought back against lines of an issued invoice. Quantities are checked
+// against what was sold and not yet credited; resalable goods go back to stock and damaged ones to
+// damaged stock. A credit note is issued for the returned lines and, when asked on a paid invoice,
+// its amount is recorded as refunded.
+func (s *Service) CreateReturn(invoiceID uint, req ReturnRequest) (*Return, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("le motif du retour est obligatoire")
+	}
+	if len(req.Lines) == 0 {
+		return nil, fmt.Errorf("indiquez au moins un article retourné")
+	}
+	date := today()
+	if strings.TrimSpace(req.Date) != "" {
+		var err error
+		date, err = time.Parse("02-01-2006", strings.TrimSpace(req.Date))
+		if err != nil {
+			return nil, fmt.Errorf("date de retour invalide: %s (format JJ-MM-AAAA attendu)", req.Date)
+		}
+	}
+
+	refundMethod := strings.ToUpper(strings.TrimSpace(req.RefundMethod))
+	if req.Refund {
+		switch refundMethod {
+		case "ESPECE", "CHEQUE", "VIREMENT":
+		default:
+			return nil, fmt.Errorf("mode de remboursement inconnu: %s (ESPECE, CHEQUE ou VIREMENT attendu)", req.RefundMethod)
+		}
+	}
+
+	// Each line goes where its condition says: resalable goods back to stock, damaged ones to damaged stock
+	conditions := make([]string, len(req.Lines))
+	lines := make([]CreditNoteLineRequest, len(req.Lines))
+	for i, line := range req.Lines {
+		condition := strings.ToUpper(strings.TrimSpace(line.Condition))
+		switch condition {
+		case "":
+			condition = ConditionResalable
+		case ConditionResalable, ConditionDamaged:
+		default:
+			return nil, fmt.Errorf("ligne %d: état inconnu: %s (RESALABLE ou DAMAGED attendu)", i+1, line.Condition)
+		}
+		conditions[i] = condition
+		lines[i] = CreditNoteLineRequest{InvoiceItemID: line.InvoiceItemID, Quantity: line.Quantity}
+	}
+
+	var ret *Return
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var inv Invoice
+		if err := tx.Preload("Items.Lots").First(&inv, invoiceID).Error; err != nil {
+			return fmt.Errorf("facture introuvable: %w", err)
+		}
+
+		note, err := s.creditInvoice(tx, &inv, date, "Retour de marchandise: "+reason, lines, false)
+		if err != nil {
+			return err
+		}
+		if req.Refund && inv.Status != StatusPaid {
+			return fmt.Errorf("la facture %s n'est pas réglée: l'avoir vient en déduction du montant dû, sans remboursement", note.InvoiceNumber)
+		}
+
+		ret = &Return{
+			Date:             date,
+			Year:             date.Year(),
+			InvoiceID:        inv.ID,
+			InvoiceNumber:    note.InvoiceNumber,
+			ClientName:       inv.ClientName,
+			Reason:           reason,
+			CreditNoteID:     note.ID,
+			CreditNoteNumber: note.FormattedID,
+			Currency:         inv.Currency,
+		}
+		if req.Refund {
+			ret.RefundAmount = note.TotalTTC
+			ret.RefundMethod = refundMethod
+			ret.RefundReference = strings.TrimSpace(req.RefundReference)
+		}
+		for i, item := range note.Items {
+			ret.Items = append(ret.Items, ReturnItem{
+				InvoiceItemID: item.InvoiceItemID,
+				ProductID:     item.ProductID,
+				Description:   item.Description,
+				Quantity:      item.Quantity,
+				Condition:     conditions[i],
+			})
+			if conditions[i] == ConditionDamaged {
+				err = s.inventoryService.AddDamagedStock(tx, item.ProductID, int(item.Quantity))
+			} else {
+				err = s.restockLine(tx, &inv, item)
+				if err == nil {
+					note.Restocked = true
+					err = tx.Model(&note.Items[i]).Update("restocked", true).Error
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("échec de la mise à jour du stock de l'article %s: %w", item.Description, err)
+			}
+		}
+		if note.Restocked {
+			if err := tx.Model(note).Update("restocked", true).Error; err != nil {
+				return fmt.Errorf("échec de la mise à jour de l'avoir: %w", err)
+			}
+		}
+
+		var last Return
+		tx.Where("year = ?", ret.Year).Order("sequence_number DESC").First(&last)
+		ret.SequenceNumber = last.SequenceNumber + 1
+		ret.FormattedID = fmt.Sprintf("RET %04d - %d", ret.SequenceNumber, ret.Year)
+
+		if err := tx.Create(ret).Error; err != nil {
+			return fmt.Errorf("échec de l'enregistrement du retour: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetReturns returns the customer returns recorded against an invoice, oldest first
+func (s *Service) GetReturns(invoiceID uint) ([]Return, error) {
+	var returns []Return
+	if err := database.GetDB().Preload("Items").Where("invoice_id = ?", invoiceID).
+		Order("date, sequence_number").Find(&returns).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des retours: %w", err)
+	}
+	return returns, nil
+}
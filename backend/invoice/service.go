@@ -2,174 +2,186 @@ package invoice
 
 import (
 	"fmt"
-	"math"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"factureapp/backend/client"
+	"factureapp/backend/currency"
 	"factureapp/backend/database"
 	"factureapp/backend/inventory"
+	"factureapp/backend/layout"
+	"factureapp/backend/locale"
+	"factureapp/backend/money"
+	"factureapp/backend/pricing"
+	"factureapp/backend/settings"
+
+	"gorm.io/gorm"
 )
 
 // Service handles invoice business logic
 type Service struct {
 	inventoryService *inventory.Service
+	pricingService   *pricing.Service
+	currencyService  *currency.Service
+	layoutService    *layout.Service
+	settingsService  *settings.Service
 }
 
 // NewService creates a new invoice service
-func NewService(inventoryService *inventory.Service) *Service {
+func NewService(inventoryService *inventory.Service, pricingService *pricing.Service, currencyService *currency.Service, layoutService *layout.Service, settingsService *settings.Service) *Service {
 	return &Service{
 		inventoryService: inventoryService,
+		pricingService:   pricingService,
+		currencyService:  currencyService,
+		layoutService:    layoutService,
+		settingsService:  settingsService,
 	}
 }
 
 // Migrate runs database migrations for invoice models
 func (s *Service) Migrate() error {
 	db := database.GetDB()
-	return db.AutoMigrate(&Invoice{}, &InvoiceItem{})
-}
-
-// CreateInvoice creates a new invoice with auto-numbering and calculations
-func (s *Service) CreateInvoice(req InvoiceCreateRequest) (*InvoiceResponse, error) {
-	db := database.GetDB()
+	if err := db.AutoMigrate(&Invoice{}, &InvoiceItem{}, &InvoiceItemLot{}); err != nil {
+		return err
+	}
 
-	// Start transaction
-	tx := db.Begin()
-	if tx.Error != nil {
-		return nil, tx.Error
+	// Amounts were stored as REAL dirhams before they became integer centimes
+	if err := database.RunOnce("invoice_amounts_to_centimes", func(tx *gorm.DB) error {
+		if err := database.ConvertToCentimes(tx, "invoices", "subtotal_ttc", "discount_amount", "total_ht", "total_tva", "total_ttc"); err != nil {
+			return err
+		}
+		return database.ConvertToCentimes(tx, "invoice_items", "buying_price", "prix_unit_ttc", "gross_ttc", "discount_amount", "total_ttc")
+	}); err != nil {
+		return err
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+
+	// Invoices created before discounts existed: gross equals net
+	if err := database.RunOnce("invoice_gross_amounts", func(tx *gorm.DB) error {
+		if err := tx.Model(&InvoiceItem{}).Where("gross_ttc = 0").Update("gross_ttc", gorm.Expr("total_ttc")).Error; err != nil {
+			return err
 		}
-	}()
+		return tx.Model(&Invoice{}).Where("subtotal_ttc = 0").Update("subtotal_ttc", gorm.Expr("total_ttc")).Error
+	}); err != nil {
+		return err
+	}
 
-	// Parse date
-	date, err := time.Parse("02-01-2006", req.Date)
-	if err != nil {
-		return nil, fmt.Errorf("format de date invalide, JJ-MM-AAAA attendu: %w", err)
+	// Invoices created before HT pricing existed were all TTC: derive the line HT amounts
+	if err := database.RunOnce("invoice_ht_amounts", func(tx *gorm.DB) error {
+		if err := tx.Model(&InvoiceItem{}).Where("total_ht = 0 AND total_ttc <> 0").Updates(map[string]interface{}{
+			"prix_unit_ht": gorm.Expr("CAST(ROUND(prix_unit_ttc / 1.2) AS INTEGER)"),
+			"total_ht":     gorm.Expr("CAST(ROUND(total_ttc / 1.2) AS INTEGER)"),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Invoice{}).Where("subtotal_ht = 0 AND subtotal_ttc <> 0").
+			Update("subtotal_ht", gorm.Expr("CAST(ROUND(subtotal_ttc / 1.2) AS INTEGER)")).Error
+	}); err != nil {
+		return err
 	}
 
-	// Validate ICE (15 characters)
-	if len(req.ClientICE) != 15 {
-		return nil, fmt.Errorf("l'ICE doit contenir exactement 15 chiffres, vous avez fourni %d", len(req.ClientICE))
+	// Invoices created before multi-currency support were all in MAD
+	if err := database.RunOnce("invoice_mad_amounts", func(tx *gorm.DB) error {
+		return tx.Model(&Invoice{}).Where("currency = ? AND total_ttc_mad = 0 AND total_ttc <> 0", currency.MAD).Updates(map[string]interface{}{
+			"total_ht_mad":  gorm.Expr("total_ht"),
+			"total_tva_mad": gorm.Expr("total_tva"),
+			"total_ttc_mad": gorm.Expr("total_ttc"),
+		}).Error
+	}); err != nil {
+		return err
 	}
 
-	// Validate items
-	if len(req.Items) == 0 {
-		return nil, fmt.Errorf("la facture doit contenir au moins un article")
+	if err := db.AutoMigrate(&Reminder{}); err != nil {
+		return err
 	}
 
-	for i, item := range req.Items {
-		if item.ProductID == 0 {
-			return nil, fmt.Errorf("article %d: aucun produit sélectionné", i+1)
-		}
-		if item.Quantity <= 0 {
-			return nil, fmt.Errorf("article %d: la quantité doit être supérieure à 0", i+1)
-		}
-		if item.Quantity > 100000 {
-			return nil, fmt.Errorf("article %d: quantité excessive (%.0f). Maximum: 100,000", i+1, item.Quantity)
-		}
-		if item.PrixUnitTTC <= 0 {
-			return nil, fmt.Errorf("article %d: le prix unitaire doit être supérieur à 0", i+1)
-		}
-		if len(strings.TrimSpace(item.Description)) == 0 {
-			return nil, fmt.Errorf("article %d: la description est obligatoire", i+1)
+	// Invoices created before payment follow-up get the default terms; cash invoices were paid on the spot
+	if err := database.RunOnce("invoice_due_dates", func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE invoices SET due_date = strftime('%Y-%m-%d %H:%M:%S+00:00', date, ?) WHERE due_date IS NULL",
+			fmt.Sprintf("+%d days", DefaultPaymentTermsDays)).Error; err != nil {
+			return err
 		}
+		return tx.Exec("UPDATE invoices SET paid_at = date WHERE paid_at IS NULL AND payment_method = 'ESPECE'").Error
+	}); err != nil {
+		return err
 	}
 
-	// Get year from the invoice date (not system date)
-	invoiceYear := date.Year()
+	// Invoices issued before sealing existed are sealed as they stand
+	if err := database.RunOnce("invoice_hash_chain", s.sealExisting); err != nil {
+		return err
+	}
 
-	// Validate year range
-	if invoiceYear < 1900 || invoiceYear > 2100 {
-		return nil, fmt.Errorf("année invalide: %d (doit être entre 1900 et 2100)", invoiceYear)
+	if err := db.AutoMigrate(&CreditNote{}, &CreditNoteItem{}, &Return{}, &ReturnItem{}); err != nil {
+		return err
 	}
 
-	// Calculate TTC from items
-	var totalTTC float64
-	items := make([]InvoiceItem, len(req.Items))
-	for i, item := range req.Items {
-		itemTotal := item.Quantity * item.PrixUnitTTC
-		// Fetch product for details
-		var product inventory.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("produit introuvable (ID: %d): %w", item.ProductID, err)
+	// Restocking was recorded per credit note before it was recorded per line: a return mixing
+	// resalable and damaged goods restocked its resalable lines under a credit note marked not restocked
+	if err := database.RunOnce("credit_note_items_restocked", func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE credit_note_items SET restocked = true
+			WHERE credit_note_id IN (SELECT id FROM credit_notes WHERE restocked)
+			OR EXISTS (SELECT 1 FROM returns JOIN return_items ON return_items.return_id = returns.id
+				WHERE returns.credit_note_id = credit_note_items.credit_note_id
+				AND return_items.invoice_item_id = credit_note_items.invoice_item_id
+				AND return_items.condition = ?)`, ConditionResalable).Error; err != nil {
+			return err
 		}
+		return tx.Exec("UPDATE credit_notes SET restocked = true WHERE id IN (SELECT credit_note_id FROM credit_note_items WHERE restocked)").Error
+	}); err != nil {
+		return err
+	}
 
-		items[i] = InvoiceItem{
-			ProductID:   item.ProductID,
-			Product:     product,
-			Description: item.Description,
-			Quantity:    item.Quantity,
-			BuyingPrice: product.BuyingPrice, // Snapshot buying price
-			PrixUnitTTC: item.PrixUnitTTC,
-			TotalTTC:    itemTotal,
+	// Invoices created before drafts existed were all issued; the number index now leaves drafts out
+	return database.RunOnce("invoice_statuses", func(tx *gorm.DB) error {
+		if err := tx.Exec("UPDATE invoices SET status = ? WHERE paid_at IS NOT NULL", StatusPaid).Error; err != nil {
+			return err
 		}
-		totalTTC += itemTotal
+		return tx.Exec("DROP INDEX IF EXISTS idx_invoices_formatted_id").Error
+	})
+}
 
-		// Decrement stock
-		if err := s.inventoryService.DecreaseStock(tx, item.ProductID, int(item.Quantity)); err != nil {
-			tx.Rollback()
-			return nil, err // Error already in French from inventory service
-		}
+// CreateInvoice creates and issues an invoice: it is numbered, sealed and its stock is decremented
+func (s *Service) CreateInvoice(req InvoiceCreateRequest) (*InvoiceResponse, error) {
+	return s.createInvoice(req, true)
+}
 
-	}
+// CreateDraft saves an invoice as a draft: it has no number and reserves no stock until it is issued
+func (s *Service) CreateDraft(req InvoiceCreateRequest) (*InvoiceResponse, error) {
+	return s.createInvoice(req, false)
+}
 
-	// Reverse tax calculation: HT = TTC / 1.20
-	totalHT := totalTTC / 1.20
-	totalTVA := totalTTC - totalHT
+// createInvoice builds an invoice from a request and saves it, issued or as a draft
+func (s *Service) createInvoice(req InvoiceCreateRequest, issue bool) (*InvoiceResponse, error) {
+	db := database.GetDB()
 
-	// Round to 2 decimal places
-	totalHT = math.Round(totalHT*100) / 100
-	totalTVA = math.Round(totalTVA*100) / 100
-	totalTTC = math.Round(totalTTC*100) / 100
+	// Start transaction
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
 
-	// Convert total to words (French)
-	totalInWords := s.ConvertToWords(totalTTC)
+	date, err := validateRequest(req)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-	// Auto-numbering: get last sequence number for the invoice's year
-	var lastInvoice Invoice
-	tx.Where("year = ?", invoiceYear).Order("sequence_number DESC").First(&lastInvoice)
-
-	nextSequence := 1
-	if lastInvoice.ID != 0 {
-		nextSequence = lastInvoice.SequenceNumber + 1
-	}
-
-	// Format: "0001 - 2025"
-	formattedID := fmt.Sprintf("%04d - %d", nextSequence, invoiceYear)
-
-	// Create invoice
-	invoice := Invoice{
-		FormattedID:       formattedID,
-		CustomFormattedID: req.CustomFormattedID,
-		SequenceNumber:    nextSequence,
-		Year:              invoiceYear,
-		Date:              date,
-		ClientName:        req.ClientName,
-		ClientCity:        req.ClientCity,
-		ClientICE:         req.ClientICE,
-		TotalHT:           totalHT,
-		TotalTVA:          totalTVA,
-		TotalTTC:          totalTTC,
-		TotalInWords:      totalInWords,
-		PaymentMethod:     req.PaymentMethod,
-		Items:             items,
-	}
-
-	// Set	// Payment Info
-	invoice.PaymentMethod = req.PaymentMethod
-	if req.PaymentMethod == "CHEQUE" && req.ChequeInfo != nil {
-		invoice.ChequeNumber = req.ChequeInfo.Number
-		invoice.ChequeBank = req.ChequeInfo.Bank
-		invoice.ChequeCity = req.ChequeInfo.City
-		// Reference removed for Cheque
-	} else if req.PaymentMethod == "EFFET" && req.EffetInfo != nil {
-		invoice.EffetCity = req.EffetInfo.City
-		invoice.EffetDateEcheance = req.EffetInfo.DateEcheance
-		invoice.EffetBank = req.EffetInfo.Bank
-		invoice.EffetReference = req.EffetInfo.Reference
+	invoice := Invoice{Status: StatusDraft}
+	if err := s.fillInvoice(tx, &invoice, req, date); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if issue {
+		if err := s.issue(tx, &invoice); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
 	// Save to database
@@ -183,14 +195,14 @@ func (s *Service) CreateInvoice(req InvoiceCreateRequest) (*InvoiceResponse, err
 		return nil, fmt.Errorf("échec de la validation de la transaction: %w", err)
 	}
 
-	return s.toResponse(&invoice), nil
+	resp := s.toResponse(&invoice)
+	resp.Warnings = s.priceWarnings(req.ClientICE, &invoice)
+	return resp, nil
 }
 
-// UpdateInvoice updates an existing invoice and handles stock adjustments
-func (s *Service) UpdateInvoice(id uint, req InvoiceCreateRequest) (*InvoiceResponse, error) {
+// IssueInvoice issues a draft: it gets the next number of its year, its stock is decremented and it is sealed
+func (s *Service) IssueInvoice(id uint) (*InvoiceResponse, error) {
 	db := database.GetDB()
-
-	// Start transaction
 	tx := db.Begin()
 	if tx.Error != nil {
 		return nil, tx.Error
@@ -201,36 +213,199 @@ func (s *Service) UpdateInvoice(id uint, req InvoiceCreateRequest) (*InvoiceResp
 		}
 	}()
 
-	// 1. Get existing invoice with items
 	var invoice Invoice
 	if err := tx.Preload("Items").First(&invoice, id).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("facture introuvable: %w", err)
 	}
-
-	// 2. Revert stock for OLD items
+	if invoice.Status != StatusDraft {
+		tx.Rollback()
+		return nil, fmt.Errorf("la facture %s est déjà émise", invoice.FormattedID)
+	}
+	if err := s.issue(tx, &invoice); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Omit("Items").Save(&invoice).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("échec de l'émission de la facture: %w", err)
+	}
+	// The lines of a draft are already saved: only their lots are new
 	for _, item := range invoice.Items {
-		if err := s.inventoryService.IncreaseStock(tx, item.ProductID, int(item.Quantity)); err != nil {
+		if len(item.Lots) == 0 {
+			continue
+		}
+		if err := tx.Create(&item.Lots).Error; err != nil {
 			tx.Rollback()
-			return nil, fmt.Errorf("échec de la restauration du stock pour l'article %s: %w", item.Description, err)
+			return nil, fmt.Errorf("échec de l'enregistrement des lots de la facture: %w", err)
 		}
 	}
 
-	// 3. Delete OLD items
-	if err := tx.Where("invoice_id = ?", id).Delete(&InvoiceItem{}).Error; err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("failed to delete old items: %w", err)
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("échec de la validation de la transaction: %w", err)
+	}
+	return s.toResponse(&invoice), nil
+}
+
+// issue numbers an invoice in its year, decrements the stock of its lines, recording the lots taken, and seals it
+func (s *Service) issue(tx *gorm.DB, invoice *Invoice) error {
+	for i, item := range invoice.Items {
+		allocations, err := s.inventoryService.ConsumeStock(tx, item.ProductID, invoice.LocationID, int(item.Quantity))
+		if err != nil {
+			return err // Error already in French from inventory service
+		}
+		invoice.Items[i].Lots = nil
+		for _, allocation := range allocations {
+			invoice.Items[i].Lots = append(invoice.Items[i].Lots, InvoiceItemLot{
+				InvoiceItemID: item.ID,
+				LotID:         allocation.LotID,
+				LotNumber:     allocation.LotNumber,
+				ExpiryDate:    allocation.ExpiryDate,
+				Quantity:      allocation.Quantity,
+			})
+		}
+	}
+
+	// Auto-numbering: get last sequence number for the invoice's year
+	var lastInvoice Invoice
+	tx.Where("year = ? AND status <> ?", invoice.Year, StatusDraft).Order("sequence_number DESC").First(&lastInvoice)
+
+	invoice.SequenceNumber = lastInvoice.SequenceNumber + 1
+	invoice.FormattedID = fmt.Sprintf("%04d - %d", invoice.SequenceNumber, invoice.Year) // Format: "0001 - 2025"
+	invoice.Status = StatusIssued
+
+	// Cash is received when the invoice is issued
+	if invoice.PaymentMethod == "ESPECE" {
+		paidAt := invoice.Date
+		invoice.PaidAt = &paidAt
+		invoice.Status = StatusPaid
+	}
+
+	// Seal the content, chained to the previous invoice of the year
+	s.seal(invoice, lastInvoice.ChainHash)
+	return nil
+}
+
+// DeleteDraft deletes a draft invoice; issued invoices are cancelled with a credit note instead
+func (s *Service) DeleteDraft(id uint) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var invoice Invoice
+		if err := tx.First(&invoice, id).Error; err != nil {
+			return fmt.Errorf("facture introuvable: %w", err)
+		}
+		if invoice.Status != StatusDraft {
+			return fmt.Errorf("la facture %s est émise: elle ne peut pas être supprimée, établissez un avoir", invoice.FormattedID)
+		}
+		if err := tx.Where("invoice_id = ?", id).Delete(&InvoiceItem{}).Error; err != nil {
+			return fmt.Errorf("échec de la suppression des articles du brouillon: %w", err)
+		}
+		if err := tx.Delete(&invoice).Error; err != nil {
+			return fmt.Errorf("échec de la suppression du brouillon: %w", err)
+		}
+		return nil
+	})
+}
+
+// validateRequest checks the date, client ICE and lines of an invoice request and returns its date
+func validateRequest(req InvoiceCreateRequest) (time.Time, error) {
+	// Parse date
+	date, err := time.Parse("02-01-2006", req.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("format de date invalide, JJ-MM-AAAA attendu: %w", err)
+	}
+
+	// Validate year range
+	if date.Year() < 1900 || date.Year() > 2100 {
+		return time.Time{}, fmt.Errorf("année invalide: %d (doit être entre 1900 et 2100)", date.Year())
+	}
+
+	// Validate ICE (15 characters)
+	if len(req.ClientICE) != 15 {
+		return time.Time{}, fmt.Errorf("l'ICE doit contenir exactement 15 chiffres, vous avez fourni %d", len(req.ClientICE))
+	}
+
+	// Validate items
+	if len(req.Items) == 0 {
+		return time.Time{}, fmt.Errorf("la facture doit contenir au moins un article")
+	}
+	return date, nil
+}
+
+// fillInvoice sets the client, terms, lines and totals of an invoice from a request.
+// It reserves nothing: the stock is decremented when the invoice is issued.
+func (s *Service) fillInvoice(tx *gorm.DB, invoice *Invoice, req InvoiceCreateRequest, date time.Time) error {
+	terms, err := s.resolveTerms(tx, req, date)
+	if err != nil {
+		return err
+	}
+	basis := terms.Basis
+
+	if req.LocationID != 0 {
+		if err := tx.First(&inventory.Location{}, req.LocationID).Error; err != nil {
+			return fmt.Errorf("emplacement de stock introuvable (ID: %d)", req.LocationID)
+		}
+	}
+
+	for i, item := range req.Items {
+		if item.ProductID == 0 {
+			return fmt.Errorf("article %d: aucun produit sélectionné", i+1)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("article %d: la quantité doit être supérieure à 0", i+1)
+		}
+		if item.Quantity > 100000 {
+			return fmt.Errorf("article %d: quantité excessive (%.0f). Maximum: 100,000", i+1, item.Quantity)
+		}
+		if unitPrice(item, basis) <= 0 {
+			return fmt.Errorf("article %d: le prix unitaire %s doit être supérieur à 0", i+1, basis)
+		}
+		if len(strings.TrimSpace(item.Description)) == 0 {
+			return fmt.Errorf("article %d: la description est obligatoire", i+1)
+		}
+	}
+
+	// Build lines (line discounts applied)
+	items, subtotalHT, subtotalTTC, err := s.buildItems(tx, terms, req.Items)
+	if err != nil {
+		return err
+	}
+
+	// Global discount applies to the sum of the lines, before the TVA split
+	discountAmount, totalHT, totalTVA, totalTTC, err := invoiceTotals(terms, subtotalHT, subtotalTTC, req.DiscountType, req.DiscountValue)
+	if err != nil {
+		return err
 	}
 
-	// 3. Update Invoice Fields
-	invoice.Date, _ = time.Parse("02-01-2006", req.Date)
 	invoice.CustomFormattedID = req.CustomFormattedID
+	invoice.Year = date.Year() // The invoice's year comes from its date, not the system date
+	invoice.Date = date
 	invoice.ClientName = req.ClientName
 	invoice.ClientCity = req.ClientCity
 	invoice.ClientICE = req.ClientICE
-	invoice.PaymentMethod = req.PaymentMethod
+	invoice.PricingBasis = basis
+	invoice.Currency = terms.Currency
+	invoice.ExchangeRate = terms.ExchangeRate
+	invoice.TVAExempt = terms.TVAExempt
+	invoice.ExemptionReason = terms.ExemptionReason
+	invoice.Language = terms.Language
+	invoice.LocationID = req.LocationID
+	invoice.DueDate = &terms.DueDate
+	invoice.SubtotalHT = subtotalHT
+	invoice.SubtotalTTC = subtotalTTC
+	invoice.DiscountType = req.DiscountType
+	invoice.DiscountValue = req.DiscountValue
+	invoice.DiscountAmount = discountAmount
 
-	// Clear old payment info
+	// Calculate totals
+	invoice.TotalHT = totalHT
+	invoice.TotalTVA = totalTVA
+	invoice.TotalTTC = totalTTC
+	invoice.TotalHTMAD, invoice.TotalTVAMAD, invoice.TotalTTCMAD = madTotals(totalHT, totalTTC, terms.ExchangeRate)
+	invoice.TotalInWords = s.ConvertToWords(totalTTC, terms.Currency, terms.Language)
+	invoice.Items = items
+
+	// Payment Info
+	invoice.PaymentMethod = req.PaymentMethod
 	invoice.ChequeNumber = ""
 	invoice.ChequeBank = ""
 	invoice.ChequeCity = ""
@@ -239,57 +414,68 @@ func (s *Service) UpdateInvoice(id uint, req InvoiceCreateRequest) (*InvoiceResp
 	invoice.EffetDateEcheance = ""
 	invoice.EffetBank = ""
 	invoice.EffetReference = ""
-
 	if req.PaymentMethod == "CHEQUE" && req.ChequeInfo != nil {
 		invoice.ChequeNumber = req.ChequeInfo.Number
 		invoice.ChequeBank = req.ChequeInfo.Bank
 		invoice.ChequeCity = req.ChequeInfo.City
+		// Reference removed for Cheque
 	} else if req.PaymentMethod == "EFFET" && req.EffetInfo != nil {
 		invoice.EffetCity = req.EffetInfo.City
 		invoice.EffetDateEcheance = req.EffetInfo.DateEcheance
 		invoice.EffetBank = req.EffetInfo.Bank
 		invoice.EffetReference = req.EffetInfo.Reference
 	}
+	return nil
+}
 
-	// 4. Process NEW items
-	var newItems []InvoiceItem
-	var totalTTC float64
+// UpdateInvoice updates a draft invoice; issued invoices can only be corrected with a credit note
+func (s *Service) UpdateInvoice(id uint, req InvoiceCreateRequest) (*InvoiceResponse, error) {
+	db := database.GetDB()
 
-	for _, itemReq := range req.Items {
-		// Decrement stock for NEW item
-		if err := s.inventoryService.DecreaseStock(tx, itemReq.ProductID, int(itemReq.Quantity)); err != nil {
+	// Start transaction
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
 			tx.Rollback()
-			return nil, err
 		}
+	}()
 
-		totalItem := itemReq.Quantity * itemReq.PrixUnitTTC
-		totalTTC += totalItem
+	// 1. Get existing invoice with items
+	var invoice Invoice
+	if err := tx.Preload("Items").First(&invoice, id).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("facture introuvable: %w", err)
+	}
+	if invoice.Status != StatusDraft {
+		tx.Rollback()
+		return nil, fmt.Errorf("la facture %s est émise: elle ne peut plus être modifiée, établissez un avoir", invoice.FormattedID)
+	}
 
-		// Fetch product for details
-		var product inventory.Product
-		if err := tx.First(&product, itemReq.ProductID).Error; err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("produit introuvable: %w", err)
-		}
+	date, err := validateRequest(req)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
-		newItems = append(newItems, InvoiceItem{
-			ProductID:   itemReq.ProductID,
-			Product:     product,
-			Description: itemReq.Description,
-			Quantity:    itemReq.Quantity,
-			BuyingPrice: product.BuyingPrice, // Snapshot buying price
-			PrixUnitTTC: itemReq.PrixUnitTTC,
-			TotalTTC:    totalItem,
-		})
+	// 2. Delete OLD items; a draft holds no stock
+	if err := tx.Where("invoice_id = ?", id).Delete(&InvoiceItem{}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to delete old items: %w", err)
 	}
 
-	// Calculate totals
-	totals := s.CalculateTotals(totalTTC)
-	invoice.TotalHT = totals["totalHT"].(float64)
-	invoice.TotalTVA = totals["totalTVA"].(float64)
-	invoice.TotalTTC = totals["totalTTC"].(float64)
-	invoice.TotalInWords = totals["totalInWords"].(string)
-	invoice.Items = newItems
+	// Keep the exchange rate captured at creation unless the currency or the date changes
+	if req.ExchangeRate == 0 && date.Equal(invoice.Date) && strings.EqualFold(req.Currency, invoice.Currency) {
+		req.ExchangeRate = invoice.ExchangeRate
+	}
+
+	// 3. Update Invoice Fields and NEW items
+	if err := s.fillInvoice(tx, &invoice, req, date); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
 	// Save updated invoice
 	if err := tx.Save(&invoice).Error; err != nil {
@@ -302,11 +488,283 @@ func (s *Service) UpdateInvoice(id uint, req InvoiceCreateRequest) (*InvoiceResp
 		return nil, fmt.Errorf("échec de la validation de la transaction: %w", err)
 	}
 
-	return s.toResponse(&invoice), nil
+	resp := s.toResponse(&invoice)
+	resp.Warnings = s.priceWarnings(req.ClientICE, &invoice)
+	return resp, nil
+}
+
+// buildItems fetches products and applies line discounts for each requested line.
+// Prices and discounts are read in the invoice's pricing basis; the other basis is derived per line.
+// It returns the lines and the sums of their net totals HT and TTC.
+func (s *Service) buildItems(tx *gorm.DB, terms invoiceTerms, reqItems []InvoiceItemRequest) ([]InvoiceItem, money.Amount, money.Amount, error) {
+	basis, rate := terms.Basis, terms.tvaRate()
+	var subtotalHT, subtotalTTC money.Amount
+	items := make([]InvoiceItem, len(reqItems))
+	for i, item := range reqItems {
+		// Fetch product for details
+		var product inventory.Product
+		if err := tx.First(&product, item.ProductID).Error; err != nil {
+			return nil, 0, 0, fmt.Errorf("produit introuvable (ID: %d): %w", item.ProductID, err)
+		}
+
+		gross := unitPrice(item, basis).Mul(item.Quantity)
+		discountAmount, err := resolveDiscount(gross, item.DiscountType, item.DiscountValue)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("article %d: %w", i+1, err)
+		}
+		net := gross - discountAmount
+
+		line := InvoiceItem{
+			ProductID:      item.ProductID,
+			Product:        product,
+			Description:    item.Description,
+			Quantity:       item.Quantity,
+			BuyingPrice:    product.BuyingPrice, // Snapshot buying price
+			DiscountType:   item.DiscountType,
+			DiscountValue:  item.DiscountValue,
+			DiscountAmount: discountAmount,
+		}
+		if basis == PricingHT {
+			line.PrixUnitHT = item.PrixUnitHT
+			line.PrixUnitTTC = withTVA(item.PrixUnitHT, rate)
+			line.GrossTTC = withTVA(gross, rate)
+			line.TotalHT = net
+			line.TotalTTC = withTVA(net, rate)
+		} else {
+			line.PrixUnitTTC = item.PrixUnitTTC
+			line.PrixUnitHT = item.PrixUnitTTC.Div(1 + rate/100)
+			line.GrossTTC = gross
+			line.TotalTTC = net
+			line.TotalHT = net.Div(1 + rate/100)
+		}
+		items[i] = line
+		subtotalHT += line.TotalHT
+		subtotalTTC += line.TotalTTC
+	}
+	return items, subtotalHT, subtotalTTC, nil
+}
+
+// unitPrice returns the unit price of a requested line in the given pricing basis
+func unitPrice(item InvoiceItemRequest, basis string) money.Amount {
+	if basis == PricingHT {
+		return item.PrixUnitHT
+	}
+	return item.PrixUnitTTC
+}
+
+// DefaultExemptionReason is printed on TVA-exempt invoices when no other reason is given
+const DefaultExemptionReason = "Exonération de TVA - exportation (article 92-I-1° du CGI)"
+
+// DefaultPaymentTermsDays is the payment delay of clients without specific terms
+const DefaultPaymentTermsDays = 30
+
+// invoiceTerms are the pricing basis, currency, TVA treatment and document language of an invoice
+type invoiceTerms struct {
+	Basis           string
+	Currency        string
+	ExchangeRate    float64
+	TVAExempt       bool
+	ExemptionReason string
+	Language        string
+	DueDate         time.Time
+}
+
+// tvaRate returns the TVA percentage charged under these terms
+func (t invoiceTerms) tvaRate() float64 {
+	if t.TVAExempt {
+		return 0
+	}
+	return TVARate
+}
+
+// resolveTerms validates the requested pricing basis, currency, TVA exemption and language, falling back to the client's defaults.
+// Foreign-currency invoices use the given exchange rate, else the rate recorded for the invoice date.
+func (s *Service) resolveTerms(tx *gorm.DB, req InvoiceCreateRequest, date time.Time) (invoiceTerms, error) {
+	var defaults client.Client
+	var clients []client.Client
+	if err := tx.Where("ice = ?", req.ClientICE).Limit(1).Find(&clients).Error; err != nil {
+		return invoiceTerms{}, fmt.Errorf("échec de la lecture du client: %w", err)
+	}
+	if len(clients) > 0 {
+		defaults = clients[0]
+	}
+
+	var terms invoiceTerms
+
+	basis := strings.ToUpper(strings.TrimSpace(req.PricingBasis))
+	if basis == "" {
+		basis = strings.ToUpper(defaults.PricingBasis)
+	}
+	switch basis {
+	case "", PricingTTC:
+		terms.Basis = PricingTTC
+	case PricingHT:
+		terms.Basis = PricingHT
+	default:
+		return invoiceTerms{}, fmt.Errorf("base de prix inconnue: %s (TTC ou HT attendu)", basis)
+	}
+
+	code := strings.TrimSpace(req.Currency)
+	if code == "" {
+		code = defaults.Currency
+	}
+	info, err := currency.Lookup(code)
+	if err != nil {
+		return invoiceTerms{}, err
+	}
+	terms.Currency = info.Code
+
+	switch {
+	case info.Code == currency.MAD:
+		terms.ExchangeRate = 1
+	case req.ExchangeRate < 0:
+		return invoiceTerms{}, fmt.Errorf("le taux de change doit être supérieur à 0")
+	case req.ExchangeRate > 0:
+		terms.ExchangeRate = req.ExchangeRate
+	default:
+		rate, err := s.currencyService.RateAt(info.Code, date)
+		if err != nil {
+			return invoiceTerms{}, err
+		}
+		terms.ExchangeRate = rate
+	}
+
+	terms.TVAExempt = defaults.TVAExempt
+	if req.TVAExempt != nil {
+		terms.TVAExempt = *req.TVAExempt
+	}
+	if terms.TVAExempt {
+		terms.ExemptionReason = strings.TrimSpace(req.ExemptionReason)
+		if terms.ExemptionReason == "" {
+			terms.ExemptionReason = DefaultExemptionReason
+		}
+	}
+
+	language := strings.TrimSpace(req.Language)
+	if language == "" {
+		language = defaults.Language
+	}
+	terms.Language, err = locale.Normalize(language)
+	if err != nil {
+		return invoiceTerms{}, err
+	}
+
+	terms.DueDate, err = dueDate(req, date, defaults.PaymentTermsDays)
+	if err != nil {
+		return invoiceTerms{}, err
+	}
+	return terms, nil
+}
+
+// dueDate returns the requested due date, else the effet due date, else the invoice date plus the payment terms
+func dueDate(req InvoiceCreateRequest, date time.Time, termsDays int) (time.Time, error) {
+	value := strings.TrimSpace(req.DueDate)
+	if value == "" && req.PaymentMethod == "EFFET" && req.EffetInfo != nil {
+		value = strings.TrimSpace(req.EffetInfo.DateEcheance)
+	}
+	if value == "" {
+		if termsDays <= 0 {
+			termsDays = DefaultPaymentTermsDays
+		}
+		return date.AddDate(0, 0, termsDays), nil
+	}
+
+	due, err := time.Parse("02-01-2006", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date d'échéance invalide: %s (format JJ-MM-AAAA attendu)", value)
+	}
+	if due.Before(date) {
+		return time.Time{}, fmt.Errorf("la date d'échéance ne peut pas précéder la date de la facture")
+	}
+	return due, nil
+}
+
+// invoiceTotals applies the global discount to the subtotal of the pricing basis and computes the invoice totals.
+// TTC invoices split the discounted TTC total into HT and TVA; HT invoices add the TVA to the discounted HT total.
+func invoiceTotals(terms invoiceTerms, subtotalHT, subtotalTTC money.Amount, discountType string, discountValue float64) (discount, totalHT, totalTVA, totalTTC money.Amount, err error) {
+	subtotal := subtotalTTC
+	if terms.Basis == PricingHT {
+		subtotal = subtotalHT
+	}
+
+	discount, err = resolveDiscount(subtotal, discountType, discountValue)
+	if err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("remise globale: %w", err)
+	}
+
+	if terms.Basis == PricingHT {
+		totalHT = subtotal - discount
+		totalTVA, totalTTC = addTVA(totalHT, terms.tvaRate())
+	} else {
+		totalTTC = subtotal - discount
+		totalHT, totalTVA = splitTTC(totalTTC, terms.tvaRate())
+	}
+	return discount, totalHT, totalTVA, totalTTC, nil
+}
+
+// madTotals converts invoice totals to MAD at the invoice's exchange rate.
+// The TVA takes the difference so that the MAD equivalents still add up.
+func madTotals(totalHT, totalTTC money.Amount, exchangeRate float64) (money.Amount, money.Amount, money.Amount) {
+	totalHTMAD := totalHT.Mul(exchangeRate)
+	totalTTCMAD := totalTTC.Mul(exchangeRate)
+	return totalHTMAD, totalTTCMAD - totalHTMAD, totalTTCMAD
+}
+
+// resolveDiscount converts a discount (percentage or fixed amount in DH) on a base amount, rounded to the centime
+func resolveDiscount(base money.Amount, discountType string, value float64) (money.Amount, error) {
+	switch discountType {
+	case "":
+		if value != 0 {
+			return 0, fmt.Errorf("type de remise manquant")
+		}
+		return 0, nil
+	case DiscountPercent:
+		if value < 0 || value > 100 {
+			return 0, fmt.Errorf("la remise doit être comprise entre 0 et 100%% (%.2f%% fourni)", value)
+		}
+		return base.Percent(value), nil
+	case DiscountAmount:
+		if value < 0 {
+			return 0, fmt.Errorf("la remise ne peut pas être négative")
+		}
+		discount := money.FromFloat(value)
+		if discount > base {
+			return 0, fmt.Errorf("la remise (%s DH) dépasse le montant (%s DH)", discount, base)
+		}
+		return discount, nil
+	default:
+		return 0, fmt.Errorf("type de remise inconnu: %s", discountType)
+	}
+}
+
+// priceWarnings flags lines priced below the client's price list or below the buying price.
+// Price lists are TTC in MAD, so each unit price is compared through its MAD TTC equivalent:
+// converted at the invoice's exchange rate, with the TVA added back on exempt invoices.
+func (s *Service) priceWarnings(clientICE string, inv *Invoice) []string {
+	rate := inv.ExchangeRate
+	if rate <= 0 {
+		rate = 1
+	}
+	var warnings []string
+	for i, item := range inv.Items {
+		unitPriceTTC := item.PrixUnitTTC.Mul(rate)
+		if inv.TVAExempt {
+			unitPriceTTC = item.PrixUnitHT.Mul(rate * (1 + TVARate/100))
+		}
+		lineWarnings, err := s.pricingService.CheckPrice(item.ProductID, clientICE, item.Quantity, unitPriceTTC)
+		if err != nil {
+			// Warnings are informative only: never fail a saved invoice because of them
+			continue
+		}
+		for _, w := range lineWarnings {
+			warnings = append(warnings, fmt.Sprintf("article %d (%s): %s", i+1, item.Description, w))
+		}
+	}
+	return warnings
 }
 
-// GetAllInvoices returns all invoices for a specific year
-func (s *Service) GetAllInvoices(year int) ([]InvoiceResponse, error) {
+// GetAllInvoices returns all invoices for a specific year, optionally with a given status (empty: all)
+func (s *Service) GetAllInvoices(year int, status string) ([]InvoiceResponse, error) {
 	db := database.GetDB()
 
 	// Default to current year if 0
@@ -314,8 +772,18 @@ func (s *Service) GetAllInvoices(year int) ([]InvoiceResponse, error) {
 		year = time.Now().Year()
 	}
 
+	query := db.Preload("Items.Lots").Where("year = ?", year)
+	if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+		switch status {
+		case StatusDraft, StatusIssued, StatusPaid, StatusCancelled:
+			query = query.Where("status = ?", status)
+		default:
+			return nil, fmt.Errorf("statut inconnu: %s (DRAFT, ISSUED, PAID ou CANCELLED attendu)", status)
+		}
+	}
+
 	var invoices []Invoice
-	if err := db.Preload("Items").Where("year = ?", year).Order("created_at DESC").Find(&invoices).Error; err != nil {
+	if err := query.Order("created_at DESC").Find(&invoices).Error; err != nil {
 		return nil, err
 	}
 
@@ -330,7 +798,7 @@ func (s *Service) GetAllInvoices(year int) ([]InvoiceResponse, error) {
 func (s *Service) GetInvoiceByID(id uint) (*InvoiceResponse, error) {
 	db := database.GetDB()
 	var invoice Invoice
-	if err := db.Preload("Items").First(&invoice, id).Error; err != nil {
+	if err := db.Preload("Items.Lots").First(&invoice, id).Error; err != nil {
 		return nil, fmt.Errorf("invoice not found: %w", err)
 	}
 	return s.toResponse(&invoice), nil
@@ -360,61 +828,153 @@ func (s *Service) GetAvailableYears() ([]int, error) {
 	return years, nil
 }
 
-// ConvertToWords converts a number to French words
-func (s *Service) ConvertToWords(amount float64) string {
-	// Split into whole and decimal parts
-	wholePart := int(amount)
-	decimalPart := int(math.Round((amount - float64(wholePart)) * 100))
+// currencyWords are the English and Arabic names of the currency unit and subunit (French names come from currency.Info)
+var currencyWords = map[string]map[string][2]string{
+	locale.English: {
+		currency.MAD: {"dirhams", "centimes"},
+		currency.EUR: {"euros", "cents"},
+		currency.USD: {"dollars", "cents"},
+	},
+	locale.Arabic: {
+		currency.MAD: {"درهم", "سنتيم"},
+		currency.EUR: {"يورو", "سنت"},
+		currency.USD: {"دولار", "سنت"},
+	},
+}
+
+// ConvertToWords converts an amount in the given currency (empty: MAD) to words in the given language
+// (empty: French). The bilingual layout uses French, its Arabic line being rendered on the PDF.
+func (s *Service) ConvertToWords(amount money.Amount, currencyCode string, language string) string {
+	info, err := currency.Lookup(currencyCode)
+	if err != nil {
+		info, _ = currency.Lookup(currency.MAD)
+	}
+	lang, err := locale.Normalize(language)
+	if err != nil || lang == locale.Bilingual {
+		lang = locale.French
+	}
+
+	toWords, unit, subunit, colon, and := IntToFrench, info.Unit, info.Subunit, " : ", " et "
+	switch lang {
+	case locale.English:
+		toWords, colon, and = IntToEnglish, ": ", " and "
+	case locale.Arabic:
+		toWords, colon, and = IntToArabic, ": ", " و"
+	}
+	if words, ok := currencyWords[lang][info.Code]; ok {
+		unit, subunit = words[0], words[1]
+	}
+
+	// Split into whole and decimal parts (exact on integer centimes)
+	wholePart := int(amount.Units())
+	decimalPart := int(amount.Cents())
 
-	// Convert to French words
-	wholeWords := IntToFrench(wholePart)
+	wholeWords := toWords(wholePart)
 
 	// Capitalize first letter
-	if len(wholeWords) > 0 {
-		wholeWords = strings.ToUpper(string(wholeWords[0])) + wholeWords[1:]
+	if r, size := utf8.DecodeRuneInString(wholeWords); size > 0 {
+		wholeWords = string(unicode.ToUpper(r)) + wholeWords[size:]
 	}
 
-	result := fmt.Sprintf("Arrêté la présente facture à la somme de : %s dirhams", wholeWords)
+	result := fmt.Sprintf("%s%s%s %s", locale.Translate(lang, "Arrêté la présente facture à la somme de"), colon, wholeWords, unit)
 
 	if decimalPart > 0 {
-		decimalWords := IntToFrench(decimalPart)
-		result += fmt.Sprintf(" et %s centimes", decimalWords)
+		result += fmt.Sprintf("%s%s %s", and, toWords(decimalPart), subunit)
 	}
 
 	return result
 }
 
-// CalculateTotals calculates HT, TVA from TTC (for preview)
-func (s *Service) CalculateTotals(totalTTC float64) map[string]interface{} {
-	totalHT := totalTTC / 1.20
-	totalTVA := totalTTC - totalHT
+// CalculateTotals calculates the invoice totals from a TTC amount (HT and TVA back-computed)
+// or from an HT amount (TVA added), depending on the pricing basis (for preview)
+func (s *Service) CalculateTotals(amount money.Amount, basis string, currencyCode string, tvaExempt bool, language string) map[string]interface{} {
+	terms := invoiceTerms{Basis: strings.ToUpper(basis), TVAExempt: tvaExempt}
+
+	var totalHT, totalTVA, totalTTC money.Amount
+	if terms.Basis == PricingHT {
+		totalHT = amount
+		totalTVA, totalTTC = addTVA(totalHT, terms.tvaRate())
+	} else {
+		totalTTC = amount
+		totalHT, totalTVA = splitTTC(totalTTC, terms.tvaRate())
+	}
 
 	return map[string]interface{}{
-		"totalHT":      math.Round(totalHT*100) / 100,
-		"totalTVA":     math.Round(totalTVA*100) / 100,
-		"totalTTC":     math.Round(totalTTC*100) / 100,
-		"totalInWords": s.ConvertToWords(totalTTC),
+		"totalHT":      totalHT,
+		"totalTVA":     totalTVA,
+		"totalTTC":     totalTTC,
+		"totalInWords": s.ConvertToWords(totalTTC, currencyCode, language),
 	}
 }
 
+// TVARate is the TVA percentage applied to invoices that are not exempt
+const TVARate = 20.0
+
+// splitTTC splits an invoice-level TTC total into HT and TVA at the given rate.
+// Rounding policy: line totals are rounded per line, the HT is rounded once on the invoice total,
+// and the TVA takes the difference so that HT + TVA always equals TTC to the centime.
+func splitTTC(totalTTC money.Amount, rate float64) (money.Amount, money.Amount) {
+	totalHT := totalTTC.Div(1 + rate/100)
+	return totalHT, totalTTC - totalHT
+}
+
+// addTVA adds the TVA to an invoice-level HT total, rounding the TVA once on the total.
+// HT invoices therefore keep the negotiated HT amounts exact to the centime.
+func addTVA(totalHT money.Amount, rate float64) (money.Amount, money.Amount) {
+	totalTVA := totalHT.Percent(rate)
+	return totalTVA, totalHT + totalTVA
+}
+
+// withTVA returns the TTC equivalent of an HT amount
+func withTVA(ht money.Amount, rate float64) money.Amount {
+	_, ttc := addTVA(ht, rate)
+	return ttc
+}
+
 // toResponse converts Invoice model to response DTO
 func (s *Service) toResponse(inv *Invoice) *InvoiceResponse {
 	resp := &InvoiceResponse{
 		ID:                inv.ID,
 		FormattedID:       inv.FormattedID,
+		Status:            inv.Status,
 		CustomFormattedID: inv.CustomFormattedID,
 		Date:              inv.Date.Format("02-01-2006"),
 		ClientName:        inv.ClientName,
 		ClientCity:        inv.ClientCity,
 		ClientICE:         inv.ClientICE,
+		PricingBasis:      inv.PricingBasis,
+		Currency:          inv.Currency,
+		ExchangeRate:      inv.ExchangeRate,
+		TVAExempt:         inv.TVAExempt,
+		ExemptionReason:   inv.ExemptionReason,
+		Language:          inv.Language,
+		LocationID:        inv.LocationID,
+		SubtotalHT:        inv.SubtotalHT,
+		SubtotalTTC:       inv.SubtotalTTC,
+		DiscountType:      inv.DiscountType,
+		DiscountValue:     inv.DiscountValue,
+		DiscountAmount:    inv.DiscountAmount,
 		TotalHT:           inv.TotalHT,
 		TotalTVA:          inv.TotalTVA,
 		TotalTTC:          inv.TotalTTC,
 		TotalInWords:      inv.TotalInWords,
+		TotalHTMAD:        inv.TotalHTMAD,
+		TotalTVAMAD:       inv.TotalTVAMAD,
+		TotalTTCMAD:       inv.TotalTTCMAD,
 		PaymentMethod:     inv.PaymentMethod,
+		ArchivePath:       inv.ArchivePath,
+		ArchiveHash:       inv.ArchiveHash,
+		ArchivedAt:        inv.ArchivedAt,
 		Items:             inv.Items,
 	}
 
+	if inv.DueDate != nil {
+		resp.DueDate = inv.DueDate.Format("02-01-2006")
+	}
+	if inv.PaidAt != nil {
+		resp.PaidAt = inv.PaidAt.Format("02-01-2006")
+	}
+
 	if inv.PaymentMethod == "CHEQUE" && inv.ChequeNumber != "" {
 		resp.ChequeInfo = &ChequeInfo{
 			Number:    inv.ChequeNumber,
@@ -436,26 +996,31 @@ func (s *Service) toResponse(inv *Invoice) *InvoiceResponse {
 	return resp
 }
 
+// globalDiscountRatio is the SQL factor turning an item's net total into its MAD share of the invoice total:
+// it applies the invoice's global discount and its exchange rate
+const globalDiscountRatio = "(CASE WHEN invoices.subtotal_ttc > 0 THEN CAST(invoices.total_ttc_mad AS REAL) / invoices.subtotal_ttc ELSE invoices.exchange_rate END)"
+
 type MonthlyRevenue struct {
-	Month   string  `json:"month"`
-	Revenue float64 `json:"revenue"`
+	Month   string       `json:"month"`
+	Revenue money.Amount `json:"revenue"`
 }
 
 type ClientStat struct {
-	Name         string  `json:"name"`
-	TotalSpend   float64 `json:"totalSpend"`
-	InvoiceCount int64   `json:"invoiceCount"`
+	Name         string       `json:"name"`
+	TotalSpend   money.Amount `json:"totalSpend"`
+	InvoiceCount int64        `json:"invoiceCount"`
 }
 
 type ProductStat struct {
-	Name         string  `json:"name"`
-	QuantitySold int     `json:"quantitySold"`
-	Revenue      float64 `json:"revenue"`
+	Name         string       `json:"name"`
+	QuantitySold int          `json:"quantitySold"`
+	Revenue      money.Amount `json:"revenue"`
 }
 
+// InvoiceStats amounts are MAD equivalents, whatever the invoice currencies
 type InvoiceStats struct {
-	TotalRevenue   float64
-	TotalNetProfit float64
+	TotalRevenue   money.Amount
+	TotalNetProfit money.Amount
 	TotalInvoices  int64
 	RecentInvoices []InvoiceResponse
 	MonthlyRevenue []MonthlyRevenue
@@ -472,40 +1037,70 @@ func (s *Service) GetStats(year int) (*InvoiceStats, error) {
 		year = time.Now().Year()
 	}
 
+	// Drafts and cancelled invoices are left out
+	active := []string{StatusIssued, StatusPaid}
+
 	// Total Invoices
-	if err := db.Model(&Invoice{}).Where("year = ?", year).Count(&stats.TotalInvoices).Error; err != nil {
+	if err := db.Model(&Invoice{}).Where("year = ? AND status IN ?", year, active).Count(&stats.TotalInvoices).Error; err != nil {
 		return nil, err
 	}
 
 	// Total Revenue
 	var result struct {
-		Total float64
+		Total money.Amount
 	}
-	if err := db.Model(&Invoice{}).Where("year = ?", year).Select("sum(total_ttc) as total").Scan(&result).Error; err != nil {
+	if err := db.Model(&Invoice{}).Where("year = ? AND status IN ?", year, active).Select("sum(total_ttc_mad) as total").Scan(&result).Error; err != nil {
 		return nil, err
 	}
 	stats.TotalRevenue = result.Total
 
+	// Net of the partial credit notes of the year; fully credited invoices are already left out.
+	// The profit, monthly revenue and top clients and products are netted the same way.
+	creditNotes := func() *gorm.DB {
+		return db.Table("credit_notes").
+			Joins("JOIN invoices ON credit_notes.invoice_id = invoices.id").
+			Where("credit_notes.deleted_at IS NULL AND credit_notes.year = ? AND invoices.status IN ?", year, active)
+	}
+	creditNoteItems := func() *gorm.DB {
+		return creditNotes().Joins("JOIN credit_note_items ON credit_note_items.credit_note_id = credit_notes.id")
+	}
+	var credited struct {
+		Total money.Amount
+	}
+	if err := creditNotes().Select("sum(credit_notes.total_ttc_mad) as total").Scan(&credited).Error; err != nil {
+		return nil, err
+	}
+	stats.TotalRevenue -= credited.Total
+
 	// Total Net Profit
-	// Profit = Sum( (Item.TotalTTC) - (Item.BuyingPrice * Item.Quantity) )
+	// Profit = Sum( (Item.TotalTTC * GlobalDiscountRatio) - (Item.BuyingPrice * Item.Quantity) )
 	// We use the stored BuyingPrice from invoice_items for historical accuracy
 	var profitResult struct {
-		Total float64
+		Total money.Amount
 	}
 	err := db.Table("invoice_items").
-		Select("SUM(invoice_items.total_ttc - (invoice_items.buying_price * invoice_items.quantity)) as total").
+		Select("SUM(invoice_items.total_ttc * "+globalDiscountRatio+" - (invoice_items.buying_price * invoice_items.quantity)) as total").
 		Joins("JOIN invoices ON invoice_items.invoice_id = invoices.id").
-		Where("invoices.deleted_at IS NULL AND invoices.year = ?", year).
+		Where("invoices.deleted_at IS NULL AND invoices.year = ? AND invoices.status IN ?", year, active).
 		Scan(&profitResult).Error
 
 	if err != nil {
 		return nil, err
 	}
-	stats.TotalNetProfit = profitResult.Total
+	var creditedProfit struct {
+		Total money.Amount
+	}
+	if err := creditNoteItems().
+		Select("SUM(credit_note_items.total_ttc * " + globalDiscountRatio + " - (invoice_items.buying_price * credit_note_items.quantity)) as total").
+		Joins("JOIN invoice_items ON invoice_items.id = credit_note_items.invoice_item_id").
+		Scan(&creditedProfit).Error; err != nil {
+		return nil, err
+	}
+	stats.TotalNetProfit = profitResult.Total - creditedProfit.Total
 
 	// Recent Invoices (Filtered by year)
 	var recent []Invoice
-	if err := db.Preload("Items").Where("year = ?", year).Order("created_at desc").Limit(5).Find(&recent).Error; err != nil {
+	if err := db.Preload("Items").Where("year = ? AND status IN ?", year, active).Order("created_at desc").Limit(5).Find(&recent).Error; err != nil {
 		return nil, err
 	}
 
@@ -515,8 +1110,8 @@ func (s *Service) GetStats(year int) (*InvoiceStats, error) {
 
 	// Monthly Revenue (Selected Year)
 	rows, err := db.Model(&Invoice{}).
-		Select("strftime('%m', date) as month, sum(total_ttc) as revenue").
-		Where("year = ?", year).
+		Select("strftime('%m', date) as month, sum(total_ttc_mad) as revenue").
+		Where("year = ? AND status IN ?", year, active).
 		Group("month").
 		Order("month").
 		Rows()
@@ -526,7 +1121,7 @@ func (s *Service) GetStats(year int) (*InvoiceStats, error) {
 	defer rows.Close()
 
 	// Initialize all months with 0
-	revenueMap := make(map[string]float64)
+	revenueMap := make(map[string]money.Amount)
 	months := []string{"01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11", "12"}
 	for _, m := range months {
 		revenueMap[m] = 0
@@ -534,12 +1129,27 @@ func (s *Service) GetStats(year int) (*InvoiceStats, error) {
 
 	for rows.Next() {
 		var month string
-		var revenue float64
+		var revenue money.Amount
 		if err := rows.Scan(&month, &revenue); err == nil {
 			revenueMap[month] = revenue
 		}
 	}
 
+	// Credit notes count in the month they are issued
+	var creditedMonths []struct {
+		Month    string
+		Credited money.Amount
+	}
+	if err := creditNotes().
+		Select("strftime('%m', credit_notes.date) as month, sum(credit_notes.total_ttc_mad) as credited").
+		Group("month").
+		Scan(&creditedMonths).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range creditedMonths {
+		revenueMap[m.Month] -= m.Credited
+	}
+
 	// Convert to slice
 	monthNames := []string{"Jan", "Fév", "Mar", "Avr", "Mai", "Juin", "Juil", "Août", "Sep", "Oct", "Nov", "Déc"}
 	for i, m := range months {
@@ -550,10 +1160,16 @@ func (s *Service) GetStats(year int) (*InvoiceStats, error) {
 	}
 
 	// Top Clients (Selected Year)
-	clientRows, err := db.Model(&Invoice{}).
-		Select("client_name, sum(total_ttc) as total_spend, count(id) as invoice_count").
-		Where("year = ?", year).
-		Group("client_name").
+	clientSales := db.Model(&Invoice{}).
+		Select("client_name, sum(total_ttc_mad) as total_spend, count(id) as invoice_count").
+		Where("year = ? AND status IN ?", year, active).
+		Group("client_name")
+	clientCredits := creditNotes().
+		Select("invoices.client_name, sum(credit_notes.total_ttc_mad) as credited").
+		Group("invoices.client_name")
+	clientRows, err := db.Table("(?) AS sales", clientSales).
+		Joins("LEFT JOIN (?) AS credits ON credits.client_name = sales.client_name", clientCredits).
+		Select("sales.client_name, sales.total_spend - COALESCE(credits.credited, 0) as total_spend, sales.invoice_count").
 		Order("total_spend desc").
 		Limit(5).
 		Rows()
@@ -571,11 +1187,17 @@ func (s *Service) GetStats(year int) (*InvoiceStats, error) {
 
 	// Top Products (Selected Year)
 	// Need to join with invoices to filter by year
-	productRows, err := db.Table("invoice_items").
-		Select("invoice_items.description, sum(invoice_items.quantity) as quantity_sold, sum(invoice_items.total_ttc) as revenue").
+	productSales := db.Table("invoice_items").
+		Select("invoice_items.description, sum(invoice_items.quantity) as quantity_sold, sum(invoice_items.total_ttc * "+globalDiscountRatio+") as revenue").
 		Joins("JOIN invoices ON invoice_items.invoice_id = invoices.id").
-		Where("invoices.year = ? AND invoices.deleted_at IS NULL", year).
-		Group("invoice_items.description").
+		Where("invoices.year = ? AND invoices.deleted_at IS NULL AND invoices.status IN ?", year, active).
+		Group("invoice_items.description")
+	productCredits := creditNoteItems().
+		Select("credit_note_items.description, sum(credit_note_items.quantity) as quantity, sum(credit_note_items.total_ttc * " + globalDiscountRatio + ") as revenue").
+		Group("credit_note_items.description")
+	productRows, err := db.Table("(?) AS sales", productSales).
+		Joins("LEFT JOIN (?) AS credits ON credits.description = sales.description", productCredits).
+		Select("sales.description, sales.quantity_sold - COALESCE(credits.quantity, 0) as quantity_sold, sales.revenue - COALESCE(credits.revenue, 0) as revenue").
 		Order("quantity_sold desc").
 		Limit(5).
 		Rows()
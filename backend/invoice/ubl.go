@@ -0,0 +1,400 @@
+package invoice
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"factureapp/backend/currency"
+	"factureapp/backend/database"
+	"factureapp/backend/inventory"
+	"factureapp/backend/money"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// UBL 2.1 namespaces
+const (
+	ublInvoiceNS = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	ublCACNS     = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	ublCBCNS     = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+)
+
+// UBL code lists
+const (
+	ublInvoiceTypeCode = "380" // UNCL1001: commercial invoice
+	ublUnitCode        = "C62" // UN/ECE rec. 20: one (unit)
+	ublCountryMorocco  = "MA"
+	ublTaxSchemeVAT    = "VAT"
+	ublTaxStandard     = "S" // UNCL5305: standard rate
+	ublTaxExempt       = "E" // UNCL5305: exempt from tax
+	ublSchemeICE       = "ICE"
+)
+
+// ublPaymentMeans maps payment methods to UNCL4461 payment means codes
+var ublPaymentMeans = map[string]string{
+	"ESPECE": "10", // In cash
+	"CHEQUE": "20", // Cheque
+	"EFFET":  "24", // Bill of exchange
+}
+
+// ublAmount is a monetary amount with its currency
+type ublAmount struct {
+	CurrencyID string `xml:"currencyID,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type ublIdentifier struct {
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type ublQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// UBLInvoice is the root of a UBL 2.1 invoice document
+type UBLInvoice struct {
+	XMLName xml.Name `xml:"Invoice"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	CAC     string   `xml:"xmlns:cac,attr"`
+	CBC     string   `xml:"xmlns:cbc,attr"`
+
+	UBLVersionID         string          `xml:"cbc:UBLVersionID"`
+	ID                   string          `xml:"cbc:ID"`
+	IssueDate            string          `xml:"cbc:IssueDate"`
+	DueDate              string          `xml:"cbc:DueDate,omitempty"`
+	InvoiceTypeCode      string          `xml:"cbc:InvoiceTypeCode"`
+	Notes                []string        `xml:"cbc:Note"`
+	DocumentCurrencyCode string          `xml:"cbc:DocumentCurrencyCode"`
+	TaxCurrencyCode      string          `xml:"cbc:TaxCurrencyCode,omitempty"`
+	Supplier             ublParty        `xml:"cac:AccountingSupplierParty>cac:Party"`
+	Customer             ublParty        `xml:"cac:AccountingCustomerParty>cac:Party"`
+	PaymentMeans         *ublPaymentMean `xml:"cac:PaymentMeans,omitempty"`
+	AllowanceCharges     []ublAllowance  `xml:"cac:AllowanceCharge"`
+	TaxExchangeRate      *ublExchange    `xml:"cac:TaxExchangeRate,omitempty"`
+	TaxTotals            []ublTaxTotal   `xml:"cac:TaxTotal"`
+	MonetaryTotal        ublMonetary     `xml:"cac:LegalMonetaryTotal"`
+	Lines                []ublLine       `xml:"cac:InvoiceLine"`
+}
+
+type ublParty struct {
+	Identification ublIdentifier  `xml:"cac:PartyIdentification>cbc:ID"`
+	Name           string         `xml:"cac:PartyName>cbc:Name"`
+	Address        ublAddress     `xml:"cac:PostalAddress"`
+	TaxScheme      ublPartyTax    `xml:"cac:PartyTaxScheme"`
+	LegalEntity    ublLegalEntity `xml:"cac:PartyLegalEntity"`
+	Contact        *ublContact    `xml:"cac:Contact,omitempty"`
+}
+
+type ublAddress struct {
+	StreetName string `xml:"cbc:StreetName,omitempty"`
+	CityName   string `xml:"cbc:CityName,omitempty"`
+	Country    string `xml:"cac:Country>cbc:IdentificationCode"`
+}
+
+type ublPartyTax struct {
+	CompanyID string `xml:"cbc:CompanyID"`
+	TaxScheme string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type ublLegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+	CompanyID        string `xml:"cbc:CompanyID,omitempty"` // Registre de commerce
+}
+
+type ublContact struct {
+	Telephone string `xml:"cbc:Telephone,omitempty"`
+	Email     string `xml:"cbc:ElectronicMail,omitempty"`
+}
+
+type ublPaymentMean struct {
+	Code            string `xml:"cbc:PaymentMeansCode"`
+	PaymentDueDate  string `xml:"cbc:PaymentDueDate,omitempty"`
+	InstructionNote string `xml:"cbc:InstructionNote,omitempty"`
+	PaymentID       string `xml:"cbc:PaymentID,omitempty"` // Cheque number or effet reference
+}
+
+type ublAllowance struct {
+	ChargeIndicator bool            `xml:"cbc:ChargeIndicator"`
+	Reason          string          `xml:"cbc:AllowanceChargeReason"`
+	Amount          ublAmount       `xml:"cbc:Amount"`
+	TaxCategory     *ublTaxCategory `xml:"cac:TaxCategory,omitempty"`
+}
+
+type ublExchange struct {
+	SourceCurrencyCode string `xml:"cbc:SourceCurrencyCode"`
+	TargetCurrencyCode string `xml:"cbc:TargetCurrencyCode"`
+	CalculationRate    string `xml:"cbc:CalculationRate"`
+	Date               string `xml:"cbc:Date"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount    ublAmount        `xml:"cbc:TaxAmount"`
+	TaxSubtotals []ublTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount ublAmount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     ublAmount      `xml:"cbc:TaxAmount"`
+	TaxCategory   ublTaxCategory `xml:"cac:TaxCategory"`
+}
+
+type ublTaxCategory struct {
+	ID              string `xml:"cbc:ID"`
+	Percent         string `xml:"cbc:Percent"`
+	ExemptionReason string `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme       string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type ublMonetary struct {
+	LineExtensionAmount  ublAmount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount   ublAmount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount   ublAmount  `xml:"cbc:TaxInclusiveAmount"`
+	AllowanceTotalAmount *ublAmount `xml:"cbc:AllowanceTotalAmount,omitempty"`
+	PayableAmount        ublAmount  `xml:"cbc:PayableAmount"`
+}
+
+type ublLine struct {
+	ID                  string         `xml:"cbc:ID"`
+	Quantity            ublQuantity    `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount ublAmount      `xml:"cbc:LineExtensionAmount"`
+	AllowanceCharges    []ublAllowance `xml:"cac:AllowanceCharge"`
+	Item                ublItem        `xml:"cac:Item"`
+	Price               ublPrice       `xml:"cac:Price"`
+}
+
+type ublItem struct {
+	Name                  string         `xml:"cbc:Name"`
+	SellersItemID         string         `xml:"cac:SellersItemIdentification>cbc:ID,omitempty"`
+	ClassifiedTaxCategory ublTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+type ublPrice struct {
+	PriceAmount ublAmount `xml:"cbc:PriceAmount"`
+}
+
+// ExportUBL writes the invoice as a UBL 2.1 XML file next to its PDF and returns the file path.
+// The document is checked by ValidateUBL before being written.
+func (s *Service) ExportUBL(invoiceID uint) (string, error) {
+	inv, err := s.GetInvoiceByID(invoiceID)
+	if err != nil {
+		return "", fmt.Errorf("impossible de récupérer la facture: %w", err)
+	}
+	data, err := s.BuildUBL(inv)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := s.monthDir(inv)
+	if err != nil {
+		return "", err
+	}
+	xmlPath := filepath.Join(dir, strings.TrimSuffix(PDFFileName(inv), ".pdf")+".xml")
+	if err := os.WriteFile(xmlPath, data, 0644); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder le fichier XML (%s): vérifiez les permissions et l'espace disque disponible", xmlPath)
+	}
+	return xmlPath, nil
+}
+
+// ExportPDFWithUBL generates the invoice PDF with its UBL XML embedded as an attached file,
+// so that a single document is both readable and machine-processable, and returns the file path.
+// The archived original PDF is left untouched.
+func (s *Service) ExportPDFWithUBL(invoiceID uint) (string, error) {
+	pdfPath, err := s.GeneratePDF(invoiceID)
+	if err != nil {
+		return "", err
+	}
+	xmlPath, err := s.ExportUBL(invoiceID)
+	if err != nil {
+		return "", err
+	}
+
+	outPath := strings.TrimSuffix(pdfPath, ".pdf") + "_UBL.pdf"
+	if err := api.AddAttachmentsFile(pdfPath, outPath, []string{xmlPath}, false, nil); err != nil {
+		return "", fmt.Errorf("échec de l'intégration du XML dans le PDF: %w", err)
+	}
+	return outPath, nil
+}
+
+// BuildUBL converts an invoice to a validated UBL 2.1 XML document
+func (s *Service) BuildUBL(inv *InvoiceResponse) ([]byte, error) {
+	if inv.Status == StatusDraft {
+		return nil, fmt.Errorf("émettez la facture avant l'export électronique: un brouillon n'a pas de numéro")
+	}
+	company := s.settingsService.GetCompany(CompanyICE)
+	if company.Name == "" {
+		return nil, fmt.Errorf("renseignez la raison sociale de la société dans les paramètres avant l'export électronique")
+	}
+
+	issueDate, err := time.Parse("02-01-2006", inv.Date)
+	if err != nil {
+		return nil, fmt.Errorf("date de facture invalide: %s", inv.Date)
+	}
+	number := inv.FormattedID
+	if inv.CustomFormattedID != "" {
+		number = inv.CustomFormattedID
+	}
+	cur := inv.Currency
+	amount := func(a money.Amount) ublAmount {
+		return ublAmount{CurrencyID: cur, Value: a.String()}
+	}
+
+	taxCategory := ublTaxCategory{ID: ublTaxStandard, Percent: fmt.Sprintf("%g", TVARate), TaxScheme: ublTaxSchemeVAT}
+	if inv.TVAExempt {
+		taxCategory = ublTaxCategory{ID: ublTaxExempt, Percent: "0", ExemptionReason: inv.ExemptionReason, TaxScheme: ublTaxSchemeVAT}
+	}
+
+	doc := UBLInvoice{
+		XMLNS:                ublInvoiceNS,
+		CAC:                  ublCACNS,
+		CBC:                  ublCBCNS,
+		UBLVersionID:         "2.1",
+		ID:                   number,
+		IssueDate:            issueDate.Format("2006-01-02"),
+		InvoiceTypeCode:      ublInvoiceTypeCode,
+		DocumentCurrencyCode: cur,
+		Supplier: ublParty{
+			Identification: ublIdentifier{SchemeID: ublSchemeICE, Value: company.ICE},
+			Name:           company.Name,
+			Address:        ublAddress{StreetName: company.Address, CityName: company.City, Country: ublCountryMorocco},
+			TaxScheme:      ublPartyTax{CompanyID: company.ICE, TaxScheme: ublTaxSchemeVAT},
+			LegalEntity:    ublLegalEntity{RegistrationName: company.Name, CompanyID: company.RC},
+		},
+		Customer: ublParty{
+			Identification: ublIdentifier{SchemeID: ublSchemeICE, Value: inv.ClientICE},
+			Name:           inv.ClientName,
+			Address:        ublAddress{CityName: inv.ClientCity, Country: ublCountryMorocco},
+			TaxScheme:      ublPartyTax{CompanyID: inv.ClientICE, TaxScheme: ublTaxSchemeVAT},
+			LegalEntity:    ublLegalEntity{RegistrationName: inv.ClientName},
+		},
+	}
+	if company.Phone != "" || company.Email != "" {
+		doc.Supplier.Contact = &ublContact{Telephone: company.Phone, Email: company.Email}
+	}
+	if inv.TotalInWords != "" {
+		doc.Notes = append(doc.Notes, inv.TotalInWords)
+	}
+	if inv.TVAExempt {
+		doc.Notes = append(doc.Notes, inv.ExemptionReason)
+	}
+
+	var dueDate string
+	if due, err := time.Parse("02-01-2006", inv.DueDate); err == nil {
+		dueDate = due.Format("2006-01-02")
+		doc.DueDate = dueDate
+	}
+
+	if code, ok := ublPaymentMeans[inv.PaymentMethod]; ok {
+		means := &ublPaymentMean{Code: code, PaymentDueDate: dueDate}
+		switch {
+		case inv.ChequeInfo != nil:
+			means.PaymentID = inv.ChequeInfo.Number
+			means.InstructionNote = strings.TrimSpace(inv.ChequeInfo.Bank + " " + inv.ChequeInfo.City)
+		case inv.EffetInfo != nil:
+			means.PaymentID = inv.EffetInfo.Reference
+			means.InstructionNote = strings.TrimSpace(inv.EffetInfo.Bank + " " + inv.EffetInfo.City)
+		}
+		doc.PaymentMeans = means
+	}
+
+	// The global discount is exported HT, as the difference between the lines and the taxable total
+	allowanceHT := inv.SubtotalHT - inv.TotalHT
+	if allowanceHT > 0 {
+		category := taxCategory
+		category.ExemptionReason = ""
+		doc.AllowanceCharges = append(doc.AllowanceCharges, ublAllowance{
+			Reason:      "Remise",
+			Amount:      amount(allowanceHT),
+			TaxCategory: &category,
+		})
+	}
+
+	doc.TaxTotals = []ublTaxTotal{{
+		TaxAmount: amount(inv.TotalTVA),
+		TaxSubtotals: []ublTaxSubtotal{{
+			TaxableAmount: amount(inv.TotalHT),
+			TaxAmount:     amount(inv.TotalTVA),
+			TaxCategory:   taxCategory,
+		}},
+	}}
+
+	// Foreign-currency invoices also state the TVA in dirhams, with the rate used
+	if cur != currency.MAD {
+		doc.TaxCurrencyCode = currency.MAD
+		doc.TaxExchangeRate = &ublExchange{
+			SourceCurrencyCode: cur,
+			TargetCurrencyCode: currency.MAD,
+			CalculationRate:    fmt.Sprintf("%g", inv.ExchangeRate),
+			Date:               doc.IssueDate,
+		}
+		doc.TaxTotals = append(doc.TaxTotals, ublTaxTotal{TaxAmount: ublAmount{CurrencyID: currency.MAD, Value: inv.TotalTVAMAD.String()}})
+	}
+
+	doc.MonetaryTotal = ublMonetary{
+		LineExtensionAmount: amount(inv.SubtotalHT),
+		TaxExclusiveAmount:  amount(inv.TotalHT),
+		TaxInclusiveAmount:  amount(inv.TotalTTC),
+		PayableAmount:       amount(inv.TotalTTC),
+	}
+	if allowanceHT > 0 {
+		total := amount(allowanceHT)
+		doc.MonetaryTotal.AllowanceTotalAmount = &total
+	}
+
+	references := productReferences(inv.Items)
+	lineCategory := taxCategory
+	lineCategory.ExemptionReason = ""
+	for i, item := range inv.Items {
+		line := ublLine{
+			ID:                  fmt.Sprintf("%d", i+1),
+			Quantity:            ublQuantity{UnitCode: ublUnitCode, Value: fmt.Sprintf("%g", item.Quantity)},
+			LineExtensionAmount: amount(item.TotalHT),
+			Item: ublItem{
+				Name:                  item.Description,
+				SellersItemID:         references[item.ProductID],
+				ClassifiedTaxCategory: lineCategory,
+			},
+			Price: ublPrice{PriceAmount: amount(item.PrixUnitHT)},
+		}
+		// Line discounts are exported HT, so that quantity x price - discount gives the line amount
+		if lineDiscount := item.PrixUnitHT.Mul(item.Quantity) - item.TotalHT; item.DiscountAmount > 0 && lineDiscount > 0 {
+			line.AllowanceCharges = []ublAllowance{{Reason: "Remise", Amount: amount(lineDiscount)}}
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("échec de la génération du XML: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+
+	if err := ValidateUBL(data); err != nil {
+		return nil, fmt.Errorf("facture électronique invalide: %w", err)
+	}
+	return data, nil
+}
+
+// productReferences returns the product references of invoice lines, by product ID
+func productReferences(items []InvoiceItem) map[uint]string {
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+
+	references := make(map[uint]string)
+	var products []inventory.Product
+	db := database.GetDB()
+	if err := db.Unscoped().Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return references
+	}
+	for _, p := range products {
+		references[p.ID] = p.Reference
+	}
+	return references
+}
@@ -0,0 +1,184 @@
+package invoice
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"factureapp/backend/client"
+	"factureapp/backend/currency"
+	"factureapp/backend/database"
+	"factureapp/backend/inventory"
+	"factureapp/backend/layout"
+	"factureapp/backend/money"
+	"factureapp/backend/pricing"
+	"factureapp/backend/settings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newUBLTestService opens an empty database in a temporary folder and returns an invoice service
+// with a configured company and one product in stock
+func newUBLTestService(t *testing.T) (*Service, uint) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "invoices.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("ouverture de la base: %v", err)
+	}
+	database.DB = db
+
+	inv := inventory.NewService()
+	pr := pricing.NewService()
+	cur := currency.NewService()
+	lay := layout.NewService()
+	set := settings.NewService()
+	s := NewService(inv, pr, cur, lay, set)
+	for _, migrate := range []func() error{set.Migrate, inv.Migrate, s.Migrate, client.NewService().Migrate, pr.Migrate, cur.Migrate, lay.Migrate} {
+		if err := migrate(); err != nil {
+			t.Fatalf("migration: %v", err)
+		}
+	}
+
+	if err := set.SaveCompany(settings.Company{Name: "Atlas Distribution", Address: "12 rue des Orangers", City: "Rabat", ICE: "001844544000022", RC: "12345"}); err != nil {
+		t.Fatalf("société: %v", err)
+	}
+	product, err := inv.CreateProduct(inventory.Product{Reference: "P-1", Name: "Peinture", SellingPriceTTC: money.FromFloat(120), CurrentStock: 100})
+	if err != nil {
+		t.Fatalf("produit: %v", err)
+	}
+	return s, product.ID
+}
+
+// buildTestUBL issues an invoice and exports it
+func buildTestUBL(t *testing.T, s *Service, req InvoiceCreateRequest) string {
+	t.Helper()
+	created, err := s.CreateInvoice(req)
+	if err != nil {
+		t.Fatalf("création de la facture: %v", err)
+	}
+	inv, err := s.GetInvoiceByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.BuildUBL(inv)
+	if err != nil {
+		t.Fatalf("export UBL: %v", err)
+	}
+	return string(data)
+}
+
+func TestBuildUBLValidates(t *testing.T) {
+	s, productID := newUBLTestService(t)
+	exempt := true
+
+	tests := []struct {
+		name     string
+		req      InvoiceCreateRequest
+		contains []string
+	}{
+		{
+			name: "MAD avec remises",
+			req: InvoiceCreateRequest{
+				Date: "03-02-2026", ClientName: "Client MAD", ClientCity: "Rabat", ClientICE: "002233445000011", PaymentMethod: "VIREMENT",
+				DiscountType: "PERCENT", DiscountValue: 5,
+				Items: []InvoiceItemRequest{
+					{ProductID: productID, Description: "Peinture", Quantity: 7, PrixUnitTTC: money.FromFloat(19.99), DiscountType: "PERCENT", DiscountValue: 10},
+					{ProductID: productID, Description: "Peinture <blanche> & co", Quantity: 3, PrixUnitTTC: money.FromFloat(33.33), DiscountType: "AMOUNT", DiscountValue: 5},
+				},
+			},
+			contains: []string{"<cbc:DocumentCurrencyCode>MAD</cbc:DocumentCurrencyCode>", "<cbc:AllowanceTotalAmount", "<cbc:ChargeIndicator>false</cbc:ChargeIndicator>"},
+		},
+		{
+			name: "devise étrangère",
+			req: InvoiceCreateRequest{
+				Date: "03-02-2026", ClientName: "Client EUR", ClientCity: "Paris", ClientICE: "002233445000012", PaymentMethod: "VIREMENT",
+				Currency: currency.EUR, ExchangeRate: 10.85,
+				Items: []InvoiceItemRequest{{ProductID: productID, Description: "Peinture", Quantity: 4, PrixUnitTTC: money.FromFloat(12.5)}},
+			},
+			contains: []string{"<cbc:TaxCurrencyCode>MAD</cbc:TaxCurrencyCode>", "<cbc:CalculationRate>10.85</cbc:CalculationRate>", `<cbc:TaxAmount currencyID="MAD">`},
+		},
+		{
+			name: "exonérée",
+			req: InvoiceCreateRequest{
+				Date: "03-02-2026", ClientName: "Client export", ClientCity: "Dakar", ClientICE: "002233445000013", PaymentMethod: "VIREMENT",
+				TVAExempt: &exempt, ExemptionReason: "Exonération de TVA - exportation (article 92-I-1° du CGI)",
+				Items: []InvoiceItemRequest{{ProductID: productID, Description: "Peinture", Quantity: 2, PrixUnitTTC: money.FromFloat(100)}},
+			},
+			contains: []string{"<cbc:ID>E</cbc:ID>", "<cbc:TaxExemptionReason>Exonération de TVA - exportation (article 92-I-1° du CGI)</cbc:TaxExemptionReason>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildTestUBL(t, s, tt.req)
+			if err := ValidateUBL([]byte(data)); err != nil {
+				t.Fatalf("document rejeté: %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(data, want) {
+					t.Errorf("élément attendu absent: %s", want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUBLRejects(t *testing.T) {
+	s, productID := newUBLTestService(t)
+	valid := buildTestUBL(t, s, InvoiceCreateRequest{
+		Date: "03-02-2026", ClientName: "Client MAD", ClientCity: "Rabat", ClientICE: "002233445000011", PaymentMethod: "VIREMENT",
+		Items: []InvoiceItemRequest{{ProductID: productID, Description: "Peinture", Quantity: 2, PrixUnitTTC: money.FromFloat(120)}},
+	})
+
+	// moveAfter cuts the first occurrence of element and pastes it after anchor
+	moveAfter := func(doc, element, anchor string) string {
+		start := strings.Index(doc, "<cbc:"+element+">")
+		end := strings.Index(doc, "</cbc:"+element+">") + len("</cbc:"+element+">")
+		cut := doc[start:end]
+		doc = doc[:start] + doc[end:]
+		at := strings.Index(doc, anchor) + len(anchor)
+		return doc[:at] + cut + doc[at:]
+	}
+
+	tests := []struct {
+		name  string
+		doc   string
+		error string
+	}{
+		{
+			name:  "ICE invalide",
+			doc:   strings.ReplaceAll(valid, "002233445000011", "00223344"),
+			error: "ICE",
+		},
+		{
+			name:  "ordre des éléments",
+			doc:   moveAfter(valid, "IssueDate", "</cbc:DocumentCurrencyCode>"),
+			error: "mal placé",
+		},
+		{
+			name:  "totaux déséquilibrés",
+			doc:   strings.Replace(valid, `<cbc:PayableAmount currencyID="MAD">240.00</cbc:PayableAmount>`, `<cbc:PayableAmount currencyID="MAD">250.00</cbc:PayableAmount>`, 1),
+			error: "PayableAmount",
+		},
+		{
+			name:  "élément obligatoire manquant",
+			doc:   strings.Replace(valid, "<cbc:IssueDate>2026-02-03</cbc:IssueDate>", "", 1),
+			error: "IssueDate",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.doc == valid {
+				t.Fatal("le document n'a pas été modifié")
+			}
+			err := ValidateUBL([]byte(tt.doc))
+			if err == nil {
+				t.Fatal("document accepté")
+			}
+			if !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("erreur %q ne mentionne pas %s", err, tt.error)
+			}
+		})
+	}
+}
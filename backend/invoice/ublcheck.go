@@ -0,0 +1,396 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"factureapp/backend/money"
+)
+
+// ublRootOrder is the sequence of the UBL 2.1 Invoice elements this exporter may produce
+var ublRootOrder = []string{
+	"UBLVersionID", "ID", "IssueDate", "DueDate", "InvoiceTypeCode", "Note", "DocumentCurrencyCode", "TaxCurrencyCode",
+	"AccountingSupplierParty", "AccountingCustomerParty", "PaymentMeans", "AllowanceCharge", "TaxExchangeRate",
+	"TaxTotal", "LegalMonetaryTotal", "InvoiceLine",
+}
+
+// ublTaxCategories are the UNCL5305 tax category codes accepted on an invoice
+var ublTaxCategories = map[string]bool{"S": true, "Z": true, "E": true, "AE": true, "K": true, "G": true, "O": true}
+
+var (
+	ublCurrencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	ublICEPattern      = regexp.MustCompile(`^[0-9]{15}$`)
+	ublAmountPattern   = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,2})?$`)
+)
+
+// xmlNode is a parsed XML element, used to check documents without binding them to structs
+type xmlNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Text     string
+	Children []*xmlNode
+}
+
+// child returns the first child element with this local name
+func (n *xmlNode) child(local string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// all returns the child elements with this local name
+func (n *xmlNode) all(local string) []*xmlNode {
+	var nodes []*xmlNode
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// path follows a "/"-separated list of local names
+func (n *xmlNode) path(path string) *xmlNode {
+	for _, local := range strings.Split(path, "/") {
+		n = n.child(local)
+	}
+	return n
+}
+
+// attr returns the value of an attribute
+func (n *xmlNode) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// parseXMLTree parses a document into its root element
+func parseXMLTree(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*xmlNode
+	var root *xmlNode
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if root != nil && len(stack) == 0 && err == io.EOF {
+				return root, nil
+			}
+			return nil, fmt.Errorf("XML mal formé: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{Name: t.Name, Attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else if root == nil {
+				root = node
+			} else {
+				return nil, fmt.Errorf("XML mal formé: plusieurs éléments racine")
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(t)
+			}
+		}
+	}
+}
+
+// ublChecker collects the problems found in a UBL document
+type ublChecker struct {
+	currency string
+	problems []string
+}
+
+func (c *ublChecker) fail(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// text returns the trimmed text of a required element, reporting it when missing or empty
+func (c *ublChecker) text(n *xmlNode, path string) string {
+	node := n.path(path)
+	if node == nil || strings.TrimSpace(node.Text) == "" {
+		c.fail("élément obligatoire manquant: %s", path)
+		return ""
+	}
+	return strings.TrimSpace(node.Text)
+}
+
+// date checks a required YYYY-MM-DD date
+func (c *ublChecker) date(n *xmlNode, path string) {
+	if value := c.text(n, path); value != "" {
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			c.fail("%s: date invalide %q (AAAA-MM-JJ attendu)", path, value)
+		}
+	}
+}
+
+// amount reads a required amount and checks its currency
+func (c *ublChecker) amount(n *xmlNode, path string, currencyCode string) money.Amount {
+	value := c.text(n, path)
+	if value == "" {
+		return 0
+	}
+	if id := n.path(path).attr("currencyID"); id != currencyCode {
+		c.fail("%s: devise %q au lieu de %q", path, id, currencyCode)
+	}
+	amount, err := money.Parse(value)
+	if err != nil || !ublAmountPattern.MatchString(value) {
+		c.fail("%s: montant invalide %q", path, value)
+		return 0
+	}
+	return amount
+}
+
+// party checks a supplier or customer party
+func (c *ublChecker) party(n *xmlNode, path string) {
+	party := n.path(path)
+	if party == nil {
+		c.fail("élément obligatoire manquant: %s", path)
+		return
+	}
+	id := party.path("PartyIdentification/ID")
+	if id == nil || id.attr("schemeID") != ublSchemeICE || !ublICEPattern.MatchString(strings.TrimSpace(id.Text)) {
+		c.fail("%s: l'ICE (PartyIdentification/ID, schemeID ICE) doit contenir 15 chiffres", path)
+	}
+	c.text(party, "PartyName/Name")
+	c.text(party, "PostalAddress/Country/IdentificationCode")
+	c.text(party, "PartyTaxScheme/CompanyID")
+	c.text(party, "PartyTaxScheme/TaxScheme/ID")
+	c.text(party, "PartyLegalEntity/RegistrationName")
+}
+
+// taxCategory checks a tax category code, and its exemption reason when exempt
+func (c *ublChecker) taxCategory(category *xmlNode, path string, needsReason bool) {
+	code := c.text(category, "ID")
+	if code != "" && !ublTaxCategories[code] {
+		c.fail("%s: catégorie de TVA inconnue %q", path, code)
+	}
+	if percent := c.text(category, "Percent"); percent != "" {
+		if _, err := strconv.ParseFloat(percent, 64); err != nil {
+			c.fail("%s: taux de TVA invalide %q", path, percent)
+		}
+	}
+	if needsReason && code == ublTaxExempt && category.child("TaxExemptionReason") == nil {
+		c.fail("%s: le motif d'exonération est obligatoire pour la catégorie E", path)
+	}
+	if c.text(category, "TaxScheme/ID") != "" && category.path("TaxScheme/ID").Text != ublTaxSchemeVAT {
+		c.fail("%s: régime de taxe %q au lieu de VAT", path, category.path("TaxScheme/ID").Text)
+	}
+}
+
+// ValidateUBL checks a UBL 2.1 invoice: namespaces and element order, mandatory elements, identifiers,
+// dates, currencies and the consistency of the totals
+func ValidateUBL(data []byte) error {
+	root, err := parseXMLTree(data)
+	if err != nil {
+		return err
+	}
+	if root.Name.Space != ublInvoiceNS || root.Name.Local != "Invoice" {
+		return fmt.Errorf("l'élément racine doit être Invoice dans l'espace de noms %s", ublInvoiceNS)
+	}
+
+	c := &ublChecker{}
+
+	// Aggregates belong to the cac namespace and leaves to cbc; a wrong declaration is reported
+	// once, on the first element that uses it
+	reported := make(map[string]bool)
+	var checkNamespaces func(n *xmlNode, path string)
+	checkNamespaces = func(n *xmlNode, path string) {
+		for _, child := range n.Children {
+			childPath := path + "/" + child.Name.Local
+			expected := ublCBCNS
+			if len(child.Children) > 0 {
+				expected = ublCACNS
+			}
+			if child.Name.Space != expected && !reported[child.Name.Space+expected] {
+				reported[child.Name.Space+expected] = true
+				c.fail("%s: espace de noms %q au lieu de %q", childPath, child.Name.Space, expected)
+			}
+			checkNamespaces(child, childPath)
+		}
+	}
+	checkNamespaces(root, "Invoice")
+
+	position := 0
+	for _, child := range root.Children {
+		found := false
+		for i := position; i < len(ublRootOrder); i++ {
+			if ublRootOrder[i] == child.Name.Local {
+				position, found = i, true
+				break
+			}
+		}
+		if !found {
+			c.fail("élément %s inattendu ou mal placé", child.Name.Local)
+		}
+	}
+
+	// Header
+	if version := c.text(root, "UBLVersionID"); version != "" && version != "2.1" {
+		c.fail("UBLVersionID: version %q au lieu de 2.1", version)
+	}
+	c.text(root, "ID")
+	c.date(root, "IssueDate")
+	if root.child("DueDate") != nil {
+		c.date(root, "DueDate")
+	}
+	c.text(root, "InvoiceTypeCode")
+	c.currency = c.text(root, "DocumentCurrencyCode")
+	if c.currency != "" && !ublCurrencyPattern.MatchString(c.currency) {
+		c.fail("DocumentCurrencyCode: code devise invalide %q", c.currency)
+	}
+	taxCurrency := ""
+	if node := root.child("TaxCurrencyCode"); node != nil {
+		taxCurrency = strings.TrimSpace(node.Text)
+		if !ublCurrencyPattern.MatchString(taxCurrency) {
+			c.fail("TaxCurrencyCode: code devise invalide %q", taxCurrency)
+		}
+	}
+
+	c.party(root, "AccountingSupplierParty/Party")
+	c.party(root, "AccountingCustomerParty/Party")
+
+	if means := root.child("PaymentMeans"); means != nil {
+		c.text(means, "PaymentMeansCode")
+	}
+
+	// Document-level allowances
+	var allowances money.Amount
+	for _, allowance := range root.all("AllowanceCharge") {
+		if c.text(allowance, "ChargeIndicator") != "false" {
+			c.fail("AllowanceCharge: seules les remises (ChargeIndicator false) sont prises en charge")
+		}
+		allowances += c.amount(allowance, "Amount", c.currency)
+		if category := allowance.child("TaxCategory"); category != nil {
+			c.taxCategory(category, "AllowanceCharge/TaxCategory", false)
+		}
+	}
+
+	// TVA: one total in the document currency, and one in the tax currency when it differs
+	var taxAmount money.Amount
+	taxTotals := root.all("TaxTotal")
+	if len(taxTotals) == 0 {
+		c.fail("élément obligatoire manquant: TaxTotal")
+	}
+	for _, total := range taxTotals {
+		currencyCode := ""
+		if node := total.child("TaxAmount"); node != nil {
+			currencyCode = node.attr("currencyID")
+		}
+		if currencyCode != c.currency {
+			if taxCurrency == "" || currencyCode != taxCurrency {
+				c.fail("TaxTotal: devise %q inattendue", currencyCode)
+			}
+			c.amount(total, "TaxAmount", currencyCode)
+			continue
+		}
+		taxAmount = c.amount(total, "TaxAmount", c.currency)
+		subtotals := total.all("TaxSubtotal")
+		if len(subtotals) == 0 {
+			c.fail("TaxTotal: au moins un TaxSubtotal est obligatoire")
+		}
+		var sum money.Amount
+		for _, subtotal := range subtotals {
+			c.amount(subtotal, "TaxableAmount", c.currency)
+			sum += c.amount(subtotal, "TaxAmount", c.currency)
+			if category := subtotal.child("TaxCategory"); category != nil {
+				c.taxCategory(category, "TaxSubtotal/TaxCategory", true)
+			} else {
+				c.fail("élément obligatoire manquant: TaxSubtotal/TaxCategory")
+			}
+		}
+		if sum != taxAmount {
+			c.fail("TaxTotal: la somme des TaxSubtotal (%s) diffère de TaxAmount (%s)", sum, taxAmount)
+		}
+	}
+
+	// Lines
+	lines := root.all("InvoiceLine")
+	if len(lines) == 0 {
+		c.fail("la facture doit contenir au moins une ligne (InvoiceLine)")
+	}
+	var linesTotal money.Amount
+	ids := make(map[string]bool)
+	for i, line := range lines {
+		path := fmt.Sprintf("InvoiceLine[%d]", i+1)
+		id := c.text(line, "ID")
+		if ids[id] {
+			c.fail("%s: identifiant de ligne %q en double", path, id)
+		}
+		ids[id] = true
+
+		if quantity := line.child("InvoicedQuantity"); quantity == nil || quantity.attr("unitCode") == "" {
+			c.fail("%s: InvoicedQuantity et son unitCode sont obligatoires", path)
+		} else if q, err := strconv.ParseFloat(strings.TrimSpace(quantity.Text), 64); err != nil || q <= 0 {
+			c.fail("%s: quantité invalide %q", path, quantity.Text)
+		}
+		linesTotal += c.amount(line, "LineExtensionAmount", c.currency)
+		for _, allowance := range line.all("AllowanceCharge") {
+			c.amount(allowance, "Amount", c.currency)
+		}
+		c.text(line, "Item/Name")
+		if category := line.path("Item/ClassifiedTaxCategory"); category != nil {
+			c.taxCategory(category, path+"/Item/ClassifiedTaxCategory", false)
+		} else {
+			c.fail("élément obligatoire manquant: %s/Item/ClassifiedTaxCategory", path)
+		}
+		if price := c.amount(line, "Price/PriceAmount", c.currency); price < 0 {
+			c.fail("%s: le prix unitaire ne peut pas être négatif", path)
+		}
+	}
+
+	// Totals: lines - allowances = taxable total, + TVA = total TTC = payable
+	totals := root.child("LegalMonetaryTotal")
+	if totals == nil {
+		c.fail("élément obligatoire manquant: LegalMonetaryTotal")
+	} else {
+		lineExtension := c.amount(totals, "LineExtensionAmount", c.currency)
+		taxExclusive := c.amount(totals, "TaxExclusiveAmount", c.currency)
+		taxInclusive := c.amount(totals, "TaxInclusiveAmount", c.currency)
+		payable := c.amount(totals, "PayableAmount", c.currency)
+		var allowanceTotal money.Amount
+		if totals.child("AllowanceTotalAmount") != nil {
+			allowanceTotal = c.amount(totals, "AllowanceTotalAmount", c.currency)
+		}
+
+		if lineExtension != linesTotal {
+			c.fail("LineExtensionAmount (%s) diffère de la somme des lignes (%s)", lineExtension, linesTotal)
+		}
+		if allowanceTotal != allowances {
+			c.fail("AllowanceTotalAmount (%s) diffère de la somme des remises (%s)", allowanceTotal, allowances)
+		}
+		if taxExclusive != lineExtension-allowanceTotal {
+			c.fail("TaxExclusiveAmount (%s) diffère de LineExtensionAmount - AllowanceTotalAmount (%s)", taxExclusive, lineExtension-allowanceTotal)
+		}
+		if taxInclusive != taxExclusive+taxAmount {
+			c.fail("TaxInclusiveAmount (%s) diffère de TaxExclusiveAmount + TVA (%s)", taxInclusive, taxExclusive+taxAmount)
+		}
+		if payable != taxInclusive {
+			c.fail("PayableAmount (%s) diffère de TaxInclusiveAmount (%s)", payable, taxInclusive)
+		}
+	}
+
+	if len(c.problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(c.problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package invoice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"factureapp/backend/database"
+)
+
+// qrPrefix identifies the QR codes printed by the application, with the payload format version
+const qrPrefix = "FACTUREAPP1"
+
+// ContentHash returns the SHA-256 of the canonical content of an invoice: number, date, client,
+// pricing, lines, totals and payment terms. The due date, payment and archive fields are left
+// out, so recording a payment does not change the hash of an invoice already printed.
+func ContentHash(inv *InvoiceResponse) string {
+	var b strings.Builder
+	field := func(key string, value interface{}) {
+		fmt.Fprintf(&b, "%s=%v\n", key, value)
+	}
+	decimal := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	field("numero", inv.FormattedID)
+	field("numero_personnalise", inv.CustomFormattedID)
+	field("date", inv.Date)
+	field("client", inv.ClientName)
+	field("ville", inv.ClientCity)
+	field("ice", inv.ClientICE)
+	field("base", inv.PricingBasis)
+	field("devise", inv.Currency)
+	field("taux_change", decimal(inv.ExchangeRate))
+	field("exonere", inv.TVAExempt)
+	field("motif_exoneration", inv.ExemptionReason)
+	for i, item := range inv.Items {
+		prefix := fmt.Sprintf("ligne%d.", i+1)
+		field(prefix+"produit", item.ProductID)
+		field(prefix+"designation", item.Description)
+		field(prefix+"quantite", decimal(item.Quantity))
+		field(prefix+"prix_ht", item.PrixUnitHT)
+		field(prefix+"prix_ttc", item.PrixUnitTTC)
+		field(prefix+"remise", item.DiscountType+" "+decimal(item.DiscountValue))
+		field(prefix+"montant_remise", item.DiscountAmount)
+		field(prefix+"total_ht", item.TotalHT)
+		field(prefix+"total_ttc", item.TotalTTC)
+	}
+	field("sous_total_ht", inv.SubtotalHT)
+	field("sous_total_ttc", inv.SubtotalTTC)
+	field("remise", inv.DiscountType+" "+decimal(inv.DiscountValue))
+	field("montant_remise", inv.DiscountAmount)
+	field("total_ht", inv.TotalHT)
+	field("total_tva", inv.TotalTVA)
+	field("total_ttc", inv.TotalTTC)
+	field("paiement", inv.PaymentMethod)
+	if inv.ChequeInfo != nil {
+		field("cheque", strings.Join([]string{inv.ChequeInfo.Number, inv.ChequeInfo.Bank, inv.ChequeInfo.City, inv.ChequeInfo.Reference}, "/"))
+	}
+	if inv.EffetInfo != nil {
+		field("effet", strings.Join([]string{inv.EffetInfo.City, inv.EffetInfo.DateEcheance, inv.EffetInfo.Bank, inv.EffetInfo.Reference}, "/"))
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// QRPayload returns the text encoded in the QR code of a printed invoice:
+// prefix|seller ICE|number|date|TTC|currency|content hash
+func (s *Service) QRPayload(inv *InvoiceResponse) string {
+	return strings.Join([]string{
+		qrPrefix,
+		s.settingsService.GetCompany(CompanyICE).ICE,
+		displayNumber(inv),
+		inv.Date,
+		inv.TotalTTC.String(),
+		inv.Currency,
+		ContentHash(inv),
+	}, "|")
+}
+
+// displayNumber returns the number printed on an invoice, the custom one when set
+func displayNumber(inv *InvoiceResponse) string {
+	if inv.CustomFormattedID != "" {
+		return inv.CustomFormattedID
+	}
+	return inv.FormattedID
+}
+
+// VerifyQRPayload checks a scanned invoice QR code against the database: the invoice must exist
+// under the printed number and its current content must still hash to the printed hash
+func (s *Service) VerifyQRPayload(payload string) (*QRVerification, error) {
+	fields := strings.Split(strings.TrimSpace(payload), "|")
+	if len(fields) < 7 || fields[0] != qrPrefix {
+		return nil, fmt.Errorf("ce code QR n'est pas un code de facture FactureApp")
+	}
+	// A custom number may itself contain the separator
+	last := len(fields) - 4
+	sellerICE := fields[1]
+	number := strings.Join(fields[2:last], "|")
+	date, totalTTC, currencyCode, hash := fields[last], fields[last+1], fields[last+2], fields[last+3]
+
+	result := &QRVerification{FormattedID: number}
+	if companyICE := s.settingsService.GetCompany(CompanyICE).ICE; sellerICE != companyICE {
+		result.Status = QROtherSeller
+		result.Message = fmt.Sprintf("facture émise sous l'ICE %s, et non sous celui de la société (%s)", sellerICE, companyICE)
+		return result, nil
+	}
+
+	var inv Invoice
+	err := database.GetDB().Preload("Items").
+		Where("status <> ? AND (custom_formatted_id = ? OR (custom_formatted_id = '' AND formatted_id = ?))", StatusDraft, number, number).
+		First(&inv).Error
+	if err != nil {
+		result.Status = QRUnknown
+		result.Message = fmt.Sprintf("aucune facture n°%s n'existe dans la base", number)
+		return result, nil
+	}
+
+	current := s.toResponse(&inv)
+	result.InvoiceID = current.ID
+	result.ClientName = current.ClientName
+	result.Date = current.Date
+	result.TotalTTC = current.TotalTTC
+	result.Currency = current.Currency
+
+	if date != current.Date {
+		result.Differences = append(result.Differences, fmt.Sprintf("date: %s imprimée, %s enregistrée", date, current.Date))
+	}
+	if totalTTC != current.TotalTTC.String() || currencyCode != current.Currency {
+		result.Differences = append(result.Differences, fmt.Sprintf("total TTC: %s %s imprimé, %s %s enregistré", totalTTC, currencyCode, current.TotalTTC.String(), current.Currency))
+	}
+	if hash != ContentHash(current) {
+		result.Differences = append(result.Differences, "le contenu de la facture (client, lignes ou montants) a changé depuis l'impression")
+	}
+
+	if len(result.Differences) > 0 {
+		result.Status = QRModified
+		result.Message = fmt.Sprintf("la facture n°%s ne correspond plus à la copie imprimée", number)
+		return result, nil
+	}
+	result.Status = QRValid
+	result.Message = fmt.Sprintf("facture n°%s authentique et inchangée", number)
+	if inv.Status == StatusCancelled {
+		result.Message += ", mais annulée par avoir"
+	}
+	return result, nil
+}
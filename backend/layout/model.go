@@ -0,0 +1,50 @@
+package layout
+
+import (
+	"gorm.io/gorm"
+)
+
+// Item table columns
+const (
+	ColumnDescription = "DESCRIPTION"
+	ColumnQuantity    = "QUANTITY"
+	ColumnUnitPrice   = "UNIT_PRICE"
+	ColumnDiscount    = "DISCOUNT" // Only printed when a line carries a discount
+	ColumnTotal       = "TOTAL"
+)
+
+// Column is an item table column and its width on the 12-unit page grid
+type Column struct {
+	Key   string `json:"key"`
+	Width int    `json:"width"`
+}
+
+// Template is a stored invoice layout: paper type, margins, letterhead, table columns, font and colours.
+// Pre-printed templates leave the letterhead blank (no logo, header or footer block) for stationery.
+type Template struct {
+	gorm.Model
+	Name       string `gorm:"uniqueIndex" json:"name"`
+	IsDefault  bool   `json:"isDefault"`  // Used when no template is chosen
+	PrePrinted bool   `json:"prePrinted"` // Letterhead already printed on the paper
+
+	// Margins in mm
+	TopMargin    float64 `json:"topMargin"`
+	BottomMargin float64 `json:"bottomMargin"`
+	LeftMargin   float64 `json:"leftMargin"`
+	RightMargin  float64 `json:"rightMargin"`
+
+	// Letterhead, printed on plain paper only
+	LogoPath    string `json:"logoPath"`    // PNG or JPG copied into the app data folder
+	HeaderText  string `json:"headerText"`  // Company block, one line per line of text
+	FooterText  string `json:"footerText"`  // Legal mentions (RC, IF, CNSS, RIB...)
+	ShowCompany bool   `json:"showCompany"` // Print the company ICE footer
+
+	// Item table
+	Columns []Column `gorm:"serializer:json" json:"columns"`
+
+	// Typography and colours (#RRGGBB)
+	FontFamily    string `json:"fontFamily"` // helvetica, arial or courier; Arabic text always uses the embedded font
+	PrimaryColor  string `json:"primaryColor"`
+	HeaderBgColor string `json:"headerBgColor"`
+	LineColor     string `json:"lineColor"`
+}
@@ -0,0 +1,346 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"factureapp/backend/database"
+
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontfamily"
+	"gorm.io/gorm"
+)
+
+// DefaultColumns is the standard item table: description, quantity, unit price, discount and line total
+var DefaultColumns = []Column{
+	{Key: ColumnDescription, Width: 4},
+	{Key: ColumnQuantity, Width: 1},
+	{Key: ColumnUnitPrice, Width: 2},
+	{Key: ColumnDiscount, Width: 3},
+	{Key: ColumnTotal, Width: 2},
+}
+
+// fontFamilies lists the built-in PDF fonts a template can use
+var fontFamilies = map[string]bool{
+	fontfamily.Helvetica: true,
+	fontfamily.Arial:     true,
+	fontfamily.Courier:   true,
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Service handles invoice templates
+type Service struct{}
+
+// NewService creates a new template service
+func NewService() *Service {
+	return &Service{}
+}
+
+// Migrate runs database migrations for templates and seeds the pre-printed and plain paper layouts
+func (s *Service) Migrate() error {
+	db := database.GetDB()
+	if err := db.AutoMigrate(&Template{}); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(&Template{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	// The pre-printed layout reproduces the historical invoice: 40mm blank for the letterhead
+	defaults := []Template{
+		{
+			Name:          "Papier pré-imprimé",
+			IsDefault:     true,
+			PrePrinted:    true,
+			TopMargin:     40,
+			BottomMargin:  20,
+			LeftMargin:    15,
+			RightMargin:   15,
+			ShowCompany:   true,
+			Columns:       DefaultColumns,
+			FontFamily:    fontfamily.Arial,
+			PrimaryColor:  "#000000",
+			HeaderBgColor: "#E6E6E6",
+			LineColor:     "#808080",
+		},
+		{
+			Name:          "Papier blanc",
+			TopMargin:     15,
+			BottomMargin:  20,
+			LeftMargin:    15,
+			RightMargin:   15,
+			HeaderText:    "Ma Société SARL\nAdresse\nTél. :",
+			ShowCompany:   true,
+			Columns:       DefaultColumns,
+			FontFamily:    fontfamily.Arial,
+			PrimaryColor:  "#1F3A5F",
+			HeaderBgColor: "#DCE6F2",
+			LineColor:     "#7F8FA6",
+		},
+	}
+	return db.Create(&defaults).Error
+}
+
+// GetAllTemplates returns every template, the default first
+func (s *Service) GetAllTemplates() ([]Template, error) {
+	db := database.GetDB()
+	var templates []Template
+	if err := db.Order("is_default DESC, name ASC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// GetTemplate returns a template; id 0 returns the default template
+func (s *Service) GetTemplate(id uint) (*Template, error) {
+	db := database.GetDB()
+	var templates []Template
+	query := db.Limit(1)
+	if id == 0 {
+		query = query.Order("is_default DESC, id ASC")
+	} else {
+		query = query.Where("id = ?", id)
+	}
+	if err := query.Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture du modèle: %w", err)
+	}
+	if len(templates) == 0 {
+		if id == 0 {
+			return nil, fmt.Errorf("aucun modèle de facture n'est défini")
+		}
+		return nil, fmt.Errorf("modèle de facture introuvable")
+	}
+	return &templates[0], nil
+}
+
+// CreateTemplate validates and saves a new template
+func (s *Service) CreateTemplate(tpl Template) (*Template, error) {
+	if err := Normalize(&tpl); err != nil {
+		return nil, err
+	}
+	tpl.ID = 0
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if tpl.IsDefault {
+			if err := tx.Model(&Template{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&tpl).Error
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("un modèle nommé '%s' existe déjà", tpl.Name)
+		}
+		return nil, fmt.Errorf("échec de la création du modèle: %w", err)
+	}
+	return &tpl, nil
+}
+
+// UpdateTemplate validates and saves an existing template; the logo is managed by SetTemplateLogo
+func (s *Service) UpdateTemplate(tpl Template) error {
+	if err := Normalize(&tpl); err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	existing, err := s.GetTemplate(tpl.ID)
+	if err != nil || tpl.ID == 0 {
+		return fmt.Errorf("modèle de facture introuvable")
+	}
+	tpl.CreatedAt = existing.CreatedAt
+	tpl.LogoPath = existing.LogoPath
+
+	// The default template can only change by promoting another one
+	if existing.IsDefault {
+		tpl.IsDefault = true
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if tpl.IsDefault {
+			if err := tx.Model(&Template{}).Where("is_default = ? AND id <> ?", true, tpl.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&tpl).Error
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("un modèle nommé '%s' existe déjà", tpl.Name)
+		}
+		return fmt.Errorf("échec de la mise à jour du modèle: %w", err)
+	}
+	return nil
+}
+
+// DeleteTemplate deletes a template other than the default one
+func (s *Service) DeleteTemplate(id uint) error {
+	tpl, err := s.GetTemplate(id)
+	if err != nil {
+		return err
+	}
+	if tpl.IsDefault {
+		return fmt.Errorf("impossible de supprimer le modèle par défaut: choisissez d'abord un autre modèle par défaut")
+	}
+
+	db := database.GetDB()
+	if err := db.Delete(&Template{}, id).Error; err != nil {
+		return fmt.Errorf("échec de la suppression du modèle: %w", err)
+	}
+	if tpl.LogoPath != "" {
+		os.Remove(tpl.LogoPath)
+	}
+	return nil
+}
+
+// SetDefaultTemplate makes a template the one used when none is chosen
+func (s *Service) SetDefaultTemplate(id uint) error {
+	if _, err := s.GetTemplate(id); err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Template{}).Where("id <> ?", id).Update("is_default", false).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour du modèle par défaut: %w", err)
+		}
+		if err := tx.Model(&Template{}).Where("id = ?", id).Update("is_default", true).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour du modèle par défaut: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetTemplateLogo copies a PNG or JPG logo into the app data folder and attaches it to the template.
+// An empty source path removes the logo.
+func (s *Service) SetTemplateLogo(id uint, sourcePath string) (string, error) {
+	tpl, err := s.GetTemplate(id)
+	if err != nil || id == 0 {
+		return "", fmt.Errorf("modèle de facture introuvable")
+	}
+
+	db := database.GetDB()
+	if sourcePath == "" {
+		if tpl.LogoPath != "" {
+			os.Remove(tpl.LogoPath)
+		}
+		if err := db.Model(tpl).Update("logo_path", "").Error; err != nil {
+			return "", fmt.Errorf("échec de la mise à jour du modèle: %w", err)
+		}
+		return "", nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(sourcePath))
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return "", fmt.Errorf("format de logo non supporté '%s' (PNG ou JPG attendu)", ext)
+	}
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("le logo n'existe pas: %s", sourcePath)
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	logoDir := filepath.Join(configDir, "FactureApp", "images")
+	if err := os.MkdirAll(logoDir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier des images (%s): vérifiez les permissions ou l'espace disque", logoDir)
+	}
+
+	destPath := filepath.Join(logoDir, fmt.Sprintf("template_%d%s", tpl.ID, ext))
+	if err := os.WriteFile(destPath, data, 0644); err != nil {
+		return "", fmt.Errorf("impossible de copier le logo (%s): vérifiez les permissions et l'espace disque disponible", destPath)
+	}
+	if tpl.LogoPath != "" && tpl.LogoPath != destPath {
+		os.Remove(tpl.LogoPath)
+	}
+
+	if err := db.Model(tpl).Update("logo_path", destPath).Error; err != nil {
+		return "", fmt.Errorf("échec de la mise à jour du modèle: %w", err)
+	}
+	return destPath, nil
+}
+
+// Normalize fills the defaults of a template and validates it
+func Normalize(tpl *Template) error {
+	tpl.Name = strings.TrimSpace(tpl.Name)
+	if tpl.Name == "" {
+		return fmt.Errorf("le nom du modèle est obligatoire")
+	}
+
+	for _, margin := range []float64{tpl.TopMargin, tpl.BottomMargin, tpl.LeftMargin, tpl.RightMargin} {
+		if margin < 0 || margin > 100 {
+			return fmt.Errorf("les marges doivent être comprises entre 0 et 100 mm")
+		}
+	}
+
+	tpl.FontFamily = strings.ToLower(strings.TrimSpace(tpl.FontFamily))
+	if tpl.FontFamily == "" {
+		tpl.FontFamily = fontfamily.Arial
+	}
+	if !fontFamilies[tpl.FontFamily] {
+		return fmt.Errorf("police non prise en charge: %s (helvetica, arial ou courier attendu)", tpl.FontFamily)
+	}
+
+	colors := []struct {
+		value    *string
+		fallback string
+	}{
+		{&tpl.PrimaryColor, "#000000"},
+		{&tpl.HeaderBgColor, "#E6E6E6"},
+		{&tpl.LineColor, "#808080"},
+	}
+	for _, c := range colors {
+		*c.value = strings.TrimSpace(*c.value)
+		if *c.value == "" {
+			*c.value = c.fallback
+		}
+		if !hexColor.MatchString(*c.value) {
+			return fmt.Errorf("couleur invalide: %s (format #RRGGBB attendu)", *c.value)
+		}
+	}
+
+	if len(tpl.Columns) == 0 {
+		tpl.Columns = DefaultColumns
+	}
+	return validateColumns(tpl.Columns)
+}
+
+// validateColumns checks that the column set is known, without duplicates, includes the description
+// and the line total, and fills the 12-unit page width
+func validateColumns(columns []Column) error {
+	seen := make(map[string]bool)
+	width := 0
+	for _, c := range columns {
+		switch c.Key {
+		case ColumnDescription, ColumnQuantity, ColumnUnitPrice, ColumnDiscount, ColumnTotal:
+		default:
+			return fmt.Errorf("colonne inconnue: %s", c.Key)
+		}
+		if seen[c.Key] {
+			return fmt.Errorf("la colonne %s est en double", c.Key)
+		}
+		if c.Width < 1 {
+			return fmt.Errorf("la largeur de la colonne %s doit être d'au moins 1", c.Key)
+		}
+		seen[c.Key] = true
+		width += c.Width
+	}
+	if !seen[ColumnDescription] || !seen[ColumnTotal] {
+		return fmt.Errorf("les colonnes description et total sont obligatoires")
+	}
+	if width != 12 {
+		return fmt.Errorf("la somme des largeurs de colonnes doit être égale à 12 (actuellement %d)", width)
+	}
+	return nil
+}
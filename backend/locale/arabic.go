@@ -0,0 +1,252 @@
+package locale
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PDF fonts have no shaping engine: Arabic letters must be replaced by their contextual
+// presentation forms and written in visual (left-to-right) order before being drawn.
+
+// arabicForms holds the isolated, final, initial and medial presentation forms of each letter.
+// Letters without initial/medial forms only join to the preceding letter.
+var arabicForms = map[rune][4]rune{
+	0x0621: {0xFE80, 0, 0, 0},                // ء
+	0x0622: {0xFE81, 0xFE82, 0, 0},           // آ
+	0x0623: {0xFE83, 0xFE84, 0, 0},           // أ
+	0x0624: {0xFE85, 0xFE86, 0, 0},           // ؤ
+	0x0625: {0xFE87, 0xFE88, 0, 0},           // إ
+	0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C}, // ئ
+	0x0627: {0xFE8D, 0xFE8E, 0, 0},           // ا
+	0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92}, // ب
+	0x0629: {0xFE93, 0xFE94, 0, 0},           // ة
+	0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98}, // ت
+	0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C}, // ث
+	0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0}, // ج
+	0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4}, // ح
+	0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8}, // خ
+	0x062F: {0xFEA9, 0xFEAA, 0, 0},           // د
+	0x0630: {0xFEAB, 0xFEAC, 0, 0},           // ذ
+	0x0631: {0xFEAD, 0xFEAE, 0, 0},           // ر
+	0x0632: {0xFEAF, 0xFEB0, 0, 0},           // ز
+	0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4}, // س
+	0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8}, // ش
+	0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC}, // ص
+	0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0}, // ض
+	0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4}, // ط
+	0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8}, // ظ
+	0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC}, // ع
+	0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0}, // غ
+	0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4}, // ف
+	0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8}, // ق
+	0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC}, // ك
+	0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0}, // ل
+	0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4}, // م
+	0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8}, // ن
+	0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC}, // ه
+	0x0648: {0xFEED, 0xFEEE, 0, 0},           // و
+	0x0649: {0xFEEF, 0xFEF0, 0, 0},           // ى
+	0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4}, // ي
+}
+
+// lamAlef maps the alef variants to the isolated form of their lam-alef ligature (final form is +1)
+var lamAlef = map[rune]rune{
+	0x0622: 0xFEF5, // لآ
+	0x0623: 0xFEF7, // لأ
+	0x0625: 0xFEF9, // لإ
+	0x0627: 0xFEFB, // لا
+}
+
+const (
+	tatweel = 0x0640
+	lam     = 0x0644
+)
+
+// isHaraka reports whether r is a diacritic, which is transparent for joining
+func isHaraka(r rune) bool {
+	return r >= 0x064B && r <= 0x0652
+}
+
+// joinsBefore reports whether a letter can connect to the following letter
+func joinsBefore(r rune) bool {
+	if r == tatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[2] != 0
+}
+
+// joinsAfter reports whether a letter can connect to the preceding letter
+func joinsAfter(r rune) bool {
+	if r == tatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[1] != 0
+}
+
+// Shape replaces Arabic letters by their contextual presentation forms, in logical order
+func Shape(s string) string {
+	runes := []rune(s)
+	var out []rune
+
+	// neighbour returns the closest non-diacritic rune in the given direction, or 0
+	neighbour := func(i, step int) rune {
+		for j := i + step; j >= 0 && j < len(runes); j += step {
+			if !isHaraka(runes[j]) {
+				return runes[j]
+			}
+		}
+		return 0
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+
+		joinPrev := joinsBefore(neighbour(i, -1)) && joinsAfter(r)
+
+		// Lam followed by an alef is written as a single ligature
+		if r == lam {
+			if ligature, ok := lamAlef[neighbour(i, 1)]; ok {
+				if joinPrev {
+					ligature++
+				}
+				out = append(out, ligature)
+				for i+1 < len(runes) && isHaraka(runes[i+1]) {
+					i++
+					out = append(out, runes[i])
+				}
+				i++ // skip the alef
+				continue
+			}
+		}
+
+		joinNext := joinsBefore(r) && joinsAfter(neighbour(i, 1))
+
+		switch {
+		case joinPrev && joinNext:
+			out = append(out, forms[3])
+		case joinPrev:
+			out = append(out, forms[1])
+		case joinNext:
+			out = append(out, forms[2])
+		default:
+			out = append(out, forms[0])
+		}
+	}
+	return string(out)
+}
+
+// isArabic reports whether r is written right-to-left
+func isArabic(r rune) bool {
+	return (r >= 0x0600 && r <= 0x06FF) || (r >= 0xFB50 && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF)
+}
+
+// ContainsArabic reports whether s contains Arabic script
+func ContainsArabic(s string) bool {
+	return strings.ContainsFunc(s, isArabic)
+}
+
+// isLTR reports whether r is a left-to-right letter or a digit
+func isLTR(r rune) bool {
+	return !isArabic(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// mirrored swaps paired punctuation when written right-to-left
+var mirrored = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '<': '>', '>': '<', '«': '»', '»': '«'}
+
+// Visual shapes the Arabic text of s and reorders it for left-to-right drawing.
+// The paragraph direction follows the first strong character: in a right-to-left paragraph the
+// runs are reversed and Latin words or numbers keep their own order; in a left-to-right paragraph
+// only the Arabic runs are reversed. Strings without Arabic are returned unchanged.
+func Visual(s string) string {
+	if !ContainsArabic(s) {
+		return s
+	}
+	runes := []rune(Shape(s))
+
+	rtl := false
+	for _, r := range runes {
+		if isArabic(r) {
+			rtl = true
+			break
+		}
+		if isLTR(r) {
+			break
+		}
+	}
+
+	// Split into runs: a run of the embedded direction starts and ends with a strong character of it
+	embedded := isArabic
+	if rtl {
+		embedded = isLTR
+	}
+	type run struct {
+		text     []rune
+		embedded bool
+	}
+	var runs []run
+	for i := 0; i < len(runes); {
+		if !embedded(runes[i]) {
+			j := i
+			for j < len(runes) && !embedded(runes[j]) {
+				j++
+			}
+			runs = append(runs, run{text: runes[i:j]})
+			i = j
+			continue
+		}
+		// Extend over neutrals as long as another strong character of the same direction follows
+		end := i + 1
+		for j := i + 1; j < len(runes); j++ {
+			if embedded(runes[j]) {
+				end = j + 1
+			} else if (rtl && isArabic(runes[j])) || (!rtl && isLTR(runes[j])) {
+				break
+			}
+		}
+		// A percent sign stays attached to its number
+		for rtl && end < len(runes) && runes[end] == '%' {
+			end++
+		}
+		runs = append(runs, run{text: runes[i:end], embedded: true})
+		i = end
+	}
+
+	var out []rune
+	if rtl {
+		for k := len(runs) - 1; k >= 0; k-- {
+			if runs[k].embedded {
+				out = append(out, runs[k].text...)
+			} else {
+				out = append(out, reverseRTL(runs[k].text)...)
+			}
+		}
+		return string(out)
+	}
+	for _, r := range runs {
+		if r.embedded {
+			out = append(out, reverseRTL(r.text)...)
+		} else {
+			out = append(out, r.text...)
+		}
+	}
+	return string(out)
+}
+
+// reverseRTL reverses a right-to-left run, mirroring paired punctuation
+func reverseRTL(text []rune) []rune {
+	out := make([]rune, len(text))
+	for i, r := range text {
+		if m, ok := mirrored[r]; ok {
+			r = m
+		}
+		out[len(text)-1-i] = r
+	}
+	return out
+}
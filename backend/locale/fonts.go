@@ -0,0 +1,29 @@
+package locale
+
+import (
+	_ "embed"
+
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/core/entity"
+	"github.com/johnfercher/maroto/v2/pkg/repository"
+)
+
+// FontFamily is the embedded Unicode font used for documents containing Arabic
+const FontFamily = "dejavu"
+
+//go:embed fonts/DejaVuSansCondensed.ttf
+var fontRegular []byte
+
+//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+var fontBold []byte
+
+// Fonts returns the embedded Unicode font for Maroto documents.
+// Only upright faces are embedded: italic styles fall back to them.
+func Fonts() ([]*entity.CustomFont, error) {
+	return repository.New().
+		AddUTF8FontFromBytes(FontFamily, fontstyle.Normal, fontRegular).
+		AddUTF8FontFromBytes(FontFamily, fontstyle.Italic, fontRegular).
+		AddUTF8FontFromBytes(FontFamily, fontstyle.Bold, fontBold).
+		AddUTF8FontFromBytes(FontFamily, fontstyle.BoldItalic, fontBold).
+		Load()
+}
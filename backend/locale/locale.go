@@ -0,0 +1,136 @@
+package locale
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Document languages
+const (
+	French    = "fr"
+	English   = "en"
+	Arabic    = "ar"
+	Bilingual = "fr-ar" // French and Arabic side by side
+)
+
+// Normalize validates a document language; an empty value means French
+func Normalize(lang string) (string, error) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	switch lang {
+	case "":
+		return French, nil
+	case French, English, Arabic, Bilingual:
+		return lang, nil
+	default:
+		return "", fmt.Errorf("langue non prise en charge: %s (fr, en, ar ou fr-ar attendu)", lang)
+	}
+}
+
+// IsRTL reports whether documents in lang are laid out right-to-left
+func IsRTL(lang string) bool {
+	return lang == Arabic
+}
+
+// HasArabic reports whether documents in lang contain Arabic text and need the embedded font
+func HasArabic(lang string) bool {
+	return lang == Arabic || lang == Bilingual
+}
+
+// translation holds the English and Arabic versions of a French label
+type translation struct {
+	en, ar string
+}
+
+// labels are keyed by their French text
+var labels = map[string]translation{
+	"FACTURE N°":        {"INVOICE NO.", "فاتورة رقم"},
+	"BROUILLON":         {"DRAFT", "مسودة"},
+	"Client":            {"Customer", "العميل"},
+	"Date":              {"Date", "التاريخ"},
+	"Ville":             {"City", "المدينة"},
+	"ICE":               {"ICE", "التعريف الموحد"},
+	"DESCRIPTION":       {"DESCRIPTION", "البيان"},
+	"QTÉ":               {"QTY", "الكمية"},
+	"PRIX UNIT. TTC":    {"UNIT PRICE INCL. VAT", "ثمن الوحدة مع الضريبة"},
+	"PU HT":             {"UNIT PRICE EXCL. VAT", "ثمن الوحدة دون الضريبة"},
+	"REMISE":            {"DISCOUNT", "التخفيض"},
+	"TOTAL TTC":         {"TOTAL INCL. VAT", "المجموع مع الضريبة"},
+	"MONTANT HT":        {"AMOUNT EXCL. VAT", "المبلغ دون الضريبة"},
+	"Sous-total TTC":    {"Subtotal incl. VAT", "المجموع الجزئي مع الضريبة"},
+	"Sous-total HT":     {"Subtotal excl. VAT", "المجموع الجزئي دون الضريبة"},
+	"Remise":            {"Discount", "التخفيض"},
+	"Total HT":          {"Total excl. VAT", "المجموع دون الضريبة"},
+	"TVA":               {"VAT", "الضريبة على القيمة المضافة"},
+	"Exonérée":          {"Exempt", "معفاة"},
+	"Taux de change":    {"Exchange rate", "سعر الصرف"},
+	"Contre-valeur TTC": {"Equivalent incl. VAT", "القيمة المقابلة مع الضريبة"},
+	"Mode de paiement":  {"Payment method", "طريقة الأداء"},
+	"Espèce":            {"Cash", "نقدا"},
+	"Chèque":            {"Cheque", "شيك"},
+	"Effet":             {"Bill of exchange", "كمبيالة"},
+	"N° Chèque":         {"Cheque no.", "رقم الشيك"},
+	"Banque":            {"Bank", "البنك"},
+	"Référence":         {"Reference", "المرجع"},
+	"Lot":               {"Batch", "الدفعة"},
+	"Exp.":              {"Exp.", "ينتهي"},
+	"Échéance":          {"Due date", "تاريخ الاستحقاق"},
+	"ICE Société":       {"Company ICE", "التعريف الموحد للشركة"},
+	"Empreinte":         {"Fingerprint", "البصمة"},
+	"Scannez ce code pour vérifier l'authenticité de la facture": {
+		"Scan this code to check that the invoice is authentic",
+		"امسح هذا الرمز للتحقق من صحة الفاتورة",
+	},
+	"Arrêté la présente facture à la somme de": {"This invoice is hereby closed at the sum of", "حصرت هذه الفاتورة في مبلغ"},
+	"Exonération de TVA - exportation (article 92-I-1° du CGI)": {
+		"VAT exemption - export (article 92-I-1° of the CGI)",
+		"إعفاء من الضريبة على القيمة المضافة - تصدير (المادة 92-I-1° من المدونة العامة للضرائب)",
+	},
+}
+
+// Translate returns a French label in a single language (French for the bilingual layout).
+// Unknown labels are returned unchanged.
+func Translate(lang, key string) string {
+	t, ok := labels[key]
+	if !ok {
+		return key
+	}
+	switch lang {
+	case English:
+		return t.en
+	case Arabic:
+		return t.ar
+	default:
+		return key
+	}
+}
+
+// Label returns a French label in lang; the bilingual layout prints "français / عربي"
+func Label(lang, key string) string {
+	if lang == Bilingual {
+		if ar := Translate(Arabic, key); ar != key {
+			return key + " / " + ar
+		}
+	}
+	return Translate(lang, key)
+}
+
+// Wrap splits s into lines of at most width characters, breaking between words in logical order.
+// Right-to-left text must be wrapped before Visual reorders it, which the PDF engine cannot do.
+func Wrap(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Connection security modes
+const (
+	SecuritySTARTTLS = "STARTTLS" // Plain connection upgraded to TLS (port 587)
+	SecurityTLS      = "TLS"      // Implicit TLS (port 465)
+	SecurityNone     = "NONE"     // Unencrypted, for a local SMTP stand-in only
+)
+
+// Email log statuses
+const (
+	StatusSent   = "SENT"
+	StatusFailed = "FAILED"
+)
+
+// SMTPConfig is the single SMTP account used to send invoices.
+// The password is stored encrypted and never returned to the frontend.
+type SMTPConfig struct {
+	ID                uint   `gorm:"primaryKey" json:"id"`
+	Host              string `json:"host"`
+	Port              int    `json:"port"`
+	Security          string `json:"security"` // STARTTLS, TLS or NONE
+	Username          string `json:"username"`
+	EncryptedPassword string `json:"-"`
+	HasPassword       bool   `gorm:"-" json:"hasPassword"`
+	FromAddress       string `json:"fromAddress"`
+	FromName          string `json:"fromName"`
+
+	// Message templates (Go text/template syntax, see TemplateData)
+	SubjectTemplate string `json:"subjectTemplate"`
+	BodyTemplate    string `json:"bodyTemplate"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// SMTPConfigRequest is the DTO for saving the SMTP configuration
+type SMTPConfigRequest struct {
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	Security        string `json:"security"`
+	Username        string `json:"username"`
+	Password        string `json:"password"` // Empty keeps the stored password
+	ClearPassword   bool   `json:"clearPassword"`
+	FromAddress     string `json:"fromAddress"`
+	FromName        string `json:"fromName"`
+	SubjectTemplate string `json:"subjectTemplate"`
+	BodyTemplate    string `json:"bodyTemplate"`
+}
+
+// TemplateData are the fields available in the message templates, e.g. {{.Numero}}
+type TemplateData struct {
+	Numero     string // Invoice number
+	Client     string
+	Date       string // DD-MM-YYYY
+	Montant    string // Total TTC with currency, e.g. "1 234.50 DH"
+	Expediteur string // FromName
+}
+
+// EmailDraft is a pre-filled message for an invoice, to be reviewed before sending
+type EmailDraft struct {
+	InvoiceID uint   `json:"invoiceId"`
+	To        string `json:"to"` // The client's email, when known
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+}
+
+// EmailLog records each attempt to email an invoice
+type EmailLog struct {
+	gorm.Model
+	InvoiceID  uint      `gorm:"index" json:"invoiceId"`
+	To         string    `json:"to"`
+	Subject    string    `json:"subject"`
+	Attachment string    `json:"attachment"` // File names of the attached PDFs
+	Status     string    `json:"status"`     // SENT or FAILED
+	Error      string    `json:"error,omitempty"`
+	SentAt     time.Time `json:"sentAt"`
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The SMTP password is encrypted with AES-256-GCM. The key is generated on first use and kept
+// in a file readable only by the current user, next to (but outside of) the database, so that a
+// copy of the database alone does not reveal the password.
+
+// secretKey returns the local encryption key, creating it if it does not exist yet. An unreadable or
+// damaged key is reported rather than replaced, since a new key would lose the stored password.
+func secretKey() ([]byte, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	keyPath := filepath.Join(configDir, "FactureApp", "secret.key")
+
+	key, err := os.ReadFile(keyPath)
+	switch {
+	case err == nil && len(key) == 32:
+		return key, nil
+	case err == nil:
+		return nil, fmt.Errorf("clé de chiffrement endommagée (%s): restaurez-la ou supprimez-la puis ressaisissez le mot de passe SMTP", keyPath)
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("impossible de lire la clé de chiffrement (%s): %w", keyPath, err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("impossible de générer la clé de chiffrement: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, fmt.Errorf("impossible de créer le dossier de la clé de chiffrement: %w", err)
+	}
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		return nil, fmt.Errorf("impossible d'enregistrer la clé de chiffrement (%s): %w", keyPath, err)
+	}
+	return key, nil
+}
+
+// encrypt seals a secret and returns it base64-encoded, nonce first
+func encrypt(plain string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("échec du chiffrement du mot de passe: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a secret sealed by encrypt
+func decrypt(encoded string) (string, error) {
+	if encoded == "" {
+		return "", nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("mot de passe SMTP illisible: ressaisissez-le")
+	}
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("mot de passe SMTP illisible: ressaisissez-le")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("mot de passe SMTP illisible (clé de chiffrement changée): ressaisissez-le")
+	}
+	return string(plain), nil
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := secretKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("échec de l'initialisation du chiffrement: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,357 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"factureapp/backend/client"
+	"factureapp/backend/currency"
+	"factureapp/backend/database"
+	"factureapp/backend/invoice"
+)
+
+// Default French message templates
+const (
+	DefaultSubjectTemplate = "Facture {{.Numero}}"
+	DefaultBodyTemplate    = `Bonjour,
+
+Veuillez trouver ci-joint la facture {{.Numero}} du {{.Date}} d'un montant de {{.Montant}}.
+
+Nous restons à votre disposition pour toute question.
+
+Cordialement,
+{{.Expediteur}}`
+)
+
+// configID is the primary key of the single SMTP configuration row
+const configID = 1
+
+// Service handles the SMTP configuration and sending invoices by email
+type Service struct {
+	invoiceService *invoice.Service
+}
+
+// NewService creates a new mailer service
+func NewService(invoiceService *invoice.Service) *Service {
+	return &Service{
+		invoiceService: invoiceService,
+	}
+}
+
+// Migrate runs database migrations for the SMTP configuration and the email log
+func (s *Service) Migrate() error {
+	db := database.GetDB()
+	return db.AutoMigrate(&SMTPConfig{}, &EmailLog{})
+}
+
+// GetConfig returns the SMTP configuration, with default templates when none is saved.
+// The password itself is never returned.
+func (s *Service) GetConfig() (*SMTPConfig, error) {
+	db := database.GetDB()
+	var configs []SMTPConfig
+	if err := db.Where("id = ?", configID).Limit(1).Find(&configs).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture de la configuration SMTP: %w", err)
+	}
+
+	cfg := SMTPConfig{ID: configID, Port: 587, Security: SecuritySTARTTLS}
+	if len(configs) > 0 {
+		cfg = configs[0]
+	}
+	if cfg.SubjectTemplate == "" {
+		cfg.SubjectTemplate = DefaultSubjectTemplate
+	}
+	if cfg.BodyTemplate == "" {
+		cfg.BodyTemplate = DefaultBodyTemplate
+	}
+	cfg.HasPassword = cfg.EncryptedPassword != ""
+	return &cfg, nil
+}
+
+// SaveConfig validates and stores the SMTP configuration, encrypting the password
+func (s *Service) SaveConfig(req SMTPConfigRequest) error {
+	cfg, err := s.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.Host = strings.TrimSpace(req.Host)
+	if cfg.Host == "" {
+		return fmt.Errorf("le serveur SMTP est obligatoire")
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		return fmt.Errorf("port SMTP invalide: %d", req.Port)
+	}
+	cfg.Port = req.Port
+
+	cfg.Security = strings.ToUpper(strings.TrimSpace(req.Security))
+	switch cfg.Security {
+	case "":
+		cfg.Security = SecuritySTARTTLS
+	case SecuritySTARTTLS, SecurityTLS, SecurityNone:
+	default:
+		return fmt.Errorf("sécurité SMTP inconnue: %s (STARTTLS, TLS ou NONE attendu)", req.Security)
+	}
+
+	from, err := mail.ParseAddress(strings.TrimSpace(req.FromAddress))
+	if err != nil {
+		return fmt.Errorf("adresse d'expéditeur invalide: %s", req.FromAddress)
+	}
+	cfg.FromAddress = from.Address
+	cfg.FromName = strings.TrimSpace(req.FromName)
+	cfg.Username = strings.TrimSpace(req.Username)
+
+	switch {
+	case req.ClearPassword:
+		cfg.EncryptedPassword = ""
+	case req.Password != "":
+		cfg.EncryptedPassword, err = encrypt(req.Password)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Templates are checked now rather than when the first invoice is sent
+	cfg.SubjectTemplate = strings.TrimSpace(req.SubjectTemplate)
+	cfg.BodyTemplate = strings.TrimSpace(req.BodyTemplate)
+	if cfg.SubjectTemplate == "" {
+		cfg.SubjectTemplate = DefaultSubjectTemplate
+	}
+	if cfg.BodyTemplate == "" {
+		cfg.BodyTemplate = DefaultBodyTemplate
+	}
+	sample := TemplateData{Numero: "0001 - 2026", Client: "Client", Date: "01-01-2026", Montant: "100.00 DH", Expediteur: cfg.FromName}
+	if _, err := render("objet", cfg.SubjectTemplate, sample); err != nil {
+		return err
+	}
+	if _, err := render("message", cfg.BodyTemplate, sample); err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	if err := db.Save(cfg).Error; err != nil {
+		return fmt.Errorf("échec de l'enregistrement de la configuration SMTP: %w", err)
+	}
+	return nil
+}
+
+// PrepareInvoiceEmail pre-fills the message for an invoice from the templates and the client's email
+func (s *Service) PrepareInvoiceEmail(invoiceID uint) (*EmailDraft, error) {
+	cfg, err := s.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	inv, err := s.invoiceService.GetInvoiceByID(invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de récupérer la facture: %w", err)
+	}
+
+	number := inv.FormattedID
+	if inv.CustomFormattedID != "" {
+		number = inv.CustomFormattedID
+	}
+	data := TemplateData{
+		Numero:     number,
+		Client:     inv.ClientName,
+		Date:       inv.Date,
+		Montant:    fmt.Sprintf("%s %s", inv.TotalTTC, currency.Symbol(inv.Currency)),
+		Expediteur: cfg.FromName,
+	}
+
+	draft := &EmailDraft{InvoiceID: invoiceID}
+	if draft.Subject, err = render("objet", cfg.SubjectTemplate, data); err != nil {
+		return nil, err
+	}
+	if draft.Body, err = render("message", cfg.BodyTemplate, data); err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	var clients []client.Client
+	if err := db.Where("ice = ?", inv.ClientICE).Limit(1).Find(&clients).Error; err == nil && len(clients) > 0 {
+		draft.To = clients[0].Email
+	}
+	return draft, nil
+}
+
+// SendInvoiceByEmail generates the invoice PDF, sends it as an attachment and logs the attempt.
+// Several recipients can be given, separated by commas.
+func (s *Service) SendInvoiceByEmail(invoiceID uint, to string, subject string, body string) (*EmailLog, error) {
+	recipients, err := mail.ParseAddressList(strings.TrimSpace(to))
+	if err != nil || len(recipients) == 0 {
+		return nil, fmt.Errorf("adresse email du destinataire invalide: %s", to)
+	}
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return nil, fmt.Errorf("l'objet du message est obligatoire")
+	}
+
+	inv, err := s.invoiceService.GetInvoiceByID(invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de récupérer la facture: %w", err)
+	}
+	if inv.Status == invoice.StatusDraft {
+		return nil, fmt.Errorf("émettez la facture avant de l'envoyer: un brouillon n'a pas de numéro")
+	}
+
+	pdfPath, err := s.invoiceService.GeneratePDF(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.sendAndLog(invoiceID, recipients, subject, body, pdfPath)
+}
+
+// SendReminderByEmail sends the reminder letter of an overdue invoice (level 0: next level) with the invoice
+// attached, and records it in the reminder history once sent. An empty recipient uses the client's email.
+func (s *Service) SendReminderByEmail(invoiceID uint, level int, to string) (*invoice.Reminder, error) {
+	if strings.TrimSpace(to) == "" {
+		draft, err := s.PrepareInvoiceEmail(invoiceID)
+		if err != nil {
+			return nil, err
+		}
+		if draft.To == "" {
+			return nil, fmt.Errorf("le client n'a pas d'adresse email: indiquez un destinataire")
+		}
+		to = draft.To
+	}
+	recipients, err := mail.ParseAddressList(strings.TrimSpace(to))
+	if err != nil || len(recipients) == 0 {
+		return nil, fmt.Errorf("adresse email du destinataire invalide: %s", to)
+	}
+
+	reminder, err := s.invoiceService.PrepareReminder(invoiceID, level)
+	if err != nil {
+		return nil, err
+	}
+	invoicePath, err := s.invoiceService.GeneratePDF(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	body := reminder.Body + "\n\nVeuillez agréer, Madame, Monsieur, l'expression de nos salutations distinguées.\n\n" + cfg.FromName
+	if _, err := s.sendAndLog(invoiceID, recipients, reminder.Subject, strings.TrimSpace(body), reminder.PDFPath, invoicePath); err != nil {
+		return nil, err
+	}
+
+	reminder.Channel = invoice.ReminderEmail
+	reminder.SentTo = addressList(recipients)
+	if err := s.invoiceService.RecordReminder(reminder); err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// sendAndLog sends files as attachments and records the attempt in the invoice's sent log
+func (s *Service) sendAndLog(invoiceID uint, recipients []*mail.Address, subject string, body string, paths ...string) (*EmailLog, error) {
+	attachments := make([]attachment, 0, len(paths))
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("impossible de lire le PDF (%s): %w", path, err)
+		}
+		attachments = append(attachments, attachment{Name: filepath.Base(path), ContentType: "application/pdf", Data: data})
+		names = append(names, filepath.Base(path))
+	}
+
+	log := EmailLog{
+		InvoiceID:  invoiceID,
+		To:         addressList(recipients),
+		Subject:    subject,
+		Attachment: strings.Join(names, ", "),
+	}
+	sendErr := s.send(recipients, subject, body, attachments)
+
+	log.SentAt = time.Now()
+	log.Status = StatusSent
+	if sendErr != nil {
+		log.Status = StatusFailed
+		log.Error = sendErr.Error()
+	}
+
+	db := database.GetDB()
+	if err := db.Create(&log).Error; err != nil {
+		return nil, fmt.Errorf("échec de l'enregistrement du journal d'envoi: %w", err)
+	}
+	if sendErr != nil {
+		return &log, sendErr
+	}
+	return &log, nil
+}
+
+// SendTestEmail checks the SMTP configuration by sending a short message
+func (s *Service) SendTestEmail(to string) error {
+	recipients, err := mail.ParseAddressList(strings.TrimSpace(to))
+	if err != nil || len(recipients) == 0 {
+		return fmt.Errorf("adresse email du destinataire invalide: %s", to)
+	}
+	return s.send(recipients, "Test de la configuration SMTP", "Ce message confirme que FactureApp peut envoyer des emails.", nil)
+}
+
+// GetEmailLogs returns the sending history of an invoice, most recent first
+func (s *Service) GetEmailLogs(invoiceID uint) ([]EmailLog, error) {
+	db := database.GetDB()
+	var logs []EmailLog
+	if err := db.Where("invoice_id = ?", invoiceID).Order("sent_at DESC").Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture du journal d'envoi: %w", err)
+	}
+	return logs, nil
+}
+
+// send builds and delivers a message with the saved configuration
+func (s *Service) send(recipients []*mail.Address, subject, body string, attachments []attachment) error {
+	cfg, err := s.GetConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Host == "" || cfg.FromAddress == "" {
+		return fmt.Errorf("la configuration SMTP n'est pas renseignée")
+	}
+	password, err := decrypt(cfg.EncryptedPassword)
+	if err != nil {
+		return err
+	}
+
+	from := &mail.Address{Name: cfg.FromName, Address: cfg.FromAddress}
+	msg, err := buildMessage(from, recipients, subject, body, attachments)
+	if err != nil {
+		return fmt.Errorf("échec de la préparation du message: %w", err)
+	}
+
+	addresses := make([]string, len(recipients))
+	for i, r := range recipients {
+		addresses[i] = r.Address
+	}
+	return deliver(cfg, password, cfg.FromAddress, addresses, msg)
+}
+
+// render executes a message template
+func render(name, text string, data TemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("modèle de %s invalide: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("modèle de %s invalide: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// addressList formats recipients for the log
+func addressList(addresses []*mail.Address) string {
+	list := make([]string, len(addresses))
+	for i, a := range addresses {
+		list[i] = a.Address
+	}
+	return strings.Join(list, ", ")
+}
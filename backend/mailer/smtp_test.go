@@ -0,0 +1,242 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"factureapp/backend/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// smtpSession is what the fake server received during one session
+type smtpSession struct {
+	From       string
+	Recipients []string
+	Data       []byte
+}
+
+// startFakeSMTP starts a local SMTP stand-in that accepts one session without TLS or authentication
+// and returns its port and the session once it ends
+func startFakeSMTP(t *testing.T) (int, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("écoute du serveur SMTP: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var session smtpSession
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.TrimRight(line, "\r\n")
+			switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL":
+				session.From = strings.Trim(strings.TrimPrefix(command[len("MAIL FROM:"):], " "), "<>")
+				reply("250 OK")
+			case "RCPT":
+				session.Recipients = append(session.Recipients, strings.Trim(command[len("RCPT TO:"):], " <>"))
+				reply("250 OK")
+			case "DATA":
+				reply("354 Fin avec <CRLF>.<CRLF>")
+				var data bytes.Buffer
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				session.Data = data.Bytes()
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				sessions <- session
+				return
+			default:
+				reply("502 Commande non prise en charge")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, sessions
+}
+
+func TestDeliverMessage(t *testing.T) {
+	port, sessions := startFakeSMTP(t)
+
+	from := &mail.Address{Name: "Atlas Distribution", Address: "factures@atlas.ma"}
+	to := []*mail.Address{{Address: "client@exemple.ma"}, {Name: "Comptabilité", Address: "compta@exemple.ma"}}
+	subject := "Facture 0001 - 2026 – échéance dépassée"
+	body := "Bonjour,\nVeuillez trouver ci-joint la facture d'un montant de 1 234,50 DH, à régler avant l'échéance. " + strings.Repeat("Très cordialement. ", 6)
+	pdf := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte{0x00, 0xff, 0x7f}, 100)...)
+
+	msg, err := buildMessage(from, to, subject, body, []attachment{{Name: "FA-0001-2026.pdf", ContentType: "application/pdf", Data: pdf}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &SMTPConfig{Host: "127.0.0.1", Port: port, Security: SecurityNone}
+	if err := deliver(cfg, "", from.Address, []string{to[0].Address, to[1].Address}, msg); err != nil {
+		t.Fatalf("envoi: %v", err)
+	}
+	session := <-sessions
+
+	// Envelope
+	if session.From != from.Address {
+		t.Errorf("expéditeur de l'enveloppe %q, attendu %q", session.From, from.Address)
+	}
+	if strings.Join(session.Recipients, ",") != "client@exemple.ma,compta@exemple.ma" {
+		t.Errorf("destinataires de l'enveloppe %v", session.Recipients)
+	}
+
+	// Headers: the subject is Q-encoded and decodes back to the original
+	parsed, err := mail.ReadMessage(bytes.NewReader(session.Data))
+	if err != nil {
+		t.Fatalf("message illisible: %v", err)
+	}
+	rawSubject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?utf-8?q?") {
+		t.Errorf("objet non encodé en Q: %s", rawSubject)
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(rawSubject); err != nil || decoded != subject {
+		t.Errorf("objet décodé %q (%v), attendu %q", decoded, err, subject)
+	}
+	for _, line := range strings.Split(string(session.Data), "\r\n") {
+		if len(line) > 998 {
+			t.Errorf("ligne de %d caractères", len(line))
+		}
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("type de contenu %q (%v)", mediaType, err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+
+	// Body: quoted-printable UTF-8 text with CRLF line ends
+	text, err := parts.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+		t.Errorf("corps encodé en %q", text.Header.Get("Content-Transfer-Encoding"))
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != strings.ReplaceAll(body, "\n", "\r\n") {
+		t.Errorf("corps décodé %q", decoded)
+	}
+
+	// Attachment: the PDF, base64-encoded
+	file, err := parts.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FileName() != "FA-0001-2026.pdf" {
+		t.Errorf("pièce jointe nommée %q", file.FileName())
+	}
+	if !strings.HasPrefix(file.Header.Get("Content-Type"), "application/pdf") {
+		t.Errorf("pièce jointe de type %q", file.Header.Get("Content-Type"))
+	}
+	data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, pdf) {
+		t.Error("le PDF reçu diffère du PDF envoyé")
+	}
+	if _, err := parts.NextRawPart(); err != io.EOF {
+		t.Errorf("partie inattendue après la pièce jointe: %v", err)
+	}
+}
+
+func TestSendAndLog(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "invoices.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB = db
+	s := NewService(nil)
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	pdfPath := filepath.Join(t.TempDir(), "FA-0007-2026.pdf")
+	if err := os.WriteFile(pdfPath, []byte("%PDF-1.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recipients := []*mail.Address{{Address: "client@exemple.ma"}}
+
+	port, sessions := startFakeSMTP(t)
+	if err := s.SaveConfig(SMTPConfigRequest{Host: "127.0.0.1", Port: port, Security: SecurityNone, FromAddress: "factures@atlas.ma"}); err != nil {
+		t.Fatal(err)
+	}
+	sent, err := s.sendAndLog(7, recipients, "Facture 0007 - 2026", "Bonjour", pdfPath)
+	if err != nil {
+		t.Fatalf("envoi: %v", err)
+	}
+	<-sessions
+
+	// A second attempt to a server that no longer listens is logged as failed
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	if err := s.SaveConfig(SMTPConfigRequest{Host: "127.0.0.1", Port: closedPort, Security: SecurityNone, FromAddress: "factures@atlas.ma"}); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := s.sendAndLog(7, recipients, "Facture 0007 - 2026", "Bonjour", pdfPath)
+	if err == nil {
+		t.Fatal("envoi accepté par un serveur fermé")
+	}
+
+	logs, err := s.GetEmailLogs(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("%d envoi(s) dans le journal, 2 attendus", len(logs))
+	}
+	byID := map[uint]EmailLog{logs[0].ID: logs[0], logs[1].ID: logs[1]}
+	if log := byID[sent.ID]; log.Status != StatusSent || log.To != "client@exemple.ma" || log.Attachment != "FA-0007-2026.pdf" || log.Error != "" {
+		t.Errorf("envoi réussi journalisé %+v", log)
+	}
+	if log := byID[failed.ID]; log.Status != StatusFailed || log.Error == "" {
+		t.Errorf("envoi échoué journalisé %+v", log)
+	}
+}
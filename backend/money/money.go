@@ -0,0 +1,193 @@
+// Package money provides the integer amount type used for every monetary value.
+//
+// Amounts are stored as whole centimes so sums are exact. Rounding happens only when an
+// amount is multiplied or divided (quantity x unit price, percentages, TVA split), and always
+// rounds half away from zero to the nearest centime.
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary amount in centimes (1 DH = 100)
+type Amount int64
+
+// FromFloat converts an amount in DH to centimes, rounding to the nearest centime
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * 100))
+}
+
+// FromCentimes builds an amount from a number of centimes
+func FromCentimes(c int64) Amount {
+	return Amount(c)
+}
+
+// Float returns the amount in DH, for display or ratios only
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// Centimes returns the raw number of centimes
+func (a Amount) Centimes() int64 {
+	return int64(a)
+}
+
+// Units returns the whole part of the absolute amount (dirhams, euros...)
+func (a Amount) Units() int64 {
+	return a.Abs().Centimes() / 100
+}
+
+// Cents returns the centimes part of the absolute amount (0-99)
+func (a Amount) Cents() int64 {
+	return a.Abs().Centimes() % 100
+}
+
+// Abs returns the absolute amount
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Mul multiplies the amount by a factor (e.g. a quantity), rounding to the centime
+func (a Amount) Mul(factor float64) Amount {
+	return Amount(math.Round(float64(a) * factor))
+}
+
+// Div divides the amount by a divisor (e.g. 1.20 to remove TVA), rounding to the centime
+func (a Amount) Div(divisor float64) Amount {
+	return Amount(math.Round(float64(a) / divisor))
+}
+
+// Percent returns pct% of the amount, rounding to the centime
+func (a Amount) Percent(pct float64) Amount {
+	return Amount(math.Round(float64(a) * pct / 100))
+}
+
+// String formats the amount with two decimals, e.g. "1234.50"
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, a.Units(), a.Cents())
+}
+
+// MarshalJSON encodes the amount as a decimal number in DH so the frontend keeps working with plain numbers
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a decimal number in DH (or a quoted one)
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(strings.TrimSpace(string(data)), `"`)
+	if s == "" || s == "null" {
+		*a = 0
+		return nil
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Parse reads a decimal amount in DH ("19.99", "-5", "1234,5") without going through float rounding
+func Parse(s string) (Amount, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	if s == "" {
+		return 0, fmt.Errorf("montant vide")
+	}
+
+	// Exponent notation only comes from float serialisation: round it
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("montant invalide '%s'", s)
+		}
+		return FromFloat(f), nil
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("montant invalide '%s'", s)
+	}
+
+	var cents int64
+	for i, r := range frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("montant invalide '%s'", s)
+		}
+		d := int64(r - '0')
+		switch {
+		case i < 2:
+			cents = cents*10 + d
+		case i == 2 && d >= 5:
+			// Round half away from zero on the third decimal
+			cents++
+		}
+	}
+	if len(frac) == 1 {
+		cents *= 10
+	}
+
+	total := Amount(units*100 + cents)
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// Value stores the amount as an integer number of centimes
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan reads centimes from the database. Floats come from SQL expressions over centimes and are rounded.
+func (a *Amount) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case float64:
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	default:
+		return fmt.Errorf("impossible de lire un montant depuis %T", value)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("montant invalide en base '%s'", s)
+	}
+	*a = Amount(math.Round(f))
+	return nil
+}
+
+// GormDataType makes GORM create integer columns for amounts
+func (Amount) GormDataType() string {
+	return "integer"
+}
@@ -0,0 +1,104 @@
+package pos
+
+import (
+	"time"
+
+	"factureapp/backend/money"
+
+	"gorm.io/gorm"
+)
+
+// Cash session statuses
+const (
+	SessionOpen   = "OPEN"
+	SessionClosed = "CLOSED"
+)
+
+// CashSession represents a cash register session, from the opening float to the Z-report
+type CashSession struct {
+	gorm.Model
+	Status       string       `gorm:"index" json:"status"` // OPEN, CLOSED
+	ZNumber      int          `json:"zNumber"`             // Sequential Z-report number, assigned on close
+	OpenedAt     time.Time    `json:"openedAt"`
+	ClosedAt     *time.Time   `json:"closedAt"`
+	OpeningFloat money.Amount `json:"openingFloat"` // Cash in the drawer when the session starts
+	ClosingCount money.Amount `json:"closingCount"` // Cash counted in the drawer at close
+	ExpectedCash money.Amount `json:"expectedCash"` // Opening float + cash sales
+	Variance     money.Amount `json:"variance"`     // ClosingCount - ExpectedCash
+
+	Tickets []Ticket `gorm:"foreignKey:SessionID" json:"tickets,omitempty"`
+}
+
+// TicketItem represents a single line on a counter ticket
+type TicketItem struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	TicketID    uint         `gorm:"index" json:"ticketId"`
+	ProductID   uint         `json:"productId"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity"`
+	BuyingPrice money.Amount `json:"buyingPrice"` // Snapshot of product buying price at time of sale
+	PrixUnitTTC money.Amount `json:"prixUnitTTC"`
+	TotalTTC    money.Amount `json:"totalTTC"`
+}
+
+// Ticket represents a counter sale receipt
+type Ticket struct {
+	gorm.Model
+	SessionID      uint      `gorm:"index" json:"sessionId"`
+	Number         string    `gorm:"uniqueIndex;size:15" json:"number"` // Format: "T-000001"
+	SequenceNumber int       `json:"sequenceNumber"`
+	Date           time.Time `json:"date"`
+
+	TotalHT  money.Amount `json:"totalHT"`
+	TotalTVA money.Amount `json:"totalTVA"`
+	TotalTTC money.Amount `json:"totalTTC"`
+
+	PaymentMethod  string       `json:"paymentMethod"`  // ESPECE, CARTE, CHEQUE
+	AmountTendered money.Amount `json:"amountTendered"` // Cash handed over by the customer
+	ChangeDue      money.Amount `json:"changeDue"`
+
+	Items []TicketItem `gorm:"foreignKey:TicketID" json:"items"`
+}
+
+// CartLine is a product resolved from a scan, ready to be added to the cart
+type CartLine struct {
+	ProductID    uint         `json:"productId"`
+	Reference    string       `json:"reference"`
+	Description  string       `json:"description"`
+	PrixUnitTTC  money.Amount `json:"prixUnitTTC"`
+	CurrentStock int          `json:"currentStock"`
+}
+
+// TicketItemRequest is the DTO for a cart line at checkout
+type TicketItemRequest struct {
+	ProductID   uint         `json:"productId"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity"`
+	PrixUnitTTC money.Amount `json:"prixUnitTTC"`
+}
+
+// CheckoutRequest is the DTO for closing a cart into a ticket
+type CheckoutRequest struct {
+	SessionID      uint                `json:"sessionId"`
+	PaymentMethod  string              `json:"paymentMethod"`
+	AmountTendered money.Amount        `json:"amountTendered"`
+	Items          []TicketItemRequest `json:"items"`
+}
+
+// ZReport summarises a closed cash session
+type ZReport struct {
+	SessionID       uint                    `json:"sessionId"`
+	ZNumber         int                     `json:"zNumber"`
+	OpenedAt        string                  `json:"openedAt"`
+	ClosedAt        string                  `json:"closedAt"`
+	TicketCount     int64                   `json:"ticketCount"`
+	TotalHT         money.Amount            `json:"totalHT"`
+	TotalTVA        money.Amount            `json:"totalTVA"`
+	TotalTTC        money.Amount            `json:"totalTTC"`
+	ByPaymentMethod map[string]money.Amount `json:"byPaymentMethod"`
+	OpeningFloat    money.Amount            `json:"openingFloat"`
+	CashSales       money.Amount            `json:"cashSales"`
+	ExpectedCash    money.Amount            `json:"expectedCash"`
+	ClosingCount    money.Amount            `json:"closingCount"`
+	Variance        money.Amount            `json:"variance"`
+}
@@ -0,0 +1,485 @@
+package pos
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"factureapp/backend/invoice"
+
+	"github.com/johnfercher/maroto/v2"
+	"github.com/johnfercher/maroto/v2/pkg/components/col"
+	"github.com/johnfercher/maroto/v2/pkg/components/line"
+	"github.com/johnfercher/maroto/v2/pkg/components/text"
+	"github.com/johnfercher/maroto/v2/pkg/config"
+	"github.com/johnfercher/maroto/v2/pkg/consts/align"
+	"github.com/johnfercher/maroto/v2/pkg/consts/fontstyle"
+	"github.com/johnfercher/maroto/v2/pkg/props"
+)
+
+// ESC/POS control sequences
+var (
+	escInit        = []byte{0x1B, 0x40}       // ESC @: reset printer
+	escCodePage858 = []byte{0x1B, 0x74, 19}   // ESC t 19: PC858 (Latin-1 + €)
+	escAlignLeft   = []byte{0x1B, 0x61, 0}    // ESC a 0
+	escAlignCenter = []byte{0x1B, 0x61, 1}    // ESC a 1
+	escBoldOn      = []byte{0x1B, 0x45, 1}    // ESC E 1
+	escBoldOff     = []byte{0x1B, 0x45, 0}    // ESC E 0
+	escDoubleSize  = []byte{0x1D, 0x21, 0x11} // GS ! 0x11: double width and height
+	escNormalSize  = []byte{0x1D, 0x21, 0x00} // GS ! 0
+	escFeedAndCut  = []byte{0x1D, 0x56, 0x42, 0x03}
+)
+
+// cp858 maps the accented characters used in French labels to code page 858
+var cp858 = map[rune]byte{
+	'Ç': 0x80, 'ü': 0x81, 'é': 0x82, 'â': 0x83, 'ä': 0x84, 'à': 0x85, 'ç': 0x87,
+	'ê': 0x88, 'ë': 0x89, 'è': 0x8A, 'ï': 0x8B, 'î': 0x8C, 'É': 0x90, 'ô': 0x93,
+	'ö': 0x94, 'û': 0x96, 'ù': 0x97, 'À': 0xB7, 'Ê': 0xD2, 'È': 0xD4, '€': 0xD5,
+	'°': 0xF8,
+}
+
+// ReceiptColumns returns the number of characters per line for a paper width in mm
+func ReceiptColumns(paperWidthMM int) (int, error) {
+	switch paperWidthMM {
+	case 58:
+		return 32, nil
+	case 80:
+		return 48, nil
+	default:
+		return 0, fmt.Errorf("largeur de papier non supportée: %d mm (58 ou 80 attendu)", paperWidthMM)
+	}
+}
+
+// escPosWriter accumulates an ESC/POS byte stream
+type escPosWriter struct {
+	buf     bytes.Buffer
+	columns int
+}
+
+func (w *escPosWriter) raw(b []byte) {
+	w.buf.Write(b)
+}
+
+// text writes a line encoded in PC858; unsupported runes are replaced by '?'
+func (w *escPosWriter) text(s string) {
+	for _, r := range s {
+		switch {
+		case r < 0x80:
+			w.buf.WriteByte(byte(r))
+		case cp858[r] != 0:
+			w.buf.WriteByte(cp858[r])
+		default:
+			w.buf.WriteByte('?')
+		}
+	}
+	w.buf.WriteByte('\n')
+}
+
+// pair writes a label on the left and a value flush right
+func (w *escPosWriter) pair(label, value string) {
+	w.text(padBetween(label, value, w.columns))
+}
+
+func (w *escPosWriter) rule() {
+	w.text(strings.Repeat("-", w.columns))
+}
+
+// padBetween fills the gap between left and right with spaces to reach width characters
+func padBetween(left, right string, width int) string {
+	gap := width - len([]rune(left)) - len([]rune(right))
+	if gap < 1 {
+		// Truncate the label so the amount always stays readable
+		keep := width - len([]rune(right)) - 1
+		if keep < 0 {
+			keep = 0
+		}
+		left = string([]rune(left)[:keep])
+		gap = 1
+	}
+	return left + strings.Repeat(" ", gap) + right
+}
+
+// RenderReceipt renders a ticket as an ESC/POS byte stream for a 58 or 80 mm thermal printer
+func RenderReceipt(ticket *Ticket, paperWidthMM int) ([]byte, error) {
+	columns, err := ReceiptColumns(paperWidthMM)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &escPosWriter{columns: columns}
+	w.raw(escInit)
+	w.raw(escCodePage858)
+
+	// Header
+	w.raw(escAlignCenter)
+	w.raw(escBoldOn)
+	w.raw(escDoubleSize)
+	w.text("TICKET")
+	w.raw(escNormalSize)
+	w.raw(escBoldOff)
+	w.text("ICE: " + invoice.CompanyICE)
+	w.text(ticket.Number + "  " + ticket.Date.Format("02-01-2006 15:04"))
+	w.raw(escAlignLeft)
+	w.rule()
+
+	// Items: description on its own line, then quantity x price and line total
+	for _, item := range ticket.Items {
+		w.text(item.Description)
+		w.pair(fmt.Sprintf("  %g x %s", item.Quantity, item.PrixUnitTTC), item.TotalTTC.String())
+	}
+	w.rule()
+
+	// Totals
+	w.pair("Total HT", fmt.Sprintf("%s DH", ticket.TotalHT))
+	w.pair("TVA 20%", fmt.Sprintf("%s DH", ticket.TotalTVA))
+	w.raw(escBoldOn)
+	w.pair("TOTAL TTC", fmt.Sprintf("%s DH", ticket.TotalTTC))
+	w.raw(escBoldOff)
+	w.rule()
+
+	// Payment
+	w.pair("Paiement", paymentLabel(ticket.PaymentMethod))
+	if ticket.PaymentMethod == "ESPECE" {
+		w.pair("Remis", fmt.Sprintf("%s DH", ticket.AmountTendered))
+		w.pair("Rendu", fmt.Sprintf("%s DH", ticket.ChangeDue))
+	}
+
+	w.raw(escAlignCenter)
+	w.text("")
+	w.text("Merci de votre visite")
+	w.raw(escFeedAndCut)
+
+	return w.buf.Bytes(), nil
+}
+
+// RenderZReport renders a Z-report as an ESC/POS byte stream
+func RenderZReport(report *ZReport, paperWidthMM int) ([]byte, error) {
+	columns, err := ReceiptColumns(paperWidthMM)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &escPosWriter{columns: columns}
+	w.raw(escInit)
+	w.raw(escCodePage858)
+
+	w.raw(escAlignCenter)
+	w.raw(escBoldOn)
+	if report.ZNumber > 0 {
+		w.text(fmt.Sprintf("RAPPORT Z N° %d", report.ZNumber))
+	} else {
+		w.text("RAPPORT X (caisse ouverte)")
+	}
+	w.raw(escBoldOff)
+	w.text("Ouverture: " + report.OpenedAt)
+	if report.ClosedAt != "" {
+		w.text("Clôture: " + report.ClosedAt)
+	}
+	w.raw(escAlignLeft)
+	w.rule()
+
+	w.pair("Tickets", fmt.Sprintf("%d", report.TicketCount))
+	w.pair("Total HT", fmt.Sprintf("%s DH", report.TotalHT))
+	w.pair("TVA 20%", fmt.Sprintf("%s DH", report.TotalTVA))
+	w.pair("Total TTC", fmt.Sprintf("%s DH", report.TotalTTC))
+	w.rule()
+
+	for _, method := range []string{"ESPECE", "CARTE", "CHEQUE"} {
+		if amount, ok := report.ByPaymentMethod[method]; ok {
+			w.pair(paymentLabel(method), fmt.Sprintf("%s DH", amount))
+		}
+	}
+	w.rule()
+
+	w.pair("Fond de caisse", fmt.Sprintf("%s DH", report.OpeningFloat))
+	w.pair("Espèces attendues", fmt.Sprintf("%s DH", report.ExpectedCash))
+	if report.ZNumber > 0 {
+		w.pair("Espèces comptées", fmt.Sprintf("%s DH", report.ClosingCount))
+		w.raw(escBoldOn)
+		w.pair("Écart", fmt.Sprintf("%s DH", report.Variance))
+		w.raw(escBoldOff)
+	}
+
+	w.raw(escFeedAndCut)
+
+	return w.buf.Bytes(), nil
+}
+
+// GenerateReceiptPDF renders a ticket as a narrow PDF and returns the file path
+func (s *Service) GenerateReceiptPDF(ticketID uint, paperWidthMM int) (string, error) {
+	if _, err := ReceiptColumns(paperWidthMM); err != nil {
+		return "", err
+	}
+
+	ticket, err := s.GetTicketByID(ticketID)
+	if err != nil {
+		return "", err
+	}
+
+	// Roll paper has no fixed length: size the page to the content
+	pageHeight := 95.0 + float64(len(ticket.Items))*9.0
+	fontSize := 8.0
+	if paperWidthMM == 58 {
+		fontSize = 7.0
+	}
+
+	cfg := config.NewBuilder().
+		WithDimensions(float64(paperWidthMM), pageHeight).
+		WithLeftMargin(3).
+		WithRightMargin(3).
+		WithTopMargin(3).
+		Build()
+
+	m := maroto.New(cfg)
+
+	centered := props.Text{Size: fontSize, Align: align.Center}
+	left := props.Text{Size: fontSize}
+	right := props.Text{Size: fontSize, Align: align.Right}
+	bold := props.Text{Size: fontSize + 1, Style: fontstyle.Bold, Align: align.Right}
+
+	separator := func() {
+		m.AddRow(2, col.New(12).Add(line.New(props.Line{Thickness: 0.2})))
+	}
+
+	m.AddRow(7, col.New(12).Add(text.New("TICKET", props.Text{Size: fontSize + 4, Style: fontstyle.Bold, Align: align.Center})))
+	m.AddRow(4, col.New(12).Add(text.New("ICE: "+invoice.CompanyICE, centered)))
+	m.AddRow(4, col.New(12).Add(text.New(ticket.Number+"  "+ticket.Date.Format("02-01-2006 15:04"), centered)))
+	separator()
+
+	for _, item := range ticket.Items {
+		m.AddRow(4, col.New(12).Add(text.New(item.Description, left)))
+		m.AddRow(4,
+			col.New(8).Add(text.New(fmt.Sprintf("  %g x %s", item.Quantity, item.PrixUnitTTC), left)),
+			col.New(4).Add(text.New(item.TotalTTC.String(), right)),
+		)
+	}
+	separator()
+
+	m.AddRow(4,
+		col.New(7).Add(text.New("Total HT", left)),
+		col.New(5).Add(text.New(fmt.Sprintf("%s DH", ticket.TotalHT), right)),
+	)
+	m.AddRow(4,
+		col.New(7).Add(text.New("TVA 20%", left)),
+		col.New(5).Add(text.New(fmt.Sprintf("%s DH", ticket.TotalTVA), right)),
+	)
+	m.AddRow(6,
+		col.New(6).Add(text.New("TOTAL TTC", props.Text{Size: fontSize + 1, Style: fontstyle.Bold})),
+		col.New(6).Add(text.New(fmt.Sprintf("%s DH", ticket.TotalTTC), bold)),
+	)
+	separator()
+
+	m.AddRow(4,
+		col.New(6).Add(text.New("Paiement", left)),
+		col.New(6).Add(text.New(paymentLabel(ticket.PaymentMethod), right)),
+	)
+	if ticket.PaymentMethod == "ESPECE" {
+		m.AddRow(4,
+			col.New(6).Add(text.New("Remis", left)),
+			col.New(6).Add(text.New(fmt.Sprintf("%s DH", ticket.AmountTendered), right)),
+		)
+		m.AddRow(4,
+			col.New(6).Add(text.New("Rendu", left)),
+			col.New(6).Add(text.New(fmt.Sprintf("%s DH", ticket.ChangeDue), right)),
+		)
+	}
+
+	m.AddRow(8, col.New(12).Add(text.New("Merci de votre visite", props.Text{Size: fontSize, Align: align.Center, Top: 3})))
+
+	doc, err := m.Generate()
+	if err != nil {
+		return "", fmt.Errorf("échec de la génération du ticket: %w", err)
+	}
+
+	ticketDir, err := receiptDir()
+	if err != nil {
+		return "", err
+	}
+
+	pdfPath := filepath.Join(ticketDir, fmt.Sprintf("Ticket_%s_%dmm.pdf", ticket.Number, paperWidthMM))
+	if err := doc.Save(pdfPath); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder le ticket (%s): vérifiez les permissions et l'espace disque disponible", pdfPath)
+	}
+
+	return pdfPath, nil
+}
+
+// WriteReceiptEscPos renders a ticket as ESC/POS and writes it to a file ready to be sent raw to the printer
+func (s *Service) WriteReceiptEscPos(ticketID uint, paperWidthMM int) (string, error) {
+	ticket, err := s.GetTicketByID(ticketID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := RenderReceipt(ticket, paperWidthMM)
+	if err != nil {
+		return "", err
+	}
+
+	ticketDir, err := receiptDir()
+	if err != nil {
+		return "", err
+	}
+
+	binPath := filepath.Join(ticketDir, fmt.Sprintf("Ticket_%s_%dmm.bin", ticket.Number, paperWidthMM))
+	if err := os.WriteFile(binPath, data, 0644); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder le ticket (%s): vérifiez les permissions et l'espace disque disponible", binPath)
+	}
+
+	return binPath, nil
+}
+
+// GenerateZReportPDF renders the Z-report of a session (X-report while it is open) as a narrow PDF
+// and returns the file path
+func (s *Service) GenerateZReportPDF(sessionID uint, paperWidthMM int) (string, error) {
+	if _, err := ReceiptColumns(paperWidthMM); err != nil {
+		return "", err
+	}
+
+	report, err := s.GetZReport(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	fontSize := 8.0
+	if paperWidthMM == 58 {
+		fontSize = 7.0
+	}
+
+	cfg := config.NewBuilder().
+		WithDimensions(float64(paperWidthMM), 120).
+		WithLeftMargin(3).
+		WithRightMargin(3).
+		WithTopMargin(3).
+		Build()
+
+	m := maroto.New(cfg)
+
+	centered := props.Text{Size: fontSize, Align: align.Center}
+	left := props.Text{Size: fontSize}
+	right := props.Text{Size: fontSize, Align: align.Right}
+
+	separator := func() {
+		m.AddRow(2, col.New(12).Add(line.New(props.Line{Thickness: 0.2})))
+	}
+	pair := func(label, value string, style fontstyle.Type) {
+		m.AddRow(4,
+			col.New(7).Add(text.New(label, props.Text{Size: fontSize, Style: style})),
+			col.New(5).Add(text.New(value, props.Text{Size: fontSize, Style: style, Align: align.Right})),
+		)
+	}
+
+	title := "RAPPORT X (caisse ouverte)"
+	if report.ZNumber > 0 {
+		title = fmt.Sprintf("RAPPORT Z N° %d", report.ZNumber)
+	}
+	m.AddRow(7, col.New(12).Add(text.New(title, props.Text{Size: fontSize + 2, Style: fontstyle.Bold, Align: align.Center})))
+	m.AddRow(4, col.New(12).Add(text.New("Ouverture: "+report.OpenedAt, centered)))
+	if report.ClosedAt != "" {
+		m.AddRow(4, col.New(12).Add(text.New("Clôture: "+report.ClosedAt, centered)))
+	}
+	separator()
+
+	m.AddRow(4,
+		col.New(7).Add(text.New("Tickets", left)),
+		col.New(5).Add(text.New(fmt.Sprintf("%d", report.TicketCount), right)),
+	)
+	pair("Total HT", fmt.Sprintf("%s DH", report.TotalHT), fontstyle.Normal)
+	pair("TVA 20%", fmt.Sprintf("%s DH", report.TotalTVA), fontstyle.Normal)
+	pair("Total TTC", fmt.Sprintf("%s DH", report.TotalTTC), fontstyle.Bold)
+	separator()
+
+	for _, method := range []string{"ESPECE", "CARTE", "CHEQUE"} {
+		if amount, ok := report.ByPaymentMethod[method]; ok {
+			pair(paymentLabel(method), fmt.Sprintf("%s DH", amount), fontstyle.Normal)
+		}
+	}
+	separator()
+
+	pair("Fond de caisse", fmt.Sprintf("%s DH", report.OpeningFloat), fontstyle.Normal)
+	pair("Espèces attendues", fmt.Sprintf("%s DH", report.ExpectedCash), fontstyle.Normal)
+	if report.ZNumber > 0 {
+		pair("Espèces comptées", fmt.Sprintf("%s DH", report.ClosingCount), fontstyle.Normal)
+		pair("Écart", fmt.Sprintf("%s DH", report.Variance), fontstyle.Bold)
+	}
+
+	doc, err := m.Generate()
+	if err != nil {
+		return "", fmt.Errorf("échec de la génération du rapport de caisse: %w", err)
+	}
+
+	ticketDir, err := receiptDir()
+	if err != nil {
+		return "", err
+	}
+
+	pdfPath := filepath.Join(ticketDir, fmt.Sprintf("%s_%dmm.pdf", zReportName(report), paperWidthMM))
+	if err := doc.Save(pdfPath); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder le rapport de caisse (%s): vérifiez les permissions et l'espace disque disponible", pdfPath)
+	}
+
+	return pdfPath, nil
+}
+
+// WriteZReportEscPos renders the Z-report of a session as ESC/POS and writes it to a file ready to be
+// sent raw to the printer
+func (s *Service) WriteZReportEscPos(sessionID uint, paperWidthMM int) (string, error) {
+	report, err := s.GetZReport(sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := RenderZReport(report, paperWidthMM)
+	if err != nil {
+		return "", err
+	}
+
+	ticketDir, err := receiptDir()
+	if err != nil {
+		return "", err
+	}
+
+	binPath := filepath.Join(ticketDir, fmt.Sprintf("%s_%dmm.bin", zReportName(report), paperWidthMM))
+	if err := os.WriteFile(binPath, data, 0644); err != nil {
+		return "", fmt.Errorf("impossible de sauvegarder le rapport de caisse (%s): vérifiez les permissions et l'espace disque disponible", binPath)
+	}
+
+	return binPath, nil
+}
+
+// zReportName returns the file name of a report: its Z number once closed, the session otherwise
+func zReportName(report *ZReport) string {
+	if report.ZNumber > 0 {
+		return fmt.Sprintf("Rapport_Z_%d", report.ZNumber)
+	}
+	return fmt.Sprintf("Rapport_X_session_%d", report.SessionID)
+}
+
+// receiptDir returns (and creates) the folder where tickets are written
+func receiptDir() (string, error) {
+	outputDir, err := os.UserConfigDir()
+	if err != nil {
+		outputDir = "."
+	}
+	ticketDir := filepath.Join(outputDir, "FactureApp", "tickets")
+	if err := os.MkdirAll(ticketDir, 0755); err != nil {
+		return "", fmt.Errorf("impossible de créer le dossier des tickets (%s): vérifiez les permissions ou l'espace disque", ticketDir)
+	}
+	return ticketDir, nil
+}
+
+// paymentLabel returns the French label of a payment method
+func paymentLabel(method string) string {
+	switch method {
+	case "ESPECE":
+		return "Espèce"
+	case "CARTE":
+		return "Carte"
+	case "CHEQUE":
+		return "Chèque"
+	default:
+		return method
+	}
+}
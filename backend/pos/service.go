@@ -0,0 +1,364 @@
+package pos
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"factureapp/backend/database"
+	"factureapp/backend/inventory"
+	"factureapp/backend/money"
+
+	"gorm.io/gorm"
+)
+
+// Service handles point-of-sale business logic
+type Service struct {
+	inventoryService *inventory.Service
+}
+
+// NewService creates a new point-of-sale service
+func NewService(inventoryService *inventory.Service) *Service {
+	return &Service{
+		inventoryService: inventoryService,
+	}
+}
+
+// Migrate runs database migrations for point-of-sale models
+func (s *Service) Migrate() error {
+	db := database.GetDB()
+	if err := db.AutoMigrate(&CashSession{}, &Ticket{}, &TicketItem{}); err != nil {
+		return err
+	}
+
+	// Amounts were stored as REAL dirhams before they became integer centimes
+	return database.RunOnce("pos_amounts_to_centimes", func(tx *gorm.DB) error {
+		if err := database.ConvertToCentimes(tx, "cash_sessions", "opening_float", "closing_count", "expected_cash", "variance"); err != nil {
+			return err
+		}
+		if err := database.ConvertToCentimes(tx, "tickets", "total_ht", "total_tva", "total_ttc", "amount_tendered", "change_due"); err != nil {
+			return err
+		}
+		return database.ConvertToCentimes(tx, "ticket_items", "buying_price", "prix_unit_ttc", "total_ttc")
+	})
+}
+
+// OpenSession opens a new cash register session with the given opening float
+func (s *Service) OpenSession(openingFloat money.Amount) (*CashSession, error) {
+	if openingFloat < 0 {
+		return nil, fmt.Errorf("le fond de caisse ne peut pas être négatif")
+	}
+
+	db := database.GetDB()
+
+	// Only one session can be open at a time
+	var count int64
+	if err := db.Model(&CashSession{}).Where("status = ?", SessionOpen).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("échec de la vérification de la caisse: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("une session de caisse est déjà ouverte, clôturez-la avant d'en ouvrir une nouvelle")
+	}
+
+	session := CashSession{
+		Status:       SessionOpen,
+		OpenedAt:     time.Now(),
+		OpeningFloat: openingFloat,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("échec de l'ouverture de la caisse: %w", err)
+	}
+	return &session, nil
+}
+
+// GetCurrentSession returns the open cash session, or nil if the register is closed
+func (s *Service) GetCurrentSession() (*CashSession, error) {
+	db := database.GetDB()
+	var sessions []CashSession
+	if err := db.Where("status = ?", SessionOpen).Order("opened_at DESC").Limit(1).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return &sessions[0], nil
+}
+
+// ScanProduct resolves a scanned code into a cart line
+func (s *Service) ScanProduct(code string) (*CartLine, error) {
+	product, err := s.inventoryService.GetProductByCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CartLine{
+		ProductID:    product.ID,
+		Reference:    product.Reference,
+		Description:  product.Name,
+		PrixUnitTTC:  product.SellingPriceTTC,
+		CurrentStock: product.CurrentStock,
+	}, nil
+}
+
+// Checkout turns a cart into a ticket, decrementing stock and computing change
+func (s *Service) Checkout(req CheckoutRequest) (*Ticket, error) {
+	// Validate items
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("le panier est vide")
+	}
+	for i, item := range req.Items {
+		if item.ProductID == 0 {
+			return nil, fmt.Errorf("article %d: aucun produit sélectionné", i+1)
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("article %d: la quantité doit être supérieure à 0", i+1)
+		}
+		// Stock is counted in whole units
+		if item.Quantity != math.Trunc(item.Quantity) {
+			return nil, fmt.Errorf("article %d: quantité %g invalide, le stock est compté en unités entières", i+1, item.Quantity)
+		}
+		if item.PrixUnitTTC <= 0 {
+			return nil, fmt.Errorf("article %d: le prix unitaire doit être supérieur à 0", i+1)
+		}
+	}
+
+	switch req.PaymentMethod {
+	case "ESPECE", "CARTE", "CHEQUE":
+	default:
+		return nil, fmt.Errorf("mode de paiement invalide: %s", req.PaymentMethod)
+	}
+
+	db := database.GetDB()
+
+	// Start transaction
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var session CashSession
+	if err := tx.First(&session, req.SessionID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("session de caisse introuvable: %w", err)
+	}
+	if session.Status != SessionOpen {
+		tx.Rollback()
+		return nil, fmt.Errorf("la session de caisse est clôturée")
+	}
+
+	var totalTTC money.Amount
+	items := make([]TicketItem, len(req.Items))
+	for i, item := range req.Items {
+		var product inventory.Product
+		if err := tx.First(&product, item.ProductID).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("produit introuvable (ID: %d): %w", item.ProductID, err)
+		}
+
+		description := strings.TrimSpace(item.Description)
+		if description == "" {
+			description = product.Name
+		}
+
+		itemTotal := item.PrixUnitTTC.Mul(item.Quantity)
+		items[i] = TicketItem{
+			ProductID:   item.ProductID,
+			Description: description,
+			Quantity:    item.Quantity,
+			BuyingPrice: product.BuyingPrice, // Snapshot buying price
+			PrixUnitTTC: item.PrixUnitTTC,
+			TotalTTC:    itemTotal,
+		}
+		totalTTC += itemTotal
+
+		// Decrement stock
+		if err := s.inventoryService.DecreaseStock(tx, item.ProductID, int(item.Quantity)); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// Reverse tax calculation: HT = TTC / 1.20, TVA takes the rounding difference
+	totalHT := totalTTC.Div(1.20)
+	totalTVA := totalTTC - totalHT
+
+	// Cash payments must cover the total; other methods are taken for the exact amount
+	tendered := req.AmountTendered
+	if req.PaymentMethod == "ESPECE" {
+		if tendered < totalTTC {
+			tx.Rollback()
+			return nil, fmt.Errorf("montant remis insuffisant (total: %s DH, remis: %s DH)", totalTTC, tendered)
+		}
+	} else {
+		tendered = totalTTC
+	}
+
+	// Ticket numbering is continuous across sessions
+	var lastTicket Ticket
+	tx.Order("sequence_number DESC").First(&lastTicket)
+
+	nextSequence := 1
+	if lastTicket.ID != 0 {
+		nextSequence = lastTicket.SequenceNumber + 1
+	}
+
+	ticket := Ticket{
+		SessionID:      session.ID,
+		Number:         fmt.Sprintf("T-%06d", nextSequence),
+		SequenceNumber: nextSequence,
+		Date:           time.Now(),
+		TotalHT:        totalHT,
+		TotalTVA:       totalTVA,
+		TotalTTC:       totalTTC,
+		PaymentMethod:  req.PaymentMethod,
+		AmountTendered: tendered,
+		ChangeDue:      tendered - totalTTC,
+		Items:          items,
+	}
+
+	if err := tx.Create(&ticket).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("échec de l'enregistrement du ticket: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("échec de la validation de la transaction: %w", err)
+	}
+
+	return &ticket, nil
+}
+
+// GetTicketByID returns a single ticket with its items
+func (s *Service) GetTicketByID(id uint) (*Ticket, error) {
+	db := database.GetDB()
+	var ticket Ticket
+	if err := db.Preload("Items").First(&ticket, id).Error; err != nil {
+		return nil, fmt.Errorf("ticket introuvable: %w", err)
+	}
+	return &ticket, nil
+}
+
+// CloseSession closes the cash session with the counted drawer amount and returns its Z-report
+func (s *Service) CloseSession(sessionID uint, closingCount money.Amount) (*ZReport, error) {
+	if closingCount < 0 {
+		return nil, fmt.Errorf("le montant compté ne peut pas être négatif")
+	}
+
+	db := database.GetDB()
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var session CashSession
+	if err := tx.First(&session, sessionID).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("session de caisse introuvable: %w", err)
+	}
+	if session.Status != SessionOpen {
+		tx.Rollback()
+		return nil, fmt.Errorf("la session de caisse est déjà clôturée")
+	}
+
+	var cashSales struct {
+		Total money.Amount
+	}
+	if err := tx.Model(&Ticket{}).
+		Where("session_id = ? AND payment_method = ?", session.ID, "ESPECE").
+		Select("sum(total_ttc) as total").
+		Scan(&cashSales).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("échec du calcul des ventes en espèces: %w", err)
+	}
+
+	// Z numbers are sequential over closed sessions
+	var lastZ struct {
+		Max int
+	}
+	tx.Model(&CashSession{}).Select("max(z_number) as max").Scan(&lastZ)
+
+	now := time.Now()
+	session.Status = SessionClosed
+	session.ClosedAt = &now
+	session.ZNumber = lastZ.Max + 1
+	session.ClosingCount = closingCount
+	session.ExpectedCash = session.OpeningFloat + cashSales.Total
+	session.Variance = session.ClosingCount - session.ExpectedCash
+
+	if err := tx.Save(&session).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("échec de la clôture de la caisse: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("échec de la validation de la transaction: %w", err)
+	}
+
+	return s.GetZReport(session.ID)
+}
+
+// GetZReport builds the Z-report (or an interim X-report for an open session)
+func (s *Service) GetZReport(sessionID uint) (*ZReport, error) {
+	db := database.GetDB()
+
+	var session CashSession
+	if err := db.First(&session, sessionID).Error; err != nil {
+		return nil, fmt.Errorf("session de caisse introuvable: %w", err)
+	}
+
+	report := &ZReport{
+		SessionID:       session.ID,
+		ZNumber:         session.ZNumber,
+		OpenedAt:        session.OpenedAt.Format("02-01-2006 15:04"),
+		ByPaymentMethod: make(map[string]money.Amount),
+		OpeningFloat:    session.OpeningFloat,
+		ClosingCount:    session.ClosingCount,
+	}
+	if session.ClosedAt != nil {
+		report.ClosedAt = session.ClosedAt.Format("02-01-2006 15:04")
+	}
+
+	rows, err := db.Model(&Ticket{}).
+		Select("payment_method, count(id), sum(total_ht), sum(total_tva), sum(total_ttc)").
+		Where("session_id = ?", session.ID).
+		Group("payment_method").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var method string
+		var count int64
+		var ht, tva, ttc money.Amount
+		if err := rows.Scan(&method, &count, &ht, &tva, &ttc); err != nil {
+			return nil, err
+		}
+		report.TicketCount += count
+		report.TotalHT += ht
+		report.TotalTVA += tva
+		report.TotalTTC += ttc
+		report.ByPaymentMethod[method] = ttc
+	}
+
+	report.CashSales = report.ByPaymentMethod["ESPECE"]
+	report.ExpectedCash = report.OpeningFloat + report.CashSales
+	if session.Status == SessionClosed {
+		report.Variance = session.Variance
+	}
+
+	return report, nil
+}
@@ -0,0 +1,45 @@
+package pricing
+
+import (
+	"factureapp/backend/money"
+
+	"gorm.io/gorm"
+)
+
+// PriceList is a named tariff (détail, gros, revendeur...) that can be assigned to clients
+type PriceList struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex" json:"name"`
+	Description string `json:"description"`
+	IsDefault   bool   `json:"isDefault"` // Applied to clients without a price list
+
+	Entries []PriceListEntry `gorm:"foreignKey:PriceListID" json:"entries,omitempty"`
+}
+
+// PriceListEntry is the TTC unit price of a product in a list from a minimum quantity (quantity break)
+type PriceListEntry struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	PriceListID uint         `gorm:"uniqueIndex:idx_price_entry" json:"priceListId"`
+	ProductID   uint         `gorm:"uniqueIndex:idx_price_entry;index" json:"productId"`
+	MinQuantity float64      `gorm:"uniqueIndex:idx_price_entry" json:"minQuantity"` // Applies from this quantity upwards
+	PriceTTC    money.Amount `json:"priceTTC"`
+}
+
+// Price sources
+const (
+	SourcePriceList = "PRICE_LIST"
+	SourceProduct   = "PRODUCT" // Product.SellingPriceTTC, no list entry applies
+)
+
+// PriceQuote is the unit price resolved for a (product, client, quantity) triple
+type PriceQuote struct {
+	ProductID     uint         `json:"productId"`
+	Quantity      float64      `json:"quantity"`
+	UnitPriceTTC  money.Amount `json:"unitPriceTTC"`
+	BasePriceTTC  money.Amount `json:"basePriceTTC"` // Product.SellingPriceTTC, for comparison
+	BuyingPrice   money.Amount `json:"buyingPrice"`
+	Source        string       `json:"source"`
+	PriceListID   uint         `json:"priceListId"`
+	PriceListName string       `json:"priceListName"`
+	MinQuantity   float64      `json:"minQuantity"` // Quantity break that matched
+}
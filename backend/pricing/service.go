@@ -0,0 +1,303 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+
+	"factureapp/backend/client"
+	"factureapp/backend/database"
+	"factureapp/backend/inventory"
+	"factureapp/backend/money"
+
+	"gorm.io/gorm"
+)
+
+// contains checks if a string contains a substring (case-insensitive)
+func contains(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// Service handles price lists and unit price resolution
+type Service struct{}
+
+// NewService creates a new pricing service
+func NewService() *Service {
+	return &Service{}
+}
+
+// Migrate runs database migrations for pricing models and seeds the standard lists
+func (s *Service) Migrate() error {
+	db := database.GetDB()
+	if err := db.AutoMigrate(&PriceList{}, &PriceListEntry{}); err != nil {
+		return err
+	}
+
+	// Prices were stored as REAL dirhams before amounts became integer centimes
+	if err := database.RunOnce("pricing_amounts_to_centimes", func(tx *gorm.DB) error {
+		return database.ConvertToCentimes(tx, "price_list_entries", "price_ttc")
+	}); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := db.Model(&PriceList{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	defaults := []PriceList{
+		{Name: "Détail", Description: "Prix public", IsDefault: true},
+		{Name: "Gros", Description: "Clients grossistes"},
+		{Name: "Revendeur", Description: "Revendeurs et installateurs"},
+	}
+	return db.Create(&defaults).Error
+}
+
+// GetAllPriceLists returns every price list
+func (s *Service) GetAllPriceLists() ([]PriceList, error) {
+	db := database.GetDB()
+	var lists []PriceList
+	if err := db.Order("name ASC").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// CreatePriceList creates a new price list
+func (s *Service) CreatePriceList(list PriceList) (*PriceList, error) {
+	list.Name = strings.TrimSpace(list.Name)
+	if len(list.Name) == 0 {
+		return nil, fmt.Errorf("le nom du tarif est obligatoire")
+	}
+	list.Entries = nil
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if list.IsDefault {
+			if err := tx.Model(&PriceList{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&list).Error
+	})
+	if err != nil {
+		if contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("un tarif nommé '%s' existe déjà", list.Name)
+		}
+		return nil, fmt.Errorf("échec de la création du tarif: %w", err)
+	}
+	return &list, nil
+}
+
+// UpdatePriceList renames a price list or changes the default one
+func (s *Service) UpdatePriceList(list PriceList) error {
+	list.Name = strings.TrimSpace(list.Name)
+	if len(list.Name) == 0 {
+		return fmt.Errorf("le nom du tarif est obligatoire")
+	}
+
+	db := database.GetDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if list.IsDefault {
+			if err := tx.Model(&PriceList{}).Where("is_default = ? AND id <> ?", true, list.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&PriceList{}).Where("id = ?", list.ID).Updates(map[string]interface{}{
+			"name":        list.Name,
+			"description": list.Description,
+			"is_default":  list.IsDefault,
+		}).Error
+	})
+	if err != nil {
+		if contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("un tarif nommé '%s' existe déjà", list.Name)
+		}
+		return fmt.Errorf("échec de la mise à jour du tarif: %w", err)
+	}
+	return nil
+}
+
+// DeletePriceList deletes a price list that is not assigned to any client
+func (s *Service) DeletePriceList(id uint) error {
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&client.Client{}).Where("price_list_id = ?", id).Count(&count).Error; err != nil {
+		return fmt.Errorf("échec de la vérification d'utilisation: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("impossible de supprimer ce tarif car il est attribué à %d client(s)", count)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("price_list_id = ?", id).Delete(&PriceListEntry{}).Error; err != nil {
+			return fmt.Errorf("échec de la suppression des prix du tarif: %w", err)
+		}
+		if err := tx.Delete(&PriceList{}, id).Error; err != nil {
+			return fmt.Errorf("échec de la suppression du tarif: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetPriceListEntries returns the prices of a list, optionally restricted to one product
+func (s *Service) GetPriceListEntries(priceListID uint, productID uint) ([]PriceListEntry, error) {
+	db := database.GetDB()
+	query := db.Where("price_list_id = ?", priceListID)
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var entries []PriceListEntry
+	if err := query.Order("product_id ASC, min_quantity ASC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SavePriceListEntry creates or updates the price of a product for a quantity break
+func (s *Service) SavePriceListEntry(entry PriceListEntry) (*PriceListEntry, error) {
+	if entry.PriceListID == 0 {
+		return nil, fmt.Errorf("aucun tarif sélectionné")
+	}
+	if entry.ProductID == 0 {
+		return nil, fmt.Errorf("aucun produit sélectionné")
+	}
+	if entry.PriceTTC <= 0 {
+		return nil, fmt.Errorf("le prix doit être supérieur à 0")
+	}
+	if entry.MinQuantity < 0 {
+		return nil, fmt.Errorf("la quantité minimale ne peut pas être négative")
+	}
+
+	db := database.GetDB()
+
+	var list PriceList
+	if err := db.First(&list, entry.PriceListID).Error; err != nil {
+		return nil, fmt.Errorf("tarif introuvable: %w", err)
+	}
+	var product inventory.Product
+	if err := db.First(&product, entry.ProductID).Error; err != nil {
+		return nil, fmt.Errorf("produit introuvable: %w", err)
+	}
+
+	// Same list, product and break: update the existing price
+	var existing PriceListEntry
+	db.Where("price_list_id = ? AND product_id = ? AND min_quantity = ?", entry.PriceListID, entry.ProductID, entry.MinQuantity).Limit(1).Find(&existing)
+	if existing.ID != 0 && existing.ID != entry.ID {
+		entry.ID = existing.ID
+	}
+
+	if err := db.Save(&entry).Error; err != nil {
+		return nil, fmt.Errorf("échec de l'enregistrement du prix: %w", err)
+	}
+	return &entry, nil
+}
+
+// DeletePriceListEntry removes a price from a list
+func (s *Service) DeletePriceListEntry(id uint) error {
+	db := database.GetDB()
+	if err := db.Delete(&PriceListEntry{}, id).Error; err != nil {
+		return fmt.Errorf("échec de la suppression du prix: %w", err)
+	}
+	return nil
+}
+
+// ResolvePrice returns the applicable TTC unit price for a product sold to a client in a given quantity.
+// The client's list is used (or the default list), picking the highest quantity break not above the quantity.
+// Variants without their own entry fall back to their parent's entries, then to the product selling price.
+func (s *Service) ResolvePrice(productID uint, clientICE string, quantity float64) (*PriceQuote, error) {
+	db := database.GetDB()
+
+	var product inventory.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("produit introuvable (ID: %d): %w", productID, err)
+	}
+
+	quote := &PriceQuote{
+		ProductID:    product.ID,
+		Quantity:     quantity,
+		UnitPriceTTC: product.SellingPriceTTC,
+		BasePriceTTC: product.SellingPriceTTC,
+		BuyingPrice:  product.BuyingPrice,
+		Source:       SourceProduct,
+	}
+
+	list, err := s.listForClient(db, clientICE)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		return quote, nil
+	}
+
+	candidates := []uint{product.ID}
+	if product.ParentID != nil {
+		candidates = append(candidates, *product.ParentID)
+	}
+
+	for _, candidate := range candidates {
+		var entries []PriceListEntry
+		if err := db.Where("price_list_id = ? AND product_id = ? AND min_quantity <= ?", list.ID, candidate, quantity).
+			Order("min_quantity DESC").
+			Limit(1).
+			Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			quote.UnitPriceTTC = entries[0].PriceTTC
+			quote.Source = SourcePriceList
+			quote.PriceListID = list.ID
+			quote.PriceListName = list.Name
+			quote.MinQuantity = entries[0].MinQuantity
+			return quote, nil
+		}
+	}
+
+	return quote, nil
+}
+
+// listForClient returns the client's price list, else the default list, else nil
+func (s *Service) listForClient(db *gorm.DB, clientICE string) (*PriceList, error) {
+	if clientICE != "" {
+		var clients []client.Client
+		if err := db.Where("ice = ?", clientICE).Limit(1).Find(&clients).Error; err != nil {
+			return nil, err
+		}
+		if len(clients) > 0 && clients[0].PriceListID != nil {
+			var list PriceList
+			if err := db.First(&list, *clients[0].PriceListID).Error; err == nil {
+				return &list, nil
+			}
+		}
+	}
+
+	var lists []PriceList
+	if err := db.Where("is_default = ?", true).Limit(1).Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, nil
+	}
+	return &lists[0], nil
+}
+
+// CheckPrice returns human-readable warnings when a unit price is below the applicable list price or below cost
+func (s *Service) CheckPrice(productID uint, clientICE string, quantity float64, unitPriceTTC money.Amount) ([]string, error) {
+	quote, err := s.ResolvePrice(productID, clientICE, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	if quote.Source == SourcePriceList && unitPriceTTC < quote.UnitPriceTTC {
+		warnings = append(warnings, fmt.Sprintf("prix unitaire %s DH inférieur au tarif '%s' (%s DH)", unitPriceTTC, quote.PriceListName, quote.UnitPriceTTC))
+	}
+	if quote.BuyingPrice > 0 && unitPriceTTC < quote.BuyingPrice {
+		warnings = append(warnings, fmt.Sprintf("prix unitaire %s DH inférieur au prix d'achat (%s DH): vente à perte", unitPriceTTC, quote.BuyingPrice))
+	}
+	return warnings, nil
+}
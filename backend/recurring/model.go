@@ -0,0 +1,106 @@
+package recurring
+
+import (
+	"time"
+
+	"factureapp/backend/money"
+
+	"gorm.io/gorm"
+)
+
+// Billing frequencies
+const (
+	FrequencyMonthly   = "MONTHLY"
+	FrequencyQuarterly = "QUARTERLY"
+	FrequencyYearly    = "YEARLY"
+)
+
+// Schedule bills a client the same lines at a fixed frequency, e.g. a maintenance or rental contract
+type Schedule struct {
+	gorm.Model
+	ClientID   uint       `gorm:"index" json:"clientId"`
+	Label      string     `json:"label"`
+	Frequency  string     `json:"frequency"`  // MONTHLY, QUARTERLY or YEARLY
+	DayOfMonth int        `json:"dayOfMonth"` // 1-31, the last day of shorter months
+	StartDate  time.Time  `json:"startDate"`
+	EndDate    *time.Time `json:"endDate"` // nil: until stopped
+	Active     bool       `json:"active"`
+
+	// Generated invoices are saved as drafts for review instead of being issued
+	AsDraft       bool   `json:"asDraft"`
+	PaymentMethod string `json:"paymentMethod"` // CHEQUE, EFFET or ESPECE
+
+	// Outcome of the last generation attempt, kept until a period is billed successfully
+	LastError string `json:"lastError,omitempty"`
+
+	Items []ScheduleItem `gorm:"foreignKey:ScheduleID" json:"items"`
+}
+
+// ScheduleItem is an invoice line billed every period
+type ScheduleItem struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	ScheduleID    uint         `gorm:"index" json:"scheduleId"`
+	ProductID     uint         `json:"productId"`
+	Description   string       `json:"description"`
+	Quantity      float64      `json:"quantity"`
+	PrixUnitTTC   money.Amount `json:"prixUnitTTC"` // Used on TTC invoices
+	PrixUnitHT    money.Amount `json:"prixUnitHT"`  // Used on HT invoices
+	DiscountType  string       `json:"discountType"`
+	DiscountValue float64      `json:"discountValue"`
+}
+
+// BilledPeriod records that a period of a schedule was invoiced, so that it is never billed twice
+type BilledPeriod struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID uint      `gorm:"uniqueIndex:idx_billed_periods_schedule_period" json:"scheduleId"`
+	Period     string    `gorm:"uniqueIndex:idx_billed_periods_schedule_period;size:7" json:"period"` // YYYY-MM of the billing date
+	InvoiceID  uint      `json:"invoiceId"`
+	BilledAt   time.Time `json:"billedAt"`
+}
+
+// ScheduleRequest is the DTO for creating or updating a schedule from frontend
+type ScheduleRequest struct {
+	ClientID      uint                  `json:"clientId"`
+	Label         string                `json:"label"`
+	Frequency     string                `json:"frequency"`
+	DayOfMonth    int                   `json:"dayOfMonth"`
+	StartDate     string                `json:"startDate"` // DD-MM-YYYY
+	EndDate       string                `json:"endDate"`   // DD-MM-YYYY, empty: no end
+	Active        bool                  `json:"active"`
+	AsDraft       bool                  `json:"asDraft"`
+	PaymentMethod string                `json:"paymentMethod"`
+	Items         []ScheduleItemRequest `json:"items"`
+}
+
+// ScheduleItemRequest is a line of the schedule's item template
+type ScheduleItemRequest struct {
+	ProductID     uint         `json:"productId"`
+	Description   string       `json:"description"`
+	Quantity      float64      `json:"quantity"`
+	PrixUnitTTC   money.Amount `json:"prixUnitTTC"`
+	PrixUnitHT    money.Amount `json:"prixUnitHT"`
+	DiscountType  string       `json:"discountType"`
+	DiscountValue float64      `json:"discountValue"`
+}
+
+// GeneratedInvoice is an invoice created for a period of a schedule
+type GeneratedInvoice struct {
+	ScheduleID  uint   `json:"scheduleId"`
+	Period      string `json:"period"`
+	InvoiceID   uint   `json:"invoiceId"`
+	FormattedID string `json:"formattedId"` // Empty for drafts
+	Draft       bool   `json:"draft"`
+}
+
+// GenerationFailure is a period that could not be billed; it is retried on the next run
+type GenerationFailure struct {
+	ScheduleID uint   `json:"scheduleId"`
+	Period     string `json:"period"`
+	Error      string `json:"error"`
+}
+
+// GenerationReport sums up a run of the generator
+type GenerationReport struct {
+	Generated []GeneratedInvoice  `json:"generated"`
+	Failed    []GenerationFailure `json:"failed"`
+}
@@ -0,0 +1,361 @@
+package recurring
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"factureapp/backend/client"
+	"factureapp/backend/database"
+	"factureapp/backend/invoice"
+
+	"gorm.io/gorm"
+)
+
+// Service handles recurring invoice schedules and their generation
+type Service struct {
+	invoiceService *invoice.Service
+}
+
+// NewService creates a new recurring invoice service
+func NewService(invoiceService *invoice.Service) *Service {
+	return &Service{invoiceService: invoiceService}
+}
+
+// Migrate runs database migrations for recurring invoice models
+func (s *Service) Migrate() error {
+	return database.GetDB().AutoMigrate(&Schedule{}, &ScheduleItem{}, &BilledPeriod{})
+}
+
+// CreateSchedule creates a recurring invoice schedule for a client
+func (s *Service) CreateSchedule(req ScheduleRequest) (*Schedule, error) {
+	schedule := &Schedule{}
+	if err := applyRequest(schedule, req); err != nil {
+		return nil, err
+	}
+	if err := database.GetDB().Create(schedule).Error; err != nil {
+		return nil, fmt.Errorf("échec de la création de l'échéancier: %w", err)
+	}
+	return schedule, nil
+}
+
+// UpdateSchedule replaces the settings and item template of a schedule.
+// Periods already billed stay billed, even if the new dates cover them again.
+func (s *Service) UpdateSchedule(id uint, req ScheduleRequest) (*Schedule, error) {
+	var schedule Schedule
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&schedule, id).Error; err != nil {
+			return fmt.Errorf("échéancier introuvable: %w", err)
+		}
+		if err := applyRequest(&schedule, req); err != nil {
+			return err
+		}
+		schedule.LastError = ""
+		if err := tx.Where("schedule_id = ?", id).Delete(&ScheduleItem{}).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour des articles de l'échéancier: %w", err)
+		}
+		if err := tx.Save(&schedule).Error; err != nil {
+			return fmt.Errorf("échec de la mise à jour de l'échéancier: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule deletes a schedule; the invoices it generated are kept
+func (s *Service) DeleteSchedule(id uint) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", id).Delete(&ScheduleItem{}).Error; err != nil {
+			return fmt.Errorf("échec de la suppression des articles de l'échéancier: %w", err)
+		}
+		if err := tx.Delete(&Schedule{}, id).Error; err != nil {
+			return fmt.Errorf("échec de la suppression de l'échéancier: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetSchedules returns the schedules of a client, or of every client when clientID is 0
+func (s *Service) GetSchedules(clientID uint) ([]Schedule, error) {
+	query := database.GetDB().Preload("Items")
+	if clientID != 0 {
+		query = query.Where("client_id = ?", clientID)
+	}
+	var schedules []Schedule
+	if err := query.Order("client_id, id").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des échéanciers: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetBilledPeriods returns the periods already billed for a schedule, oldest first
+func (s *Service) GetBilledPeriods(scheduleID uint) ([]BilledPeriod, error) {
+	var periods []BilledPeriod
+	if err := database.GetDB().Where("schedule_id = ?", scheduleID).Order("period").Find(&periods).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des périodes facturées: %w", err)
+	}
+	return periods, nil
+}
+
+// ReleasePeriod frees a period left claimed without an invoice by an interrupted run, once it has been
+// checked that no invoice was created for it, so that the next run bills it
+func (s *Service) ReleasePeriod(scheduleID uint, period string) error {
+	result := database.GetDB().Where("schedule_id = ? AND period = ? AND invoice_id = 0", scheduleID, period).Delete(&BilledPeriod{})
+	if result.Error != nil {
+		return fmt.Errorf("échec de la libération de la période %s: %w", period, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("la période %s n'est pas en attente de facturation pour cet échéancier", period)
+	}
+	return nil
+}
+
+// GenerateDueInvoices bills every period due up to today that has not been billed yet
+func (s *Service) GenerateDueInvoices() (*GenerationReport, error) {
+	now := time.Now()
+	return s.generateDue(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// generateDue bills the periods of the active schedules whose billing date is on or before asOf.
+// Each schedule is billed in period order and stops at its first failure, retried on the next run.
+func (s *Service) generateDue(asOf time.Time) (*GenerationReport, error) {
+	db := database.GetDB()
+	var schedules []Schedule
+	if err := db.Preload("Items").Where("active = ?", true).Order("id").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("échec de la lecture des échéanciers: %w", err)
+	}
+
+	report := &GenerationReport{}
+	for i := range schedules {
+		schedule := &schedules[i]
+		var billed []BilledPeriod
+		if err := db.Where("schedule_id = ?", schedule.ID).Find(&billed).Error; err != nil {
+			return nil, fmt.Errorf("échec de la lecture des périodes facturées: %w", err)
+		}
+		done := make(map[string]bool, len(billed))
+		failed := false
+		for _, claim := range billed {
+			done[claim.Period] = true
+			// A claim without invoice was left by an interrupted run: the invoice may or may not exist,
+			// so the period is reported for review rather than billed again
+			if claim.InvoiceID == 0 {
+				message := "facturation interrompue: vérifiez si la facture de cette période a été créée, sinon libérez la période"
+				report.Failed = append(report.Failed, GenerationFailure{ScheduleID: schedule.ID, Period: claim.Period, Error: message})
+				db.Model(schedule).Update("last_error", fmt.Sprintf("période %s: %s", claim.Period, message))
+				failed = true
+			}
+		}
+
+		for _, date := range billingDates(schedule, asOf) {
+			period := date.Format("2006-01")
+			if done[period] {
+				continue
+			}
+			generated, err := s.billPeriod(schedule, period, date)
+			if err != nil {
+				report.Failed = append(report.Failed, GenerationFailure{ScheduleID: schedule.ID, Period: period, Error: err.Error()})
+				db.Model(schedule).Update("last_error", fmt.Sprintf("période %s: %s", period, err.Error()))
+				failed = true
+				break
+			}
+			report.Generated = append(report.Generated, *generated)
+		}
+		if !failed && schedule.LastError != "" {
+			db.Model(schedule).Update("last_error", "")
+		}
+	}
+	return report, nil
+}
+
+// billPeriod creates the invoice of one period. The period is claimed before the invoice is created,
+// so that a crash in between leaves it billed at most once; the claim is released if creation fails.
+// A claim left without invoice by a crash is reported by generateDue until released with ReleasePeriod.
+func (s *Service) billPeriod(schedule *Schedule, period string, date time.Time) (*GeneratedInvoice, error) {
+	db := database.GetDB()
+	claim := BilledPeriod{ScheduleID: schedule.ID, Period: period, BilledAt: time.Now()}
+	if err := db.Create(&claim).Error; err != nil {
+		return nil, fmt.Errorf("échec de l'enregistrement de la période: %w", err)
+	}
+
+	resp, err := s.createInvoice(schedule, date)
+	if err != nil {
+		if releaseErr := db.Delete(&claim).Error; releaseErr != nil {
+			return nil, fmt.Errorf("%v (la période reste réservée: %v)", err, releaseErr)
+		}
+		return nil, err
+	}
+	if err := db.Model(&claim).Update("invoice_id", resp.ID).Error; err != nil {
+		return nil, fmt.Errorf("facture %d créée mais non rattachée à la période: %w", resp.ID, err)
+	}
+
+	return &GeneratedInvoice{
+		ScheduleID:  schedule.ID,
+		Period:      period,
+		InvoiceID:   resp.ID,
+		FormattedID: resp.FormattedID,
+		Draft:       resp.Status == invoice.StatusDraft,
+	}, nil
+}
+
+// createInvoice issues, or saves as a draft, the invoice of a schedule dated on a billing date
+func (s *Service) createInvoice(schedule *Schedule, date time.Time) (*invoice.InvoiceResponse, error) {
+	var c client.Client
+	if err := database.GetDB().First(&c, schedule.ClientID).Error; err != nil {
+		return nil, fmt.Errorf("client introuvable: %w", err)
+	}
+
+	req := invoice.InvoiceCreateRequest{
+		Date:          date.Format("02-01-2006"),
+		ClientName:    c.Name,
+		ClientCity:    c.City,
+		ClientICE:     c.ICE,
+		PaymentMethod: schedule.PaymentMethod,
+	}
+	for _, item := range schedule.Items {
+		req.Items = append(req.Items, invoice.InvoiceItemRequest{
+			ProductID:     item.ProductID,
+			Description:   item.Description,
+			Quantity:      item.Quantity,
+			PrixUnitTTC:   item.PrixUnitTTC,
+			PrixUnitHT:    item.PrixUnitHT,
+			DiscountType:  item.DiscountType,
+			DiscountValue: item.DiscountValue,
+		})
+	}
+
+	if schedule.AsDraft {
+		return s.invoiceService.CreateDraft(req)
+	}
+	return s.invoiceService.CreateInvoice(req)
+}
+
+// billingDates returns the billing dates of a schedule from its start up to asOf and its end date.
+// The first billing date is the first billing day on or after the start date; the day is moved to
+// the last day of months that are too short.
+func billingDates(schedule *Schedule, asOf time.Time) []time.Time {
+	step := frequencyMonths(schedule.Frequency)
+	if step == 0 {
+		return nil
+	}
+	last := asOf
+	if schedule.EndDate != nil && schedule.EndDate.Before(last) {
+		last = *schedule.EndDate
+	}
+
+	start := schedule.StartDate
+	first := billingDay(start.Year(), start.Month(), schedule.DayOfMonth)
+	if first.Before(start) {
+		first = billingDay(start.Year(), start.Month()+1, schedule.DayOfMonth)
+	}
+
+	var dates []time.Time
+	for n := 0; ; n++ {
+		date := billingDay(first.Year(), first.Month()+time.Month(n*step), schedule.DayOfMonth)
+		if date.After(last) {
+			return dates
+		}
+		dates = append(dates, date)
+	}
+}
+
+// billingDay returns the given day of a month, or the month's last day when it is shorter
+func billingDay(year int, month time.Month, day int) time.Time {
+	firstOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfMonth.AddDate(0, 0, day-1)
+}
+
+// frequencyMonths returns the number of months between two billing dates (0 for an unknown frequency)
+func frequencyMonths(frequency string) int {
+	switch frequency {
+	case FrequencyMonthly:
+		return 1
+	case FrequencyQuarterly:
+		return 3
+	case FrequencyYearly:
+		return 12
+	}
+	return 0
+}
+
+// applyRequest validates a schedule request and copies it into a schedule
+func applyRequest(schedule *Schedule, req ScheduleRequest) error {
+	var c client.Client
+	if err := database.GetDB().First(&c, req.ClientID).Error; err != nil {
+		return fmt.Errorf("client introuvable: %w", err)
+	}
+
+	frequency := strings.ToUpper(strings.TrimSpace(req.Frequency))
+	if frequencyMonths(frequency) == 0 {
+		return fmt.Errorf("fréquence inconnue: %s (MONTHLY, QUARTERLY ou YEARLY attendu)", req.Frequency)
+	}
+	if req.DayOfMonth < 1 || req.DayOfMonth > 31 {
+		return fmt.Errorf("jour de facturation invalide: %d (entre 1 et 31)", req.DayOfMonth)
+	}
+
+	startDate, err := time.Parse("02-01-2006", strings.TrimSpace(req.StartDate))
+	if err != nil {
+		return fmt.Errorf("date de début invalide: %s (format JJ-MM-AAAA attendu)", req.StartDate)
+	}
+	var endDate *time.Time
+	if strings.TrimSpace(req.EndDate) != "" {
+		end, err := time.Parse("02-01-2006", strings.TrimSpace(req.EndDate))
+		if err != nil {
+			return fmt.Errorf("date de fin invalide: %s (format JJ-MM-AAAA attendu)", req.EndDate)
+		}
+		if end.Before(startDate) {
+			return fmt.Errorf("la date de fin ne peut pas précéder la date de début")
+		}
+		endDate = &end
+	}
+
+	paymentMethod := strings.ToUpper(strings.TrimSpace(req.PaymentMethod))
+	switch paymentMethod {
+	case "CHEQUE", "EFFET", "ESPECE":
+	default:
+		return fmt.Errorf("mode de paiement inconnu: %s (CHEQUE, EFFET ou ESPECE attendu)", req.PaymentMethod)
+	}
+
+	if len(req.Items) == 0 {
+		return fmt.Errorf("l'échéancier doit contenir au moins un article")
+	}
+	items := make([]ScheduleItem, len(req.Items))
+	for i, item := range req.Items {
+		if item.ProductID == 0 {
+			return fmt.Errorf("article %d: aucun produit sélectionné", i+1)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("article %d: la quantité doit être supérieure à 0", i+1)
+		}
+		if strings.TrimSpace(item.Description) == "" {
+			return fmt.Errorf("article %d: la description est obligatoire", i+1)
+		}
+		items[i] = ScheduleItem{
+			ProductID:     item.ProductID,
+			Description:   item.Description,
+			Quantity:      item.Quantity,
+			PrixUnitTTC:   item.PrixUnitTTC,
+			PrixUnitHT:    item.PrixUnitHT,
+			DiscountType:  item.DiscountType,
+			DiscountValue: item.DiscountValue,
+		}
+	}
+
+	schedule.ClientID = req.ClientID
+	schedule.Label = strings.TrimSpace(req.Label)
+	schedule.Frequency = frequency
+	schedule.DayOfMonth = req.DayOfMonth
+	schedule.StartDate = startDate
+	schedule.EndDate = endDate
+	schedule.Active = req.Active
+	schedule.AsDraft = req.AsDraft
+	schedule.PaymentMethod = paymentMethod
+	schedule.Items = items
+	return nil
+}
@@ -0,0 +1,39 @@
+package settings
+
+import (
+	"time"
+)
+
+// Setting keys
+const (
+	KeyArchiveRoot = "pdf_archive_root" // Folder where invoice PDFs are archived
+
+	// Seller identity printed on invoices and exported in electronic invoices
+	KeyCompanyName    = "company_name"
+	KeyCompanyAddress = "company_address"
+	KeyCompanyCity    = "company_city"
+	KeyCompanyICE     = "company_ice"
+	KeyCompanyIF      = "company_if" // Identifiant fiscal
+	KeyCompanyRC      = "company_rc" // Registre de commerce
+	KeyCompanyEmail   = "company_email"
+	KeyCompanyPhone   = "company_phone"
+)
+
+// Setting is an application preference stored as a key/value pair
+type Setting struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Company is the seller's identity
+type Company struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	City    string `json:"city"`
+	ICE     string `json:"ice"`
+	IF      string `json:"if"`
+	RC      string `json:"rc"`
+	Email   string `json:"email"`
+	Phone   string `json:"phone"`
+}
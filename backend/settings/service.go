@@ -0,0 +1,84 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+
+	"factureapp/backend/database"
+)
+
+// Service handles application settings
+type Service struct{}
+
+// NewService creates a new settings service
+func NewService() *Service {
+	return &Service{}
+}
+
+// Migrate runs database migrations for settings
+func (s *Service) Migrate() error {
+	db := database.GetDB()
+	return db.AutoMigrate(&Setting{})
+}
+
+// Get returns the value of a setting, or fallback when it is not set
+func (s *Service) Get(key string, fallback string) string {
+	db := database.GetDB()
+	var settings []Setting
+	if err := db.Where("key = ?", key).Limit(1).Find(&settings).Error; err != nil || len(settings) == 0 || settings[0].Value == "" {
+		return fallback
+	}
+	return settings[0].Value
+}
+
+// Set stores the value of a setting
+func (s *Service) Set(key string, value string) error {
+	db := database.GetDB()
+	if err := db.Save(&Setting{Key: key, Value: value}).Error; err != nil {
+		return fmt.Errorf("échec de l'enregistrement du paramètre %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetCompany returns the seller's identity; ice is used when no ICE is set
+func (s *Service) GetCompany(ice string) Company {
+	return Company{
+		Name:    s.Get(KeyCompanyName, ""),
+		Address: s.Get(KeyCompanyAddress, ""),
+		City:    s.Get(KeyCompanyCity, ""),
+		ICE:     s.Get(KeyCompanyICE, ice),
+		IF:      s.Get(KeyCompanyIF, ""),
+		RC:      s.Get(KeyCompanyRC, ""),
+		Email:   s.Get(KeyCompanyEmail, ""),
+		Phone:   s.Get(KeyCompanyPhone, ""),
+	}
+}
+
+// SaveCompany validates and stores the seller's identity
+func (s *Service) SaveCompany(company Company) error {
+	company.Name = strings.TrimSpace(company.Name)
+	company.ICE = strings.TrimSpace(company.ICE)
+	if company.Name == "" {
+		return fmt.Errorf("la raison sociale est obligatoire")
+	}
+	if len(company.ICE) != 15 || strings.Trim(company.ICE, "0123456789") != "" {
+		return fmt.Errorf("l'ICE de la société doit contenir exactement 15 chiffres")
+	}
+
+	values := map[string]string{
+		KeyCompanyName:    company.Name,
+		KeyCompanyAddress: company.Address,
+		KeyCompanyCity:    company.City,
+		KeyCompanyICE:     company.ICE,
+		KeyCompanyIF:      company.IF,
+		KeyCompanyRC:      company.RC,
+		KeyCompanyEmail:   company.Email,
+		KeyCompanyPhone:   company.Phone,
+	}
+	for key, value := range values {
+		if err := s.Set(key, strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
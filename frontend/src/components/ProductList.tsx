@@ -232,6 +232,8 @@ export const ProductList: React.FC = () => {
                                         value={formData.CurrentStock}
                                         onChange={e => setFormData({ ...formData, CurrentStock: parseInt(e.target.value) || 0 })}
                                         onFocus={(e) => e.target.select()}
+                                        disabled={!!editingProduct}
+                                        title={editingProduct ? "Corrigez le stock par un inventaire" : undefined}
                                     />
                                 </div>
                                 <div>
@@ -30,7 +30,7 @@ export const useInventory = () => {
         setLoading(true);
         setError(null);
         try {
-            const data = await GetAllProducts();
+            const data = await GetAllProducts({} as any);
             setProducts(data);
         } catch (err: any) {
             const errorMsg = err?.message || String(err) || 'Failed to fetch products';
@@ -116,7 +116,7 @@ export function useInvoice() {
         const totalTTC = formData.items.reduce((sum, item) => sum + item.totalTTC, 0);
 
         if (totalTTC > 0) {
-            CalculateTotals(totalTTC).then((result) => {
+            CalculateTotals(totalTTC, 'TTC', 'MAD', false, 'fr').then((result) => {
                 setTotalsPreview({
                     totalHT: result.totalHT as number,
                     totalTVA: result.totalTVA as number,
@@ -5,7 +5,7 @@ import {invoice} from '../models';
 import {inventory} from '../models';
 import {main} from '../models';
 
-export function CalculateTotals(arg1:number):Promise<Record<string, any>>;
+export function CalculateTotals(arg1:number,arg2:string,arg3:string,arg4:boolean,arg5:string):Promise<Record<string, any>>;
 
 export function CreateClient(arg1:client.Client):Promise<void>;
 
@@ -21,9 +21,9 @@ export function GeneratePDF(arg1:number):Promise<string>;
 
 export function GetAllClients():Promise<Array<client.Client>>;
 
-export function GetAllInvoices(arg1:number):Promise<Array<invoice.InvoiceResponse>>;
+export function GetAllInvoices(arg1:number,arg2:string):Promise<Array<invoice.InvoiceResponse>>;
 
-export function GetAllProducts():Promise<Array<inventory.Product>>;
+export function GetAllProducts(arg1:inventory.ProductFilter):Promise<Array<inventory.Product>>;
 
 export function GetAvailableYears():Promise<Array<number>>;
 
@@ -31,7 +31,7 @@ export function GetDashboardStats(arg1:number):Promise<main.DashboardStats>;
 
 export function GetInvoiceByID(arg1:number):Promise<invoice.InvoiceResponse>;
 
-export function GetTotalInWords(arg1:number):Promise<string>;
+export function GetTotalInWords(arg1:number,arg2:string,arg3:string):Promise<string>;
 
 export function GetVersion():Promise<string>;
 
@@ -2,8 +2,8 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function CalculateTotals(arg1) {
-  return window['go']['main']['App']['CalculateTotals'](arg1);
+export function CalculateTotals(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['CalculateTotals'](arg1, arg2, arg3, arg4, arg5);
 }
 
 export function CreateClient(arg1) {
@@ -34,12 +34,12 @@ export function GetAllClients() {
   return window['go']['main']['App']['GetAllClients']();
 }
 
-export function GetAllInvoices(arg1) {
-  return window['go']['main']['App']['GetAllInvoices'](arg1);
+export function GetAllInvoices(arg1, arg2) {
+  return window['go']['main']['App']['GetAllInvoices'](arg1, arg2);
 }
 
-export function GetAllProducts() {
-  return window['go']['main']['App']['GetAllProducts']();
+export function GetAllProducts(arg1) {
+  return window['go']['main']['App']['GetAllProducts'](arg1);
 }
 
 export function GetAvailableYears() {
@@ -54,8 +54,8 @@ export function GetInvoiceByID(arg1) {
   return window['go']['main']['App']['GetInvoiceByID'](arg1);
 }
 
-export function GetTotalInWords(arg1) {
-  return window['go']['main']['App']['GetTotalInWords'](arg1);
+export function GetTotalInWords(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetTotalInWords'](arg1, arg2, arg3);
 }
 
 export function GetVersion() {
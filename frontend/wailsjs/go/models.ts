@@ -56,6 +56,24 @@ export namespace client {
 
 export namespace inventory {
 	
+	export class ProductFilter {
+	    query: string;
+	    categoryId: number;
+	    stockState: string;
+	    parentId: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProductFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.query = source["query"];
+	        this.categoryId = source["categoryId"];
+	        this.stockState = source["stockState"];
+	        this.parentId = source["parentId"];
+	    }
+	}
 	export class InventoryStats {
 	    TotalProducts: number;
 	    LowStockCount: number;